@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/archive"
+	auditlogService "github.com/motiso/sparksai-audit-service/internal/auditlog/service"
+	"github.com/motiso/sparksai-audit-service/internal/checkpoint"
+	"github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/db/migrate"
+	"github.com/motiso/sparksai-audit-service/internal/dsar"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/motiso/sparksai-audit-service/internal/rollup"
+)
+
+const usage = `Usage: audit_service [command]
+
+With no command the HTTP server is started.
+
+Commands:
+  migrate up [version]   Apply pending migrations (optionally only up to version)
+  migrate down [steps]   Revert the last applied migration(s) (default 1)
+  migrate status         List migrations and whether they are applied
+  archive verify <file>  Check an archive file against its manifest
+  archive restore <file> [table]
+                         Load an archive file into a scratch table
+                         (default audit_logs_restore_<yyyymmdd>)
+  checkpoint keygen <private-key-file>
+                         Create an ed25519 checkpoint signing key and print its public key
+  checkpoint verify <proof-file> <public-key-file>
+                         Verify an inclusion proof from GET /api/audit-logs/{id}/proof offline
+  rollup backfill [from] Rebuild the hourly report rollups from date from (YYYY-MM-DD,
+                         default the first audit log) up to now
+  encryption add-key <key-file>
+                         Append a new master key to the key file, making it the active key
+  encryption rotate [force]
+                         Re-wrap data keys with the active master key and replace the active
+                         data key when it is due (or always with force)
+  export user <user-id> <zip-file> [json|csv]
+                         Write every audit log of a user, decrypted, with a summary and a
+                         signed manifest (default format json)
+  export verify <zip-file> <public-key-file>
+                         Check an export against its signed manifest offline
+`
+
+// runCommand runs a sub-command and returns the process exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "migrate":
+		return runMigrate(args)
+	case "archive":
+		return runArchive(args)
+	case "checkpoint":
+		return runCheckpoint(args)
+	case "rollup":
+		return runRollup(args)
+	case "encryption":
+		return runEncryption(args)
+	case "export":
+		return runExport(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+}
+
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	// Optional numeric argument (target version for up, steps for down)
+	number := 0
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 0 {
+			fmt.Fprintf(os.Stderr, "invalid number %q\n", args[1])
+			return 2
+		}
+		number = parsed
+	}
+
+	cfg := db.LoadConfig()
+	conn, err := db.Connect(cfg)
+	if err != nil {
+		log.Printf("Error connecting to database: %v", err)
+		return 1
+	}
+	defer conn.Close()
+
+	migrator, err := migrate.New(conn, string(cfg.Driver))
+	if err != nil {
+		log.Printf("Error loading migrations: %v", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(number)
+		if err != nil {
+			log.Printf("Error applying migrations: %v", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(applied))
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+		reverted, err := migrator.Down(number)
+		if err != nil {
+			log.Printf("Error reverting migrations: %v", err)
+			return 1
+		}
+		fmt.Printf("Reverted %d migration(s)\n", len(reverted))
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Printf("Error reading migration status: %v", err)
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt
+			}
+			fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
+
+func runArchive(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	dataPath := args[1]
+
+	switch args[0] {
+	case "verify":
+		manifest, err := archive.Verify(dataPath)
+		if err != nil {
+			log.Printf("Archive verification failed: %v", err)
+			return 1
+		}
+		fmt.Printf("OK %s: %d rows, %d segment(s), sha256 %s\n", manifest.File, manifest.RowCount, len(manifest.Segments), manifest.SHA256)
+	case "restore":
+		table := archive.DefaultRestoreTable(dataPath)
+		if len(args) > 2 {
+			table = args[2]
+		}
+
+		conn, err := db.Open(db.LoadConfig())
+		if err != nil {
+			log.Printf("Error connecting to database: %v", err)
+			return 1
+		}
+		defer conn.Close()
+
+		restored, err := archive.Restore(conn, dataPath, table)
+		if err != nil {
+			log.Printf("Error restoring archive: %v", err)
+			return 1
+		}
+		fmt.Printf("Restored %d row(s) into %s\n", restored, table)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown archive command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
+
+func runCheckpoint(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "keygen":
+		if _, err := os.Stat(args[1]); err == nil {
+			log.Printf("%s already exists, refusing to overwrite it", args[1])
+			return 1
+		}
+		publicKey, err := checkpoint.GenerateKey(args[1])
+		if err != nil {
+			log.Printf("Error generating key: %v", err)
+			return 1
+		}
+		fmt.Print(string(publicKey))
+	case "verify":
+		if len(args) < 3 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			log.Printf("Error reading proof: %v", err)
+			return 1
+		}
+		var proof checkpoint.Proof
+		if err := json.Unmarshal(data, &proof); err != nil {
+			log.Printf("Invalid proof file: %v", err)
+			return 1
+		}
+		publicKey, err := checkpoint.LoadPublicKey(args[2])
+		if err != nil {
+			log.Printf("Error loading public key: %v", err)
+			return 1
+		}
+		if err := checkpoint.VerifyProof(proof, publicKey); err != nil {
+			log.Printf("Proof verification failed: %v", err)
+			return 1
+		}
+		fmt.Printf("OK audit log %d is included in the %s checkpoint %s - %s (leaf %d of %d, key %s)\n",
+			proof.ID, proof.Checkpoint.Period, proof.Checkpoint.PeriodStart, proof.Checkpoint.PeriodEnd,
+			proof.LeafIndex+1, proof.Checkpoint.LeafCount, proof.Checkpoint.KeyID)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown checkpoint command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
+
+func runRollup(args []string) int {
+	if len(args) == 0 || args[0] != "backfill" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	var from time.Time
+	if len(args) > 1 {
+		parsed, err := time.Parse("2006-01-02", args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid date %q, expected YYYY-MM-DD\n", args[1])
+			return 2
+		}
+		from = parsed
+	}
+
+	cfg := db.LoadConfig()
+	if cfg.Driver != db.Postgres {
+		log.Printf("Rollups require PostgreSQL (DB_DRIVER=%s)", cfg.Driver)
+		return 1
+	}
+	conn, err := db.Open(cfg)
+	if err != nil {
+		log.Printf("Error connecting to database: %v", err)
+		return 1
+	}
+	defer conn.Close()
+
+	coverage, err := rollup.NewManager(conn, rollup.LoadConfig()).Backfill(context.Background(), from)
+	if err != nil {
+		log.Printf("Error rebuilding rollups: %v", err)
+		return 1
+	}
+	fmt.Printf("Rollups cover %s - %s\n", coverage.From.Format(time.RFC3339), coverage.To.Format(time.RFC3339))
+	return 0
+}
+
+func runEncryption(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "add-key":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		id, err := encryption.GenerateMasterKey(args[1])
+		if err != nil {
+			log.Printf("Error generating master key: %v", err)
+			return 1
+		}
+		fmt.Printf("Added master key %s to %s; restart the service to start wrapping data keys with it\n", id, args[1])
+	case "rotate":
+		force := len(args) > 1 && args[1] == "force"
+
+		cfg := db.LoadConfig()
+		if cfg.Driver != db.Postgres {
+			log.Printf("Payload encryption requires PostgreSQL (DB_DRIVER=%s)", cfg.Driver)
+			return 1
+		}
+		conn, err := db.Open(cfg)
+		if err != nil {
+			log.Printf("Error connecting to database: %v", err)
+			return 1
+		}
+		defer conn.Close()
+
+		keyring, err := encryption.NewKeyring(conn, encryption.LoadConfig())
+		if err != nil {
+			log.Printf("Error loading master keys: %v", err)
+			return 1
+		}
+		rotation, err := keyring.Rotate(context.Background(), force)
+		if err != nil {
+			log.Printf("Error rotating keys: %v", err)
+			return 1
+		}
+		if rotation.Created != 0 {
+			fmt.Printf("New active data key %d\n", rotation.Created)
+		}
+		fmt.Printf("Re-wrapped %d data key(s)\n", rotation.Rewrapped)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown encryption command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
+
+func runExport(args []string) int {
+	if len(args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "user":
+		format := dsar.FormatJSON
+		if len(args) > 3 {
+			format = args[3]
+		}
+		if format != dsar.FormatJSON && format != dsar.FormatCSV {
+			fmt.Fprintf(os.Stderr, "invalid format %q, expected json or csv\n", format)
+			return 2
+		}
+		if _, err := os.Stat(args[2]); err == nil {
+			log.Printf("%s already exists, refusing to overwrite it", args[2])
+			return 1
+		}
+
+		cfg := db.LoadConfig()
+		if cfg.Driver != db.Postgres {
+			log.Printf("Subject exports require PostgreSQL (DB_DRIVER=%s)", cfg.Driver)
+			return 1
+		}
+		conn, err := db.Open(cfg)
+		if err != nil {
+			log.Printf("Error connecting to database: %v", err)
+			return 1
+		}
+		defer conn.Close()
+
+		keyring, err := encryption.NewKeyring(conn, encryption.LoadConfig())
+		if err != nil {
+			log.Printf("Error loading master keys: %v", err)
+			return 1
+		}
+		datastore := auditlogService.NewAuditLogDataStore(conn, conn, cfg.Driver, keyring)
+		exporter := dsar.NewExporter(conn, datastore, keyring, dsar.LoadConfig())
+
+		file, err := os.OpenFile(args[2], os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Printf("Error creating export file: %v", err)
+			return 1
+		}
+		manifest, err := exporter.Export(context.Background(), file, args[1], format)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(args[2])
+			log.Printf("Error exporting audit logs: %v", err)
+			return 1
+		}
+		fmt.Printf("Exported %d audit log(s) of %s to %s (key %s)\n", manifest.RowCount, args[1], args[2], manifest.KeyID)
+		if manifest.Masked > 0 {
+			fmt.Printf("WARNING %d encrypted value(s) could not be decrypted and are masked\n", manifest.Masked)
+		}
+	case "verify":
+		publicKey, err := checkpoint.LoadPublicKey(args[2])
+		if err != nil {
+			log.Printf("Error loading public key: %v", err)
+			return 1
+		}
+		manifest, err := dsar.Verify(args[1], publicKey)
+		if err != nil {
+			log.Printf("Export verification failed: %v", err)
+			return 1
+		}
+		fmt.Printf("OK %d audit log(s) of %s generated at %s (key %s)\n",
+			manifest.RowCount, manifest.UserID, manifest.GeneratedAt, manifest.KeyID)
+		if manifest.Masked > 0 {
+			fmt.Printf("WARNING %d encrypted value(s) could not be decrypted and are masked\n", manifest.Masked)
+		}
+		for _, gap := range manifest.Excluded {
+			fmt.Printf("NOT SEARCHED ids %d-%d (%s to %s): removed by %s\n", gap.FirstID, gap.LastID, gap.From, gap.To, gap.Reason)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown export command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
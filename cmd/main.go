@@ -1,46 +1,48 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/motiso/sparksai-audit-service/internal/db"
-	"github.com/motiso/sparksai-audit-service/internal/routes"
-	"github.com/rs/cors"
+	"github.com/motiso/sparksai-audit-service/internal/app"
 	"github.com/spf13/viper"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
-	// Initialize database
-	dbConn := db.Get()
-	if dbConn == nil {
-		log.Fatal("Failed to initialize database")
+	// Sub-commands (e.g. "migrate up") run and exit instead of serving
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
 	}
-	defer dbConn.Close()
-
-	// Setup router
-	r := mux.NewRouter()
-
-	// Set up routes
-	routes.SetupRoutes(r)
 
-	// Configure CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowCredentials: true,
-		AllowedHeaders:   []string{"Content-Type"},
-	})
+	application, err := app.New(app.LoadConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := application.Start(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
-	handler := c.Handler(r)
-	port := viper.GetString("SERVER_PORT")
-	if port == "" {
-		port = "8083"
+	// Serve until SIGINT/SIGTERM or a server failure, then shut down gracefully
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		log.Printf("Received %s, shutting down", sig)
+	case err := <-application.Err():
+		log.Printf("HTTP server failed: %v", err)
 	}
-	log.Printf("Audit Service starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
-		log.Fatal(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := application.Stop(ctx); err != nil {
+		log.Printf("Error during shutdown: %v", err)
 	}
 }
 
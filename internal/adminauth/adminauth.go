@@ -0,0 +1,58 @@
+package adminauth
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// TokenHeader carries the token that lets a request use the /api/admin routes
+const TokenHeader = "X-Audit-Admin-Token"
+
+// Config holds the tokens accepted for admin routes
+// With no tokens every admin request is rejected
+type Config struct {
+	Tokens []string // Tokens accepted in X-Audit-Admin-Token
+}
+
+// LoadConfig reads AUDIT_ADMIN_TOKENS (";"-separated) from viper
+func LoadConfig() Config {
+	var tokens []string
+	for _, token := range strings.Split(viper.GetString("AUDIT_ADMIN_TOKENS"), ";") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return Config{Tokens: tokens}
+}
+
+// Middleware rejects requests without a valid X-Audit-Admin-Token
+func (c Config) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(TokenHeader)
+		if token == "" {
+			http.Error(w, "Admin token required", http.StatusUnauthorized)
+			return
+		}
+		if !c.validToken(token) {
+			log.Printf("[ADMIN] Rejected %s %s: invalid admin token", r.Method, r.URL.Path)
+			http.Error(w, "Invalid admin token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validToken compares token against every configured token in constant time
+func (c Config) validToken(token string) bool {
+	valid := false
+	for _, candidate := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,306 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/motiso/sparksai-audit-service/internal/adminauth"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	auditlogService "github.com/motiso/sparksai-audit-service/internal/auditlog/service"
+	"github.com/motiso/sparksai-audit-service/internal/buffer"
+	"github.com/motiso/sparksai-audit-service/internal/checkpoint"
+	"github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/dsar"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/motiso/sparksai-audit-service/internal/erasure"
+	"github.com/motiso/sparksai-audit-service/internal/legalhold"
+	"github.com/motiso/sparksai-audit-service/internal/metaevent"
+	"github.com/motiso/sparksai-audit-service/internal/partition"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
+	"github.com/motiso/sparksai-audit-service/internal/retention"
+	"github.com/motiso/sparksai-audit-service/internal/rollup"
+	"github.com/motiso/sparksai-audit-service/internal/routes"
+	"github.com/motiso/sparksai-audit-service/internal/search"
+	"github.com/rs/cors"
+	"github.com/spf13/viper"
+)
+
+// Config holds every setting the service is built from
+type Config struct {
+	Port       string
+	Database   db.Config
+	Buffer     buffer.Config
+	Partition  partition.Config
+	Retention  retention.Config
+	Checkpoint checkpoint.Config
+	Rollup     rollup.Config
+	Encryption encryption.Config
+	Search     search.Config
+	Erasure    erasure.Config
+	Export     dsar.Config
+	Admin      adminauth.Config
+	Timeouts   querytimeout.Config
+}
+
+// LoadConfig reads the configuration from viper (configs/app.env and the environment)
+func LoadConfig() Config {
+	port := viper.GetString("SERVER_PORT")
+	if port == "" {
+		port = "8083"
+	}
+
+	encryptionCfg := encryption.LoadConfig()
+	return Config{
+		Port:       port,
+		Database:   db.LoadConfig(),
+		Buffer:     buffer.LoadConfig(),
+		Partition:  partition.LoadConfig(),
+		Retention:  retention.LoadConfig(),
+		Checkpoint: checkpoint.LoadConfig(),
+		Rollup:     rollup.LoadConfig(),
+		Encryption: encryptionCfg,
+		Search:     search.LoadConfig(encryptionCfg),
+		Erasure:    erasure.LoadConfig(),
+		Export:     dsar.LoadConfig(),
+		Admin:      adminauth.LoadConfig(),
+		Timeouts:   querytimeout.LoadConfig(),
+	}
+}
+
+// App is one fully wired instance of the service
+// Nothing is shared between instances, so several can run in one process
+type App struct {
+	Config        Config
+	DB            *sql.DB
+	MaintenanceDB *sql.DB      // Same as DB on SQLite; nil when no maintenance role is configured
+	ReadPool      *db.ReadPool // Reads for reports and listings; nil on SQLite
+	Datastore     auditlog.AuditLogDatastore
+	Buffer        *buffer.Buffer
+	AuditService  *auditlogService.AuditService
+	Reports       *auditlogService.ReportService
+	Partitions    *partition.Manager  // Nil on SQLite
+	Retention     *retention.Engine   // Nil on SQLite or without a maintenance role
+	Checkpoints   *checkpoint.Manager // Nil on SQLite
+	Rollups       *rollup.Manager     // Nil on SQLite
+	Encryption    *encryption.Keyring // Nil on SQLite
+	Erasure       *erasure.Manager    // Nil on SQLite or without a maintenance role
+	Exports       *dsar.Exporter      // Nil on SQLite
+	LegalHolds    *legalhold.Manager  // Nil on SQLite
+	MetaEvents    *metaevent.Store    // Nil on SQLite
+	Router        *mux.Router
+
+	server   *http.Server
+	serveErr chan error
+}
+
+// New connects to the database and builds the services and router
+// Background jobs and the HTTP server only run once Start is called
+func New(cfg Config) (*App, error) {
+	if err := cfg.Search.Validate(cfg.Encryption); err != nil {
+		return nil, err
+	}
+	conn, err := db.Open(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	maintenanceConn, err := db.OpenMaintenance(cfg.Database, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	a := &App{
+		Config:        cfg,
+		DB:            conn,
+		MaintenanceDB: maintenanceConn,
+		serveErr:      make(chan error, 1),
+	}
+
+	// SQLite has a single connection, so reads share it
+	var reads db.Queryer = conn
+	if cfg.Database.Driver == db.Postgres {
+		a.ReadPool, err = db.OpenReadPool(cfg.Database)
+		if err != nil {
+			a.closeDB()
+			return nil, err
+		}
+		reads = a.ReadPool
+
+		// Payload encryption keeps its data keys in PostgreSQL
+		a.Encryption, err = encryption.NewKeyring(conn, cfg.Encryption)
+		if err != nil {
+			a.closeDB()
+			return nil, fmt.Errorf("failed to set up payload encryption: %w", err)
+		}
+	} else if cfg.Encryption.Enabled {
+		a.closeDB()
+		return nil, errors.New("AUDIT_ENCRYPTION_ENABLED requires PostgreSQL")
+	}
+
+	a.Datastore = auditlogService.NewAuditLogDataStore(conn, reads, cfg.Database.Driver, a.Encryption)
+	a.Buffer, err = buffer.NewBuffer(a.Datastore, cfg.Buffer)
+	if err != nil {
+		a.closeDB()
+		return nil, fmt.Errorf("invalid buffer configuration: %w", err)
+	}
+	rollups := cfg.Database.Driver == db.Postgres && cfg.Rollup.Enabled
+	a.Reports = auditlogService.NewReportService(reads, cfg.Database.Driver, rollups, cfg.Search.Enabled, a.Encryption)
+	a.AuditService = auditlogService.NewAuditService(a.Datastore, a.Buffer, a.Reports)
+
+	// Partitioning, retention, checkpoints, rollups, erasure, exports and legal holds rely on PostgreSQL features
+	if cfg.Database.Driver == db.Postgres {
+		a.Partitions = partition.NewManager(conn, maintenanceConn, cfg.Partition)
+		a.Checkpoints = checkpoint.NewManager(conn, cfg.Checkpoint)
+		a.Rollups = rollup.NewManager(conn, cfg.Rollup)
+		// Removing audit rows needs the maintenance role; on the main connection the
+		// append-only triggers reject it
+		if maintenanceConn != nil {
+			a.Retention = retention.NewEngine(maintenanceConn, a.Datastore, cfg.Retention)
+			a.Erasure = erasure.NewManager(maintenanceConn, a.Datastore, cfg.Erasure)
+		}
+		a.Exports = dsar.NewExporter(reads, a.Datastore, a.Encryption, cfg.Export)
+		a.LegalHolds = legalhold.NewManager(conn, a.Datastore)
+		a.MetaEvents = metaevent.NewStore(conn)
+	}
+
+	a.Router = mux.NewRouter()
+	routes.SetupRoutes(a.Router, routes.Services{
+		DB:          conn,
+		Audit:       a.AuditService,
+		Reports:     a.Reports,
+		Partitions:  a.Partitions,
+		Retention:   a.Retention,
+		Checkpoints: a.Checkpoints,
+		MetaEvents:  a.MetaEvents,
+		Maintenance: maintenanceConn != nil,
+		Encryption:  a.Encryption,
+		Erasure:     a.Erasure,
+		Exports:     a.Exports,
+		LegalHolds:  a.LegalHolds,
+		Admin:       cfg.Admin,
+		Timeouts:    cfg.Timeouts,
+	})
+
+	// Configure CORS
+	c := cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+		AllowedHeaders:   []string{"Content-Type", encryption.ReaderTokenHeader, adminauth.TokenHeader},
+	})
+	a.server = &http.Server{Addr: ":" + cfg.Port, Handler: c.Handler(a.Router)}
+
+	return a, nil
+}
+
+// Start runs the buffer worker and background jobs, then serves HTTP in the background
+// Errors from the server after it started listening are delivered on Err
+func (a *App) Start() error {
+	a.Buffer.Start()
+	if a.ReadPool != nil {
+		// Keep checking replica health and lag
+		a.ReadPool.Start()
+	}
+	if a.Partitions != nil {
+		// Keep future audit_logs partitions created ahead of time
+		a.Partitions.Start()
+
+		// Purge expired audit logs on schedule (AUDIT_RETENTION_ENABLED)
+		if a.Retention != nil {
+			a.Retention.Start()
+		}
+
+		// Sign Merkle checkpoints of completed periods (AUDIT_CHECKPOINT_ENABLED)
+		a.Checkpoints.Start()
+
+		// Keep the hourly report rollups up to date (AUDIT_ROLLUP_ENABLED)
+		a.Rollups.Start()
+
+		// Rotate payload encryption data keys (AUDIT_ENCRYPTION_ENABLED)
+		a.Encryption.Start()
+
+		// Run submitted erasure jobs (AUDIT_ERASURE_KEY)
+		if a.Erasure != nil {
+			a.Erasure.Start()
+		}
+	}
+
+	listener, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		a.stopJobs()
+		return fmt.Errorf("failed to listen on %s: %w", a.server.Addr, err)
+	}
+
+	log.Printf("Audit Service starting on port %s", a.Config.Port)
+	go func() {
+		if err := a.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.serveErr <- err
+		}
+	}()
+	return nil
+}
+
+// Err delivers an error when the HTTP server stops unexpectedly
+func (a *App) Err() <-chan error {
+	return a.serveErr
+}
+
+// Stop stops accepting requests, waits for in-flight ones (bounded by ctx), stops the
+// background jobs, flushes the buffer and closes the database pools
+func (a *App) Stop(ctx context.Context) error {
+	var errs []error
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shut down HTTP server: %w", err))
+	}
+
+	a.stopJobs()
+
+	if err := a.closeDB(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// closeDB closes every database pool
+func (a *App) closeDB() error {
+	var errs []error
+	if a.ReadPool != nil {
+		if err := a.ReadPool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close read pool: %w", err))
+		}
+	}
+	if a.MaintenanceDB != nil && a.MaintenanceDB != a.DB {
+		if err := a.MaintenanceDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close maintenance pool: %w", err))
+		}
+	}
+	if err := a.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// stopJobs stops the background jobs, then flushes what is left in the buffer
+func (a *App) stopJobs() {
+	if a.Partitions != nil {
+		if a.Erasure != nil {
+			a.Erasure.Stop()
+		}
+		a.Encryption.Stop()
+		a.Rollups.Stop()
+		a.Checkpoints.Stop()
+		if a.Retention != nil {
+			a.Retention.Stop()
+		}
+		a.Partitions.Stop()
+	}
+	if a.ReadPool != nil {
+		a.ReadPool.Stop()
+	}
+	a.Buffer.Stop()
+}
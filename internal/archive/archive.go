@@ -0,0 +1,461 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/viper"
+)
+
+// Format is recorded in every manifest so readers know how to decode the data file
+const Format = "ndjson+zstd"
+
+// Record is one archived audit_logs row
+// Row holds the full row as produced by to_jsonb(audit_logs), so archives keep every
+// column without this package having to track the table definition
+type Record struct {
+	ID        int64
+	CreatedAt time.Time
+	Row       []byte
+}
+
+// Archiver writes expired audit rows to compressed daily files in Dir
+// Each day has one data file (audit_logs_YYYY-MM-DD.ndjson.zst) plus a manifest
+// (audit_logs_YYYY-MM-DD.manifest.json). Later batches for the same day are appended
+// as additional zstd frames, which standard zstd decoders read as one stream.
+type Archiver struct {
+	Dir string
+}
+
+// Manifest describes one daily archive file
+type Manifest struct {
+	File           string    `json:"file"`
+	Day            string    `json:"day"`
+	Format         string    `json:"format"`
+	RowCount       int64     `json:"row_count"`
+	Bytes          int64     `json:"bytes"`
+	SHA256         string    `json:"sha256"`
+	MinID          int64     `json:"min_id"`
+	MaxID          int64     `json:"max_id"`
+	FirstCreatedAt string    `json:"first_created_at"`
+	LastCreatedAt  string    `json:"last_created_at"`
+	Segments       []Segment `json:"segments"`
+	UpdatedAt      string    `json:"updated_at"`
+}
+
+// Segment is one appended batch (one zstd frame) of a daily file
+type Segment struct {
+	Rows       int    `json:"rows"`
+	Bytes      int64  `json:"bytes"`
+	SHA256     string `json:"sha256"`
+	ArchivedAt string `json:"archived_at"`
+}
+
+// Config controls whether expired rows are archived before they are purged
+type Config struct {
+	Enabled bool
+	Dir     string
+}
+
+// LoadConfig reads the archive settings from viper
+func LoadConfig() Config {
+	dir := viper.GetString("AUDIT_ARCHIVE_DIR")
+	if dir == "" {
+		dir = "archive" // default, relative to the working directory
+	}
+	return Config{
+		Enabled: viper.GetBool("AUDIT_ARCHIVE_ENABLED"),
+		Dir:     dir,
+	}
+}
+
+// NewArchiver returns an Archiver when archiving is enabled, otherwise nil
+func NewArchiver(cfg Config) *Archiver {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Archiver{Dir: cfg.Dir}
+}
+
+// Batch is a set of records appended to the archive whose rows are not deleted yet
+// The manifests only take the batch in on Commit, once the delete has committed; Abort
+// cuts it off the data files again. Each staged day keeps a journal next to its manifest
+// (audit_logs_YYYY-MM-DD.pending.json) until then, so Recover can settle it after a crash.
+type Batch struct {
+	archiver *Archiver
+	days     []string
+}
+
+// journal is the pending state of a staged day: enough to undo the append, or to
+// publish it once the rows are known to be deleted
+type journal struct {
+	Offset   int64     `json:"offset"`             // Size of the data file before the batch
+	IDs      []int64   `json:"ids"`                // Rows of the batch
+	Manifest *Manifest `json:"manifest,omitempty"` // Manifest including the batch; unset until the append is synced
+}
+
+// Stage appends records to their daily files without updating the manifests
+// Returns only after every file has been synced to disk, so callers can delete the rows
+// afterwards and then Commit. A day with an unsettled journal must be recovered first.
+func (a *Archiver) Stage(records []Record) (*Batch, error) {
+	batch := &Batch{archiver: a}
+	if len(records) == 0 {
+		return batch, nil
+	}
+	if err := os.MkdirAll(a.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	byDay := map[string][]Record{}
+	for _, record := range records {
+		day := record.CreatedAt.UTC().Format("2006-01-02")
+		byDay[day] = append(byDay[day], record)
+	}
+
+	days := make([]string, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	for _, day := range days {
+		batch.days = append(batch.days, day)
+		if err := a.stageDay(day, byDay[day]); err != nil {
+			if abortErr := batch.Abort(); abortErr != nil {
+				return nil, errors.Join(err, abortErr)
+			}
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// Commit writes the manifests of the staged days and removes their journals
+// Call it once the archived rows are deleted; a journal left behind by a failed
+// Commit is published by the next Recover
+func (b *Batch) Commit() error {
+	for _, day := range b.days {
+		if err := b.archiver.publish(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort truncates the data files of the staged days back to their manifests and removes
+// the journals. Call it when the archived rows could not be deleted
+func (b *Batch) Abort() error {
+	var errs []error
+	for _, day := range b.days {
+		if err := b.archiver.discard(day); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Recover settles the journals left by a crash between Stage and Commit or Abort
+// deleted reports whether the rows of a batch are gone from the database: batches are
+// deleted in one transaction, so either all of them are or none is. Deleted batches are
+// published, the others discarded so the next purge archives their rows again
+func (a *Archiver) Recover(deleted func(ids []int64) (bool, error)) error {
+	journals, err := filepath.Glob(filepath.Join(a.Dir, "audit_logs_*.pending.json"))
+	if err != nil {
+		return err
+	}
+	for _, journalPath := range journals {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(journalPath), "audit_logs_"), ".pending.json")
+		pending, err := readJournal(journalPath)
+		if err != nil {
+			return err
+		}
+
+		// Without a manifest the append never finished, so the rows cannot have been deleted
+		publish := false
+		if pending.Manifest != nil {
+			if publish, err = deleted(pending.IDs); err != nil {
+				return fmt.Errorf("failed to check archived rows of %s: %w", day, err)
+			}
+		}
+		if publish {
+			err = a.publish(day)
+		} else {
+			err = a.discard(day)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("[ARCHIVE] Recovered pending batch of %s (%d rows, published: %t)", day, len(pending.IDs), publish)
+	}
+	return nil
+}
+
+// DataPath returns the data file path for a day
+func (a *Archiver) DataPath(day string) string {
+	return filepath.Join(a.Dir, "audit_logs_"+day+".ndjson.zst")
+}
+
+// ManifestPath returns the manifest path that belongs to a data file
+func ManifestPath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, ".ndjson.zst") + ".manifest.json"
+}
+
+// JournalPath returns the journal path of a day's pending batch
+func (a *Archiver) JournalPath(day string) string {
+	return filepath.Join(a.Dir, "audit_logs_"+day+".pending.json")
+}
+
+// stageDay appends records to a day's data file as one zstd frame and journals the
+// manifest that includes them
+func (a *Archiver) stageDay(day string, records []Record) error {
+	dataPath := a.DataPath(day)
+	manifestPath := ManifestPath(dataPath)
+	journalPath := a.JournalPath(day)
+
+	if _, err := os.Stat(journalPath); err == nil {
+		return fmt.Errorf("archive %s has an unsettled batch; recover it first", day)
+	}
+	manifest, err := ReadManifest(manifestPath)
+	if os.IsNotExist(err) {
+		manifest = &Manifest{File: filepath.Base(dataPath), Day: day, Format: Format, Segments: []Segment{}}
+	} else if err != nil {
+		return err
+	}
+
+	// The data file must hold exactly what the manifest describes before anything is appended
+	var offset int64
+	if info, err := os.Stat(dataPath); err == nil {
+		offset = info.Size()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat archive file: %w", err)
+	}
+	if offset != manifest.Bytes {
+		return fmt.Errorf("archive file %s has %d bytes, its manifest %d", dataPath, offset, manifest.Bytes)
+	}
+
+	pending := &journal{Offset: offset, IDs: make([]int64, 0, len(records))}
+	for _, record := range records {
+		pending.IDs = append(pending.IDs, record.ID)
+	}
+	if err := writeJSON(journalPath, pending); err != nil {
+		return err
+	}
+
+	// Compress the batch as one zstd frame
+	segmentHash := sha256.New()
+	file, err := os.OpenFile(dataPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer file.Close()
+
+	counter := &countingWriter{w: io.MultiWriter(file, segmentHash)}
+	encoder, err := zstd.NewWriter(counter)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	for _, record := range records {
+		if _, err := encoder.Write(append(record.Row, '\n')); err != nil {
+			encoder.Close()
+			return fmt.Errorf("failed to write archive record: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive segment: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive file: %w", err)
+	}
+
+	// Checksum covers the whole file so the manifest verifies every segment at once
+	fileHash, size, err := hashFile(dataPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, record := range records {
+		if manifest.RowCount == 0 || record.ID < manifest.MinID {
+			manifest.MinID = record.ID
+		}
+		if record.ID > manifest.MaxID {
+			manifest.MaxID = record.ID
+		}
+		createdAt := record.CreatedAt.UTC().Format(time.RFC3339Nano)
+		if manifest.FirstCreatedAt == "" || createdAt < manifest.FirstCreatedAt {
+			manifest.FirstCreatedAt = createdAt
+		}
+		if createdAt > manifest.LastCreatedAt {
+			manifest.LastCreatedAt = createdAt
+		}
+		manifest.RowCount++
+	}
+	manifest.Bytes = size
+	manifest.SHA256 = fileHash
+	manifest.UpdatedAt = now
+	manifest.Segments = append(manifest.Segments, Segment{
+		Rows:       len(records),
+		Bytes:      counter.n,
+		SHA256:     hex.EncodeToString(segmentHash.Sum(nil)),
+		ArchivedAt: now,
+	})
+
+	pending.Manifest = manifest
+	return writeJSON(journalPath, pending)
+}
+
+// publish replaces a day's manifest with the one in its journal and removes the journal
+func (a *Archiver) publish(day string) error {
+	journalPath := a.JournalPath(day)
+	pending, err := readJournal(journalPath)
+	if err != nil {
+		return err
+	}
+	if pending.Manifest == nil {
+		return fmt.Errorf("archive %s has no staged manifest to publish", day)
+	}
+	if err := writeJSON(ManifestPath(a.DataPath(day)), pending.Manifest); err != nil {
+		return err
+	}
+	return removeJournal(journalPath)
+}
+
+// discard truncates a day's data file back to its size before the staged batch and
+// removes the journal; a file the batch created is removed
+func (a *Archiver) discard(day string) error {
+	journalPath := a.JournalPath(day)
+	pending, err := readJournal(journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dataPath := a.DataPath(day)
+	if pending.Offset == 0 {
+		err = os.Remove(dataPath)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = os.Truncate(dataPath, pending.Offset)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discard staged archive batch: %w", err)
+	}
+	return removeJournal(journalPath)
+}
+
+// readJournal loads the journal of a pending batch
+func readJournal(path string) (*journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pending journal
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("invalid archive journal %s: %w", path, err)
+	}
+	return &pending, nil
+}
+
+// removeJournal deletes a settled journal
+func removeJournal(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove archive journal: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest loads a manifest file
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// writeJSON replaces a manifest or journal atomically (write to temp file, then rename)
+func writeJSON(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", filepath.Base(path), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Verify checks a data file against its manifest (size and sha256)
+func Verify(dataPath string) (*Manifest, error) {
+	manifest, err := ReadManifest(ManifestPath(dataPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	fileHash, size, err := hashFile(dataPath)
+	if err != nil {
+		return manifest, err
+	}
+	if size != manifest.Bytes || fileHash != manifest.SHA256 {
+		return manifest, fmt.Errorf("checksum mismatch for %s: manifest sha256=%s bytes=%d, file sha256=%s bytes=%d",
+			dataPath, manifest.SHA256, manifest.Bytes, fileHash, size)
+	}
+	return manifest, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
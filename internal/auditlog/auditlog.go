@@ -1,27 +1,170 @@
 package auditlog
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
+)
+
 // AuditLog represents an audit log entry
 type AuditLog struct {
-	ID                  int     `json:"id"`
-	UserID              *string `json:"user_id,omitempty"`
-	Severity            string  `json:"severity,omitempty"`
-	EndpointPath        string  `json:"endpoint_path"`
-	SessionID           *string `json:"session_id,omitempty"` // String
-	Action              *string `json:"action,omitempty"`
-	ActionDate          *string `json:"action_date,omitempty"`
-	Count               *int    `json:"count,omitempty"`
-	HTTPMethod          string  `json:"http_method"`
-	StatusCode          int     `json:"status_code"`
-	ResponseTimeSeconds float64 `json:"response_time_seconds"`
-	CreatedAt           string  `json:"created_at"`
-	IPAddress           *string `json:"ip_address,omitempty"`
-	UserAgent           *string `json:"user_agent,omitempty"`
-	ChatHistoryID       *int    `json:"chat_history_id,omitempty"`
-	InsightsID          *int    `json:"insights_id,omitempty"`
-	TokensUsed          *int    `json:"tokens_used,omitempty"`
-	QueryRaw            *string `json:"query_raw,omitempty"`           // JSONB as string (raw query string)
-	BodyRaw             *string `json:"body_raw,omitempty"`            // JSONB as string (raw body)
-	ResponseBody        *string `json:"response_body,omitempty"`       // JSONB as string (response body for LLM)
+	ID                  int             `json:"id"`
+	UserID              *string         `json:"user_id,omitempty"`
+	Severity            string          `json:"severity,omitempty"`
+	EndpointPath        string          `json:"endpoint_path"`
+	SessionID           *string         `json:"session_id,omitempty"` // String
+	Action              *string         `json:"action,omitempty"`
+	ActionDate          *string         `json:"action_date,omitempty"`
+	Count               *int            `json:"count,omitempty"`
+	HTTPMethod          string          `json:"http_method"`
+	StatusCode          int             `json:"status_code"`
+	ResponseTimeSeconds float64         `json:"response_time_seconds"`
+	CreatedAt           string          `json:"created_at"`
+	IPAddress           *string         `json:"ip_address,omitempty"`
+	UserAgent           *string         `json:"user_agent,omitempty"`
+	ChatHistoryID       *int            `json:"chat_history_id,omitempty"`
+	InsightsID          *int            `json:"insights_id,omitempty"`
+	TokensUsed          *int            `json:"tokens_used,omitempty"`
+	QueryRaw            *string         `json:"query_raw,omitempty"`       // JSONB as string (raw query string)
+	BodyRaw             *string         `json:"body_raw,omitempty"`        // JSONB as string (raw body)
+	ResponseBody        *string         `json:"response_body,omitempty"`   // JSONB as string (response body for LLM)
+	ResourceType        *string         `json:"resource_type,omitempty"`   // Type of the object acted upon (e.g. "sprint")
+	ResourceID          *string         `json:"resource_id,omitempty"`     // ID of the object acted upon (e.g. "42")
+	Outcome             string          `json:"outcome,omitempty"`         // success, failure, denied or error
+	ActorType           *string         `json:"actor_type,omitempty"`      // user, service or system
+	ImpersonatedBy      *string         `json:"impersonated_by,omitempty"` // Real user when acting on behalf of user_id
+	TenantID            *string         `json:"tenant_id,omitempty"`       // Customer organization the request belongs to
+	TraceID             *string         `json:"trace_id,omitempty"`        // Distributed trace the request belongs to
+	Before              json.RawMessage `json:"before,omitempty"`          // Object snapshot before a PUT/PATCH/DELETE
+	After               json.RawMessage `json:"after,omitempty"`           // Object snapshot after a PUT/PATCH/DELETE
+	ChangeDiff          json.RawMessage `json:"change_diff,omitempty"`     // RFC 6902 JSON Patch from before to after (computed on ingest)
+}
+
+// AuditLogRecord is the full stored form of one audit log entry
+// The payload columns are decoded into JSON instead of the JSON strings of AuditLog
+type AuditLogRecord struct {
+	AuditLog
+	QueryRaw     json.RawMessage `json:"query_raw,omitempty"`
+	BodyRaw      json.RawMessage `json:"body_raw,omitempty"`
+	ResponseBody json.RawMessage `json:"response_body,omitempty"`
+	RedactedAt   *string         `json:"redacted_at,omitempty"` // Set when an erasure pseudonymized the entry
+	PrevHash     *string         `json:"prev_hash,omitempty"`   // Hash chain link; unset for rows written before the chain
+	ContentHash  *string         `json:"content_hash,omitempty"`
+	RowHash      *string         `json:"row_hash,omitempty"`
+}
+
+// RelatedAuditLogs are the entries around one audit log that share its session, chat
+// history or trace, oldest first; a list is nil when the entry has no value to match
+type RelatedAuditLogs struct {
+	Session     []AuditLog `json:"session"`
+	ChatHistory []AuditLog `json:"chat_history"`
+	Trace       []AuditLog `json:"trace"`
+}
+
+// AuditLogChange is the stored change capture of a single mutating request
+type AuditLogChange struct {
+	ID           int             `json:"id"`
+	UserID       *string         `json:"user_id,omitempty"`
+	HTTPMethod   string          `json:"http_method"`
+	EndpointPath string          `json:"endpoint_path"`
+	ResourceType *string         `json:"resource_type,omitempty"`
+	ResourceID   *string         `json:"resource_id,omitempty"`
+	CreatedAt    string          `json:"created_at"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	Patch        json.RawMessage `json:"patch"`
+}
+
+// IsMutatingMethod reports whether before/after snapshots may be sent for an HTTP method
+func IsMutatingMethod(httpMethod string) bool {
+	switch strings.ToUpper(httpMethod) {
+	case "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// Actor types accepted in AuditLog.ActorType
+const (
+	ActorTypeUser    = "user"
+	ActorTypeService = "service"
+	ActorTypeSystem  = "system"
+)
+
+// Outcomes accepted in AuditLog.Outcome
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+)
+
+// IsValidActorType reports whether actorType is one of the known actor types
+func IsValidActorType(actorType string) bool {
+	switch actorType {
+	case ActorTypeUser, ActorTypeService, ActorTypeSystem:
+		return true
+	}
+	return false
+}
+
+// IsValidOutcome reports whether outcome is one of the known outcomes
+func IsValidOutcome(outcome string) bool {
+	switch outcome {
+	case OutcomeSuccess, OutcomeFailure, OutcomeDenied, OutcomeError:
+		return true
+	}
+	return false
+}
+
+// OutcomeFromStatusCode derives an outcome from an HTTP status code
+// Used when the producer does not send an explicit outcome
+func OutcomeFromStatusCode(statusCode int) string {
+	switch {
+	case statusCode == 401 || statusCode == 403:
+		return OutcomeDenied
+	case statusCode >= 500:
+		return OutcomeError
+	case statusCode >= 400:
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// actionDateLayouts are the action_date formats accepted on ingest
+// Values without a zone are taken as UTC
+var actionDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ParseActionDate parses an action_date value
+func ParseActionDate(value string) (time.Time, error) {
+	for _, layout := range actionDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid action_date %q", value)
+}
+
+// IsValidIPAddress reports whether ip is an address or network the INET column accepts
+func IsValidIPAddress(ip string) bool {
+	if strings.Contains(ip, "/") {
+		prefix, err := netip.ParsePrefix(ip)
+		return err == nil && prefix.Addr().Zone() == ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Zone() == ""
 }
 
 // CreateAuditLogsRequest represents the request body for creating audit logs
@@ -30,12 +173,19 @@ type CreateAuditLogsRequest struct {
 }
 
 // AuditLogDatastore interface for database operations
+// Every method runs its queries under ctx, so cancelling it cancels the query
 type AuditLogDatastore interface {
 	// Write operations
-	BatchInsertAuditLogs(logs []AuditLog) error
-	
+	BatchInsertAuditLogs(ctx context.Context, logs []AuditLog) error
+
 	// Read operations
-	GetAuditLogs(userID *string, action *string, limit int) ([]AuditLog, error)
-	GetDistinctActions() ([]string, error)
-}
+	GetAuditLogs(ctx context.Context, f filter.Filter, cursor *Cursor, limit int) (*Page, error)
+	GetDistinctActions(ctx context.Context) ([]string, error)
+	GetResourceHistory(ctx context.Context, resourceType string, resourceID string, cursor *Cursor, limit int) (*Page, error)
+	GetAuditLogChange(ctx context.Context, id int) (*AuditLogChange, error)
+	GetAuditLog(ctx context.Context, id int) (*AuditLogRecord, error)
+	GetRelatedAuditLogs(ctx context.Context, entry *AuditLogRecord, limit int) (*RelatedAuditLogs, error)
 
+	// Integrity
+	VerifyChain(ctx context.Context, from *time.Time, to *time.Time) (*chain.VerifyResult, error)
+}
@@ -0,0 +1,166 @@
+package chain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// GenesisHash is the prev_hash of the first row in the chain
+var GenesisHash = strings.Repeat("0", 64)
+
+// LockKey is the transaction advisory lock that serializes chain appends
+// across goroutines and service instances
+const LockKey int64 = 7263842002
+
+// Entry holds the hashed content of one audit_logs row, normalized so the value
+// computed at insert time can be recomputed from what the database returns
+// JSON columns hold JSON text, ResponseTime holds the NUMERIC(10,3) text form
+type Entry struct {
+	ID             int64
+	CreatedAt      time.Time
+	UserID         *string
+	Severity       string
+	EndpointPath   string
+	SessionID      *string
+	Action         *string
+	ActionDate     *time.Time
+	Count          *int64
+	HTTPMethod     string
+	StatusCode     int64
+	ResponseTime   string
+	IPAddress      *string
+	UserAgent      *string
+	ChatHistoryID  *int64
+	InsightsID     *int64
+	TokensUsed     *int64
+	QueryRaw       *string
+	BodyRaw        *string
+	ResponseBody   *string
+	ResourceType   *string
+	ResourceID     *string
+	Outcome        *string
+	ActorType      *string
+	ImpersonatedBy *string
+	TenantID       *string
+	TraceID        *string
+	Before         *string
+	After          *string
+	ChangeDiff     *string
+}
+
+// Canonical returns the canonical JSON form of the entry
+// Keys are sorted and NULL columns are omitted, so columns added to audit_logs
+// later do not change the hash of rows written before they existed
+func (e Entry) Canonical() []byte {
+	fields := map[string]interface{}{
+		"id":                    e.ID,
+		"created_at":            FormatTime(e.CreatedAt),
+		"severity":              e.Severity,
+		"endpoint_path":         e.EndpointPath,
+		"http_method":           e.HTTPMethod,
+		"status_code":           e.StatusCode,
+		"response_time_seconds": e.ResponseTime,
+	}
+	putString(fields, "user_id", e.UserID)
+	putString(fields, "session_id", e.SessionID)
+	putString(fields, "action", e.Action)
+	putString(fields, "user_agent", e.UserAgent)
+	putString(fields, "resource_type", e.ResourceType)
+	putString(fields, "resource_id", e.ResourceID)
+	putString(fields, "outcome", e.Outcome)
+	putString(fields, "actor_type", e.ActorType)
+	putString(fields, "impersonated_by", e.ImpersonatedBy)
+	putString(fields, "tenant_id", e.TenantID)
+	putString(fields, "trace_id", e.TraceID)
+	putInt(fields, "count", e.Count)
+	putInt(fields, "chat_history_id", e.ChatHistoryID)
+	putInt(fields, "insights_id", e.InsightsID)
+	putInt(fields, "tokens_used", e.TokensUsed)
+	putJSON(fields, "query_raw", e.QueryRaw)
+	putJSON(fields, "body_raw", e.BodyRaw)
+	putJSON(fields, "response_body", e.ResponseBody)
+	putJSON(fields, "before_snapshot", e.Before)
+	putJSON(fields, "after_snapshot", e.After)
+	putJSON(fields, "change_diff", e.ChangeDiff)
+	if e.ActionDate != nil {
+		fields["action_date"] = FormatTime(*e.ActionDate)
+	}
+	if e.IPAddress != nil {
+		fields["ip_address"] = NormalizeIP(*e.IPAddress)
+	}
+
+	canonical, _ := json.Marshal(fields)
+	return canonical
+}
+
+// ContentHash is the hex sha256 of the canonical entry
+func (e Entry) ContentHash() string {
+	sum := sha256.Sum256(e.Canonical())
+	return hex.EncodeToString(sum[:])
+}
+
+// RowHash links a row to its predecessor: sha256(prev_hash || content_hash)
+func RowHash(prevHash string, contentHash string) string {
+	sum := sha256.Sum256([]byte(prevHash + contentHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatTime is the canonical timestamp form (UTC, microsecond precision like Postgres)
+func FormatTime(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format("2006-01-02T15:04:05.000000Z")
+}
+
+// NormalizeIP returns the canonical text form of an address or network
+// Values that do not parse are returned unchanged
+func NormalizeIP(ip string) string {
+	if strings.Contains(ip, "/") {
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			if prefix.IsSingleIP() {
+				return prefix.Addr().String()
+			}
+			return prefix.String()
+		}
+		return ip
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.String()
+	}
+	return ip
+}
+
+// CanonicalJSON re-encodes JSON text with sorted keys and normalized numbers/escapes
+// JSONB does not keep the original formatting, so both the insert and the verify
+// side hash this form. Text that is not valid JSON is returned unchanged.
+func CanonicalJSON(text string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return text
+	}
+	return string(canonical)
+}
+
+func putString(fields map[string]interface{}, key string, value *string) {
+	if value != nil {
+		fields[key] = *value
+	}
+}
+
+func putInt(fields map[string]interface{}, key string, value *int64) {
+	if value != nil {
+		fields[key] = *value
+	}
+}
+
+func putJSON(fields map[string]interface{}, key string, value *string) {
+	if value != nil {
+		fields[key] = json.RawMessage(CanonicalJSON(*value))
+	}
+}
@@ -0,0 +1,139 @@
+package chain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanonical(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int64) *int64 { return &n }
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.FixedZone("UTC+2", 2*60*60))
+	base := Entry{
+		ID:           1,
+		CreatedAt:    createdAt,
+		Severity:     "LOW",
+		EndpointPath: "/api/v1/goals",
+		HTTPMethod:   "GET",
+		StatusCode:   200,
+		ResponseTime: "0.250",
+	}
+	const baseJSON = `{"created_at":"2026-03-01T10:00:00.123456Z","endpoint_path":"/api/v1/goals","http_method":"GET","id":1,"response_time_seconds":"0.250","severity":"LOW","status_code":200}`
+
+	tests := []struct {
+		name  string
+		entry func(e *Entry)
+		want  string
+	}{
+		{
+			name: "required columns only, in UTC with microseconds",
+			want: baseJSON,
+		},
+		{
+			name: "optional columns are added in key order",
+			entry: func(e *Entry) {
+				e.UserID = str("u1")
+				e.TokensUsed = num(42)
+				e.IPAddress = str("10.0.0.1/32")
+			},
+			want: `{"created_at":"2026-03-01T10:00:00.123456Z","endpoint_path":"/api/v1/goals","http_method":"GET","id":1,"ip_address":"10.0.0.1","response_time_seconds":"0.250","severity":"LOW","status_code":200,"tokens_used":42,"user_id":"u1"}`,
+		},
+		{
+			name: "json columns are embedded in canonical form",
+			entry: func(e *Entry) {
+				e.BodyRaw = str(`{ "b": 1.0, "a": "A" }`)
+				e.QueryRaw = str(`"plain"`)
+			},
+			want: `{"body_raw":{"a":"A","b":1},"created_at":"2026-03-01T10:00:00.123456Z","endpoint_path":"/api/v1/goals","http_method":"GET","id":1,"query_raw":"plain","response_time_seconds":"0.250","severity":"LOW","status_code":200}`,
+		},
+		{
+			name: "empty strings are kept, unlike NULLs",
+			entry: func(e *Entry) {
+				e.TraceID = str("")
+			},
+			want: `{"created_at":"2026-03-01T10:00:00.123456Z","endpoint_path":"/api/v1/goals","http_method":"GET","id":1,"response_time_seconds":"0.250","severity":"LOW","status_code":200,"trace_id":""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := base
+			if tt.entry != nil {
+				tt.entry(&entry)
+			}
+			if got := string(entry.Canonical()); got != tt.want {
+				t.Errorf("Canonical() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := base.ContentHash(), "0d5a35eff8187f605877a031b83aed3c3a682bf565b67fd2ad145d67c25dee8f"; got != want {
+		t.Errorf("ContentHash() = %s, want %s", got, want)
+	}
+}
+
+func TestRowHash(t *testing.T) {
+	if got, want := RowHash(GenesisHash, GenesisHash), "45725791c47b32618cc57b88343e2bceec3b0a01b83bc97d144a2cbc11a20c3d"; got != want {
+		t.Errorf("RowHash(genesis, genesis) = %s, want %s", got, want)
+	}
+	a, b := RowHash(GenesisHash, "a"), RowHash(GenesisHash, "b")
+	if RowHash(a, b) == RowHash(b, a) {
+		t.Error("RowHash() does not depend on the order of its inputs")
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want string
+	}{
+		{time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), "2026-03-01T10:00:00.000000Z"},
+		{time.Date(2026, 3, 1, 10, 0, 0, 999999999, time.UTC), "2026-03-01T10:00:00.999999Z"},
+		{time.Date(2026, 3, 1, 1, 0, 0, 1000, time.FixedZone("UTC-5", -5*60*60)), "2026-03-01T06:00:00.000001Z"},
+	}
+	for _, tt := range tests {
+		if got := FormatTime(tt.in); got != tt.want {
+			t.Errorf("FormatTime(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"192.168.0.1", "192.168.0.1"},
+		{"192.168.0.1/32", "192.168.0.1"},
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+		{"2001:db8::1/128", "2001:db8::1"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"unknown", "unknown"},
+		{"10.0.0.1/99", "10.0.0.1/99"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeIP(tt.in); got != tt.want {
+			t.Errorf("NormalizeIP(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"b": 2, "a": 1}`, `{"a":1,"b":2}`},
+		{`{"n": 1.50, "e": 1e3}`, `{"e":1000,"n":1.5}`},
+		{`["é", "<tag>"]`, `["é","\u003ctag\u003e"]`},
+		{`{"nested": {"z": null, "y": [true, false]}}`, `{"nested":{"y":[true,false],"z":null}}`},
+		{`  "text"  `, `"text"`},
+		{`{"a": 1`, `{"a": 1`},
+		{``, ``},
+	}
+	for _, tt := range tests {
+		if got := CanonicalJSON(tt.in); got != tt.want {
+			t.Errorf("CanonicalJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package chain
+
+// Link is one position in the chain: a live audit_logs row or a tombstone left
+// behind when a row was removed by retention or erasure
+type Link struct {
+	ID          int64
+	CreatedAt   string
+	PrevHash    string
+	ContentHash string
+	RowHash     string
+	Tombstone   bool
+	LastID      int64  // Set on range tombstones: the link stands for every id from ID to LastID
+	Redacted    bool   // Row was pseudonymized by a completed erasure job; its content no longer matches content_hash
+	Entry       *Entry // Nil for tombstones
+}
+
+// BrokenLink describes the first place where verification failed
+type BrokenLink struct {
+	ID        int64  `json:"id"`
+	CreatedAt string `json:"created_at,omitempty"`
+	Reason    string `json:"reason"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+}
+
+// Reasons reported in BrokenLink.Reason
+const (
+	ReasonContentMismatch = "content_mismatch" // Row content no longer matches content_hash (row edited)
+	ReasonHashMismatch    = "hash_mismatch"    // row_hash is not sha256(prev_hash || content_hash)
+	ReasonChainBreak      = "chain_break"      // prev_hash does not match the previous row (row deleted or inserted)
+	ReasonMissingTail     = "missing_tail"     // Newest rows were deleted (chain head points past the last row)
+)
+
+// VerifyResult is the outcome of verifying a range of the chain
+type VerifyResult struct {
+	From        string      `json:"from,omitempty"`
+	To          string      `json:"to,omitempty"`
+	Valid       bool        `json:"valid"`
+	Checked     int64       `json:"checked"`
+	Tombstones  int64       `json:"tombstones"`
+	Redacted    int64       `json:"redacted"`
+	FirstID     int64       `json:"first_id,omitempty"`
+	LastID      int64       `json:"last_id,omitempty"`
+	FirstBroken *BrokenLink `json:"first_broken,omitempty"`
+}
+
+// Verifier checks links fed in id order and records the first broken one
+type Verifier struct {
+	Result   VerifyResult
+	lastHash string
+	started  bool
+	anchored bool
+	skipTo   int64 // Last id covered by the latest range tombstone
+}
+
+// NewVerifier creates a verifier. When fromGenesis is true the first link must start
+// at GenesisHash; otherwise the first link's prev_hash is trusted as the anchor
+// (used when verifying a time range that starts mid-chain)
+func NewVerifier(fromGenesis bool) *Verifier {
+	v := &Verifier{Result: VerifyResult{Valid: true}}
+	if fromGenesis {
+		v.lastHash = GenesisHash
+		v.anchored = true
+	}
+	return v
+}
+
+// Check verifies one link and returns false once the chain is broken
+func (v *Verifier) Check(link Link) bool {
+	if !v.Result.Valid {
+		return false
+	}
+
+	// Retention tombstones inside a detached range are already covered by the range
+	if link.ID <= v.skipTo {
+		return true
+	}
+
+	if !v.started {
+		v.started = true
+		v.Result.FirstID = link.ID
+		if !v.anchored {
+			v.lastHash = link.PrevHash
+		}
+	}
+	v.Result.LastID = link.ID
+	if link.LastID > 0 {
+		v.Result.LastID = link.LastID
+	}
+	v.Result.Checked++
+	if link.Tombstone {
+		v.Result.Tombstones++
+	}
+	if link.Redacted {
+		v.Result.Redacted++
+	}
+
+	// Tombstones and redacted rows keep the content_hash of the original row, so only
+	// the links are checked. Callers only mark rows redacted when an erasure accounts for them
+	if link.Entry != nil && !link.Redacted {
+		if actual := link.Entry.ContentHash(); actual != link.ContentHash {
+			return v.broken(link, ReasonContentMismatch, link.ContentHash, actual)
+		}
+	}
+	if link.PrevHash != v.lastHash {
+		return v.broken(link, ReasonChainBreak, v.lastHash, link.PrevHash)
+	}
+	// A range tombstone joins the prev_hash of its first link to the row_hash of its last
+	if link.LastID > 0 {
+		v.lastHash = link.RowHash
+		v.skipTo = link.LastID
+		return true
+	}
+	if expected := RowHash(link.PrevHash, link.ContentHash); expected != link.RowHash {
+		return v.broken(link, ReasonHashMismatch, expected, link.RowHash)
+	}
+
+	v.lastHash = link.RowHash
+	return true
+}
+
+// CheckHead compares the last verified link with the recorded chain head
+// Only meaningful when the verified range runs to the end of the chain
+func (v *Verifier) CheckHead(headID int64, headHash string) bool {
+	if !v.Result.Valid || headID == 0 {
+		return v.Result.Valid
+	}
+	if v.Result.LastID != headID || v.lastHash != headHash {
+		v.Result.Valid = false
+		v.Result.FirstBroken = &BrokenLink{
+			ID:       headID,
+			Reason:   ReasonMissingTail,
+			Expected: headHash,
+			Actual:   v.lastHash,
+		}
+	}
+	return v.Result.Valid
+}
+
+func (v *Verifier) broken(link Link, reason string, expected string, actual string) bool {
+	v.Result.Valid = false
+	v.Result.FirstBroken = &BrokenLink{
+		ID:        link.ID,
+		CreatedAt: link.CreatedAt,
+		Reason:    reason,
+		Expected:  expected,
+		Actual:    actual,
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package chain
+
+import (
+	"testing"
+	"time"
+)
+
+// testChain returns n correctly chained links with ids 1..n starting at GenesisHash
+func testChain(n int) []Link {
+	var links []Link
+	prev := GenesisHash
+	for id := int64(1); id <= int64(n); id++ {
+		entry := &Entry{
+			ID:           id,
+			CreatedAt:    time.Date(2026, 3, 1, 10, 0, int(id), 0, time.UTC),
+			Severity:     "LOW",
+			EndpointPath: "/api/v1/goals",
+			HTTPMethod:   "GET",
+			StatusCode:   200,
+			ResponseTime: "0.100",
+		}
+		content := entry.ContentHash()
+		row := RowHash(prev, content)
+		links = append(links, Link{
+			ID:          id,
+			CreatedAt:   FormatTime(entry.CreatedAt),
+			PrevHash:    prev,
+			ContentHash: content,
+			RowHash:     row,
+			Entry:       entry,
+		})
+		prev = row
+	}
+	return links
+}
+
+// tombstone turns a link into the retention tombstone left when its row was deleted
+func tombstone(link Link) Link {
+	link.Entry = nil
+	link.Tombstone = true
+	return link
+}
+
+// rangeLink joins links[first] to links[last] like a detached partition's chain range
+func rangeLink(links []Link, first int, last int) Link {
+	return Link{
+		ID:        links[first].ID,
+		LastID:    links[last].ID,
+		CreatedAt: links[first].CreatedAt,
+		PrevHash:  links[first].PrevHash,
+		RowHash:   links[last].RowHash,
+		Tombstone: true,
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	// orig is an untouched copy of the test chain for building expected hashes
+	orig := testChain(5)
+	tests := []struct {
+		name        string
+		fromGenesis bool
+		links       func(c []Link) []Link
+		want        VerifyResult
+	}{
+		{
+			name:        "intact chain",
+			fromGenesis: true,
+			links:       func(c []Link) []Link { return c },
+			want:        VerifyResult{Valid: true, Checked: 5, FirstID: 1, LastID: 5},
+		},
+		{
+			name:  "range starting mid-chain is anchored on its first link",
+			links: func(c []Link) []Link { return c[2:] },
+			want:  VerifyResult{Valid: true, Checked: 3, FirstID: 3, LastID: 5},
+		},
+		{
+			name:        "range starting mid-chain with fromGenesis",
+			fromGenesis: true,
+			links:       func(c []Link) []Link { return c[2:] },
+			want: VerifyResult{Checked: 1, FirstID: 3, LastID: 3, FirstBroken: &BrokenLink{
+				ID: 3, Reason: ReasonChainBreak, Expected: GenesisHash, Actual: orig[1].RowHash,
+			}},
+		},
+		{
+			name:        "deleted row",
+			fromGenesis: true,
+			links:       func(c []Link) []Link { return append(c[:2:2], c[3:]...) },
+			want: VerifyResult{Checked: 3, FirstID: 1, LastID: 4, FirstBroken: &BrokenLink{
+				ID: 4, Reason: ReasonChainBreak, Expected: orig[1].RowHash, Actual: orig[2].RowHash,
+			}},
+		},
+		{
+			name:        "edited row",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				c[1].Entry.StatusCode = 500
+				return c
+			},
+			want: VerifyResult{Checked: 2, FirstID: 1, LastID: 2, FirstBroken: &BrokenLink{
+				ID: 2, Reason: ReasonContentMismatch, Expected: orig[1].ContentHash, Actual: edited(orig[1]),
+			}},
+		},
+		{
+			name:        "redacted row keeps its links",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				c[1].Entry.StatusCode = 500
+				c[1].Redacted = true
+				return c
+			},
+			want: VerifyResult{Valid: true, Checked: 5, Redacted: 1, FirstID: 1, LastID: 5},
+		},
+		{
+			name:        "rewritten row hash",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				c[2].RowHash = GenesisHash
+				return c
+			},
+			want: VerifyResult{Checked: 3, FirstID: 1, LastID: 3, FirstBroken: &BrokenLink{
+				ID: 3, Reason: ReasonHashMismatch, Expected: orig[2].RowHash, Actual: GenesisHash,
+			}},
+		},
+		{
+			name:        "retention tombstones",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				c[0], c[1] = tombstone(c[0]), tombstone(c[1])
+				return c
+			},
+			want: VerifyResult{Valid: true, Checked: 5, Tombstones: 2, FirstID: 1, LastID: 5},
+		},
+		{
+			name:        "chain range with tombstones inside",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				return []Link{rangeLink(c, 0, 2), tombstone(c[0]), tombstone(c[1]), c[3], c[4]}
+			},
+			want: VerifyResult{Valid: true, Checked: 3, Tombstones: 1, FirstID: 1, LastID: 5},
+		},
+		{
+			name:        "chain range at the end",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				return []Link{c[0], rangeLink(c, 1, 4)}
+			},
+			want: VerifyResult{Valid: true, Checked: 2, Tombstones: 1, FirstID: 1, LastID: 5},
+		},
+		{
+			name:        "chain range with a forged prev_hash",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				r := rangeLink(c, 1, 3)
+				r.PrevHash = GenesisHash
+				return []Link{c[0], r, c[4]}
+			},
+			want: VerifyResult{Checked: 2, Tombstones: 1, FirstID: 1, LastID: 4, FirstBroken: &BrokenLink{
+				ID: 2, Reason: ReasonChainBreak, Expected: orig[0].RowHash, Actual: GenesisHash,
+			}},
+		},
+		{
+			name:        "row after a chain range that skips a link",
+			fromGenesis: true,
+			links: func(c []Link) []Link {
+				return []Link{rangeLink(c, 0, 1), c[3], c[4]}
+			},
+			want: VerifyResult{Checked: 2, Tombstones: 1, FirstID: 1, LastID: 4, FirstBroken: &BrokenLink{
+				ID: 4, Reason: ReasonChainBreak, Expected: orig[1].RowHash, Actual: orig[2].RowHash,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(tt.fromGenesis)
+			for _, link := range tt.links(testChain(5)) {
+				v.Check(link)
+			}
+			got := v.Result
+			if got.FirstBroken != nil {
+				got.FirstBroken.CreatedAt = ""
+			}
+			if got.Valid != tt.want.Valid || got.Checked != tt.want.Checked || got.Tombstones != tt.want.Tombstones ||
+				got.Redacted != tt.want.Redacted || got.FirstID != tt.want.FirstID || got.LastID != tt.want.LastID {
+				t.Errorf("Result = %+v, want %+v", got, tt.want)
+			}
+			if (got.FirstBroken == nil) != (tt.want.FirstBroken == nil) ||
+				got.FirstBroken != nil && *got.FirstBroken != *tt.want.FirstBroken {
+				t.Errorf("FirstBroken = %+v, want %+v", got.FirstBroken, tt.want.FirstBroken)
+			}
+		})
+	}
+}
+
+func TestVerifierCheckHead(t *testing.T) {
+	c := testChain(5)
+	tests := []struct {
+		name     string
+		links    []Link
+		headID   int64
+		headHash string
+		want     bool
+	}{
+		{"head matches", c, 5, c[4].RowHash, true},
+		{"no head recorded", c, 0, "", true},
+		{"newest rows deleted", c[:3], 5, c[4].RowHash, false},
+		{"head hash rewritten", c, 5, GenesisHash, false},
+		{"head ends in a chain range", []Link{c[0], rangeLink(c, 1, 4)}, 5, c[4].RowHash, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(true)
+			for _, link := range tt.links {
+				v.Check(link)
+			}
+			if got := v.CheckHead(tt.headID, tt.headHash); got != tt.want {
+				t.Errorf("CheckHead() = %v, want %v", got, tt.want)
+			}
+			if !tt.want && (v.Result.FirstBroken == nil || v.Result.FirstBroken.Reason != ReasonMissingTail) {
+				t.Errorf("FirstBroken = %+v, want %s", v.Result.FirstBroken, ReasonMissingTail)
+			}
+		})
+	}
+}
+
+// edited returns the content hash of link after the edit made in the "edited row" case
+func edited(link Link) string {
+	entry := *link.Entry
+	entry.StatusCode = 500
+	return entry.ContentHash()
+}
@@ -0,0 +1,63 @@
+package auditlog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned for cursors that were not issued by this service
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in the (created_at, id) order of audit_logs
+// Pages run newest first; a cursor continues either to older rows (next) or newer rows (prev).
+// Rows are inserted with a created_at that never goes backwards, so keyset pages stay
+// stable while new rows arrive
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	Backward  bool // Continue towards newer rows (prev_cursor) instead of older ones
+}
+
+// Page is one page of audit logs, newest first
+type Page struct {
+	Results    []AuditLog `json:"results"`
+	Limit      int        `json:"limit"`
+	NextCursor *string    `json:"next_cursor"` // Older rows; null when there are none
+	PrevCursor *string    `json:"prev_cursor"` // Newer rows; null when there were none at query time
+}
+
+// cursorToken is the JSON inside the opaque cursor string
+type cursorToken struct {
+	CreatedAt string `json:"t"`
+	ID        int64  `json:"id"`
+	Backward  bool   `json:"b,omitempty"`
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c Cursor) Encode() string {
+	token, _ := json.Marshal(cursorToken{
+		CreatedAt: c.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ID:        c.ID,
+		Backward:  c.Backward,
+	})
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// DecodeCursor parses a cursor returned in next_cursor or prev_cursor
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil || token.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, token.CreatedAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: createdAt.UTC(), ID: token.ID, Backward: token.Backward}, nil
+}
@@ -0,0 +1,69 @@
+package auditlog
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"forward", Cursor{CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), ID: 42}},
+		{"backward", Cursor{CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), ID: 42, Backward: true}},
+		{"nanoseconds", Cursor{CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 123456789, time.UTC), ID: 1}},
+		{"microseconds from PostgreSQL", Cursor{CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 123456000, time.UTC), ID: 9007199254740993}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor(Encode()) error: %v", err)
+			}
+			if !got.CreatedAt.Equal(tt.cursor.CreatedAt) || got.ID != tt.cursor.ID || got.Backward != tt.cursor.Backward {
+				t.Errorf("DecodeCursor(Encode()) = %+v, want %+v", *got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestCursorEncodeNormalizesToUTC(t *testing.T) {
+	local := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	got, err := DecodeCursor(Cursor{CreatedAt: local, ID: 7}.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor error: %v", err)
+	}
+	if got.CreatedAt.Location() != time.UTC || !got.CreatedAt.Equal(local) {
+		t.Errorf("CreatedAt = %v, want %v in UTC", got.CreatedAt, local.UTC())
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(token string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(token))
+	}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"t":"2026-03-01T10:00:00Z","id":1}`))},
+		{"not JSON", encode("cursor")},
+		{"missing id", encode(`{"t":"2026-03-01T10:00:00Z"}`)},
+		{"zero id", encode(`{"t":"2026-03-01T10:00:00Z","id":0}`)},
+		{"negative id", encode(`{"t":"2026-03-01T10:00:00Z","id":-5}`)},
+		{"string id", encode(`{"t":"2026-03-01T10:00:00Z","id":"5"}`)},
+		{"missing time", encode(`{"id":5}`)},
+		{"date only", encode(`{"t":"2026-03-01","id":5}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := DecodeCursor(tt.value); err != ErrInvalidCursor {
+				t.Errorf("DecodeCursor(%q) = %+v, %v, want ErrInvalidCursor", tt.value, cursor, err)
+			}
+		})
+	}
+}
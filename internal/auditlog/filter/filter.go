@@ -0,0 +1,371 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+)
+
+// Filter is a set of conditions on audit_logs rows, all of which must hold
+// It is parsed from query parameters by Parse and shared by every read path and report
+type Filter struct {
+	From *time.Time // Inclusive
+	To   *time.Time // Exclusive
+
+	UserIDs     Set
+	SessionIDs  Set
+	TenantIDs   Set
+	TraceIDs    Set
+	Actions     Set
+	Severities  Set
+	HTTPMethods Set
+	Outcomes    Set
+
+	StatusCodes StatusCodes
+	Endpoints   Endpoints
+
+	MinResponseTime *float64 // Seconds, inclusive
+	MaxResponseTime *float64 // Seconds, inclusive
+	MinTokens       *int64   // Inclusive
+	MaxTokens       *int64   // Inclusive
+
+	JSON []PathPredicate // Conditions inside body_raw, query_raw and response_body
+}
+
+// Set is an IN-list on one column; values written as !value are excluded instead
+// Example: severity=HIGH,CRITICAL matches either, action=!login,!logout matches neither
+type Set struct {
+	Include []string
+	Exclude []string
+}
+
+// StatusRange is an inclusive range of status codes
+// 404 is {404, 404}, 500-503 is {500, 503} and the class 5xx is {500, 599}
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// StatusCodes matches status_code against ranges; a row matches when it is in any of
+// Include (or Include is empty) and in none of Exclude
+type StatusCodes struct {
+	Include []StatusRange
+	Exclude []StatusRange
+}
+
+// Endpoints matches endpoint_path against LIKE patterns built from globs and prefixes
+type Endpoints struct {
+	Include []string
+	Exclude []string
+}
+
+// setParams are the query parameters parsed into a Set, with the column each filters
+var setParams = []struct {
+	param  string
+	column string
+	set    func(f *Filter) *Set
+}{
+	{"user_id", "user_id", func(f *Filter) *Set { return &f.UserIDs }},
+	{"session_id", "session_id", func(f *Filter) *Set { return &f.SessionIDs }},
+	{"tenant_id", "tenant_id", func(f *Filter) *Set { return &f.TenantIDs }},
+	{"trace_id", "trace_id", func(f *Filter) *Set { return &f.TraceIDs }},
+	{"action", "action", func(f *Filter) *Set { return &f.Actions }},
+	{"severity", "severity", func(f *Filter) *Set { return &f.Severities }},
+	{"http_method", "http_method", func(f *Filter) *Set { return &f.HTTPMethods }},
+	{"outcome", "outcome", func(f *Filter) *Set { return &f.Outcomes }},
+}
+
+// Parse reads a Filter from query parameters
+// Every parameter may be repeated or hold a comma-separated list, and values prefixed
+// with ! are negated:
+//   - from, to: RFC 3339 timestamp or YYYY-MM-DD (to is exclusive)
+//   - user_id, session_id, tenant_id, trace_id, action, severity, http_method, outcome: exact values
+//   - status_code: codes (404), ranges (500-503) and classes (5xx)
+//   - endpoint: globs where * matches any characters and ? one (\ escapes either);
+//     endpoint_prefix: literal prefixes
+//   - min_response_time, max_response_time (seconds), min_tokens, max_tokens: inclusive bounds
+//   - json: one predicate per parameter, not split on commas, see ParsePathPredicate
+//
+// Example: status_code=5xx,!503&endpoint=/api/v1/goals/*&severity=HIGH,CRITICAL&min_tokens=1000
+func Parse(params url.Values) (Filter, error) {
+	var f Filter
+	var err error
+
+	if f.From, err = ParseTime(params.Get("from")); err != nil {
+		return f, fmt.Errorf("from: %q is not an RFC 3339 timestamp or YYYY-MM-DD date", params.Get("from"))
+	}
+	if f.To, err = ParseTime(params.Get("to")); err != nil {
+		return f, fmt.Errorf("to: %q is not an RFC 3339 timestamp or YYYY-MM-DD date", params.Get("to"))
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return f, fmt.Errorf("from must be before to")
+	}
+
+	for _, p := range setParams {
+		set := p.set(&f)
+		for _, value := range values(params, p.param) {
+			if negated, ok := strings.CutPrefix(value, "!"); ok {
+				set.Exclude = append(set.Exclude, negated)
+			} else {
+				set.Include = append(set.Include, value)
+			}
+		}
+	}
+
+	for _, value := range values(params, "status_code") {
+		negated, exclude := strings.CutPrefix(value, "!")
+		statusRange, ok := parseStatusRange(negated)
+		if !ok {
+			return f, fmt.Errorf("status_code: %q is not a status code, range or class", value)
+		}
+		if exclude {
+			f.StatusCodes.Exclude = append(f.StatusCodes.Exclude, statusRange)
+		} else {
+			f.StatusCodes.Include = append(f.StatusCodes.Include, statusRange)
+		}
+	}
+
+	for _, value := range values(params, "endpoint") {
+		negated, exclude := strings.CutPrefix(value, "!")
+		f.Endpoints.add(globPattern(negated), exclude)
+	}
+	for _, value := range values(params, "endpoint_prefix") {
+		negated, exclude := strings.CutPrefix(value, "!")
+		f.Endpoints.add(escapeLike(negated)+"%", exclude)
+	}
+
+	if f.MinResponseTime, err = parseFloat(params, "min_response_time"); err != nil {
+		return f, err
+	}
+	if f.MaxResponseTime, err = parseFloat(params, "max_response_time"); err != nil {
+		return f, err
+	}
+	if f.MinTokens, err = parseInt(params, "min_tokens"); err != nil {
+		return f, err
+	}
+	if f.MaxTokens, err = parseInt(params, "max_tokens"); err != nil {
+		return f, err
+	}
+
+	if len(params["json"]) > maxPathPredicates {
+		return f, fmt.Errorf("json: at most %d predicates are allowed", maxPathPredicates)
+	}
+	for _, value := range params["json"] {
+		predicate, err := ParsePathPredicate(value)
+		if err != nil {
+			return f, err
+		}
+		f.JSON = append(f.JSON, predicate)
+	}
+	return f, nil
+}
+
+// ParseTime parses an optional RFC 3339 timestamp or YYYY-MM-DD date
+func ParseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	parsed = parsed.UTC()
+	return &parsed, nil
+}
+
+// Where returns the filter as " AND ..." conditions for dialect whose placeholders continue
+// after args, and args extended with their values
+func (f Filter) Where(dialect database.Dialect, args []interface{}) (string, []interface{}) {
+	var where strings.Builder
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.From != nil {
+		where.WriteString(" AND created_at >= " + param(*f.From))
+	}
+	if f.To != nil {
+		where.WriteString(" AND created_at < " + param(*f.To))
+	}
+
+	for _, p := range setParams {
+		set := p.set(&f)
+		if len(set.Include) > 0 {
+			where.WriteString(" AND " + p.column + " IN (" + params(set.Include, param) + ")")
+		}
+		if len(set.Exclude) > 0 {
+			// Rows without a value are not excluded by a negation
+			where.WriteString(" AND (" + p.column + " IS NULL OR " + p.column + " NOT IN (" + params(set.Exclude, param) + "))")
+		}
+	}
+
+	if len(f.StatusCodes.Include) > 0 {
+		var ranges []string
+		for _, r := range f.StatusCodes.Include {
+			ranges = append(ranges, "status_code BETWEEN "+param(r.Min)+" AND "+param(r.Max))
+		}
+		where.WriteString(" AND (" + strings.Join(ranges, " OR ") + ")")
+	}
+	for _, r := range f.StatusCodes.Exclude {
+		where.WriteString(" AND status_code NOT BETWEEN " + param(r.Min) + " AND " + param(r.Max))
+	}
+
+	if len(f.Endpoints.Include) > 0 {
+		var patterns []string
+		for _, pattern := range f.Endpoints.Include {
+			patterns = append(patterns, "endpoint_path LIKE "+param(pattern)+` ESCAPE '\'`)
+		}
+		where.WriteString(" AND (" + strings.Join(patterns, " OR ") + ")")
+	}
+	for _, pattern := range f.Endpoints.Exclude {
+		where.WriteString(" AND endpoint_path NOT LIKE " + param(pattern) + ` ESCAPE '\'`)
+	}
+
+	if f.MinResponseTime != nil {
+		where.WriteString(" AND response_time_seconds >= " + param(*f.MinResponseTime))
+	}
+	if f.MaxResponseTime != nil {
+		where.WriteString(" AND response_time_seconds <= " + param(*f.MaxResponseTime))
+	}
+	if f.MinTokens != nil {
+		where.WriteString(" AND tokens_used >= " + param(*f.MinTokens))
+	}
+	if f.MaxTokens != nil {
+		where.WriteString(" AND tokens_used <= " + param(*f.MaxTokens))
+	}
+
+	for _, predicate := range f.JSON {
+		where.WriteString(" AND " + predicate.sql(dialect, param))
+	}
+	return where.String(), args
+}
+
+// RollupCompatible reports whether every condition of f other than its time range tests a
+// column that the hourly rollups group by (user_id, action, severity, http_method,
+// status_code and endpoint_path), so Where also applies to audit_rollups_hourly
+func (f Filter) RollupCompatible() bool {
+	return f.SessionIDs.empty() && f.TenantIDs.empty() && f.TraceIDs.empty() && f.Outcomes.empty() &&
+		f.MinResponseTime == nil && f.MaxResponseTime == nil && f.MinTokens == nil && f.MaxTokens == nil &&
+		len(f.JSON) == 0
+}
+
+// empty reports whether the set has no values
+func (s Set) empty() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0
+}
+
+// add appends a LIKE pattern to Include or Exclude
+func (e *Endpoints) add(pattern string, exclude bool) {
+	if exclude {
+		e.Exclude = append(e.Exclude, pattern)
+	} else {
+		e.Include = append(e.Include, pattern)
+	}
+}
+
+// values returns the non-empty comma-separated values of every occurrence of a parameter
+func values(params url.Values, name string) []string {
+	var result []string
+	for _, param := range params[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" && value != "!" {
+				result = append(result, value)
+			}
+		}
+	}
+	return result
+}
+
+// params binds each value and returns the comma-separated placeholders
+func params(values []string, param func(interface{}) string) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = param(value)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// parseStatusRange parses a status code, an inclusive range like 500-503 or a class like 5xx
+func parseStatusRange(value string) (StatusRange, bool) {
+	if len(value) == 3 && strings.EqualFold(value[1:], "xx") && value[0] >= '1' && value[0] <= '5' {
+		class := int(value[0]-'0') * 100
+		return StatusRange{Min: class, Max: class + 99}, true
+	}
+	if lower, upper, ok := strings.Cut(value, "-"); ok {
+		min, err := strconv.Atoi(lower)
+		if err != nil {
+			return StatusRange{}, false
+		}
+		max, err := strconv.Atoi(upper)
+		if err != nil || min > max {
+			return StatusRange{}, false
+		}
+		return StatusRange{Min: min, Max: max}, true
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return StatusRange{}, false
+	}
+	return StatusRange{Min: code, Max: code}, true
+}
+
+// globPattern turns a glob into a LIKE pattern with \ as the escape character
+// * matches any characters, ? matches one and \ makes the next character literal
+func globPattern(glob string) string {
+	var pattern strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			pattern.WriteByte('%')
+		case '?':
+			pattern.WriteByte('_')
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			pattern.WriteString(escapeLike(glob[i : i+1]))
+		default:
+			pattern.WriteString(escapeLike(string(c)))
+		}
+	}
+	return pattern.String()
+}
+
+// escapeLike makes every character of value match literally in a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// parseFloat parses an optional non-negative number parameter
+func parseFloat(params url.Values, name string) (*float64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("%s: %q is not a non-negative number", name, value)
+	}
+	return &parsed, nil
+}
+
+// parseInt parses an optional non-negative integer parameter
+func parseInt(params url.Values, name string) (*int64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("%s: %q is not a non-negative integer", name, value)
+	}
+	return &parsed, nil
+}
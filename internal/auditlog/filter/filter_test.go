@@ -0,0 +1,206 @@
+package filter
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 12, 30, 0, 0, time.UTC)
+	tokens := int64(1000)
+	responseTime := 1.5
+
+	tests := []struct {
+		name  string
+		query string
+		want  Filter
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  Filter{},
+		},
+		{
+			name:  "date and timestamp bounds",
+			query: "from=2026-01-01&to=2026-02-01T14:30:00%2B02:00",
+			want:  Filter{From: &from, To: &to},
+		},
+		{
+			name:  "comma-separated and repeated sets with negation",
+			query: "severity=HIGH,CRITICAL&action=!login&action=!logout, export",
+			want: Filter{
+				Severities: Set{Include: []string{"HIGH", "CRITICAL"}},
+				Actions:    Set{Include: []string{"export"}, Exclude: []string{"login", "logout"}},
+			},
+		},
+		{
+			name:  "empty values and a lone ! are dropped",
+			query: "user_id=,!,u1,",
+			want:  Filter{UserIDs: Set{Include: []string{"u1"}}},
+		},
+		{
+			name:  "status codes, ranges and classes",
+			query: "status_code=404,500-503,5xx,!503",
+			want: Filter{StatusCodes: StatusCodes{
+				Include: []StatusRange{{404, 404}, {500, 503}, {500, 599}},
+				Exclude: []StatusRange{{503, 503}},
+			}},
+		},
+		{
+			name:  "endpoint globs and prefixes",
+			query: `endpoint=/api/v1/goals/*&endpoint=!/api/?/x\*&endpoint_prefix=/api/v1_&endpoint_prefix=!/health`,
+			want: Filter{Endpoints: Endpoints{
+				Include: []string{`/api/v1/goals/%`, `/api/v1\_%`},
+				Exclude: []string{`/api/_/x*`, `/health%`},
+			}},
+		},
+		{
+			name:  "numeric bounds",
+			query: "min_tokens=1000&max_response_time=1.5",
+			want:  Filter{MinTokens: &tokens, MaxResponseTime: &responseTime},
+		},
+		{
+			name:  "json predicates are not split on commas",
+			query: `json=body.question~"a,b"`,
+			want: Filter{JSON: []PathPredicate{
+				{Column: "body_raw", Path: []string{"question"}, Op: "~", Kind: KindString, Value: "a,b"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid test query: %v", err)
+			}
+			got, err := Parse(params)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{"invalid from", "from=yesterday", "from:"},
+		{"invalid to", "to=2026-13-01", "to:"},
+		{"from after to", "from=2026-02-01&to=2026-01-01", "from must be before to"},
+		{"from equal to to", "from=2026-01-01&to=2026-01-01", "from must be before to"},
+		{"invalid status code", "status_code=abc", "status_code:"},
+		{"inverted status range", "status_code=503-500", "status_code:"},
+		{"invalid status class", "status_code=6xx", "status_code:"},
+		{"negative tokens", "min_tokens=-1", "min_tokens:"},
+		{"fractional tokens", "max_tokens=1.5", "max_tokens:"},
+		{"invalid response time", "min_response_time=fast", "min_response_time:"},
+		{"invalid json predicate", "json=payload.x=1", "json:"},
+		{"too many json predicates", strings.Repeat("json=body.a&", maxPathPredicates+1), "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid test query: %v", err)
+			}
+			if _, err := Parse(params); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want it to contain %q", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no conditions",
+			query:     "",
+			wantWhere: "",
+		},
+		{
+			name:      "sets with negation keep rows without a value",
+			query:     "severity=HIGH,CRITICAL&action=!login",
+			wantWhere: " AND (action IS NULL OR action NOT IN ($1)) AND severity IN ($2, $3)",
+			wantArgs:  []interface{}{"login", "HIGH", "CRITICAL"},
+		},
+		{
+			name:      "placeholders continue after existing args",
+			query:     "user_id=u1&status_code=5xx,!503",
+			args:      []interface{}{"existing"},
+			wantWhere: " AND user_id IN ($2) AND (status_code BETWEEN $3 AND $4) AND status_code NOT BETWEEN $5 AND $6",
+			wantArgs:  []interface{}{"existing", "u1", 500, 599, 503, 503},
+		},
+		{
+			name:      "endpoints and numeric bounds",
+			query:     "endpoint=/a/*,/b&endpoint_prefix=!/c&min_tokens=10&max_response_time=2",
+			wantWhere: ` AND (endpoint_path LIKE $1 ESCAPE '\' OR endpoint_path LIKE $2 ESCAPE '\') AND endpoint_path NOT LIKE $3 ESCAPE '\' AND response_time_seconds <= $4 AND tokens_used >= $5`,
+			wantArgs:  []interface{}{"/a/%", "/b", "/c%", 2.0, int64(10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid test query: %v", err)
+			}
+			f, err := Parse(params)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.query, err)
+			}
+			where, args := f.Where(database.Postgres, tt.args)
+			if where != tt.wantWhere {
+				t.Errorf("Where() = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Where() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRollupCompatible(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"from=2026-01-01&user_id=u1&action=!login&severity=HIGH&http_method=GET&status_code=5xx&endpoint=/api/*", true},
+		{"session_id=s1", false},
+		{"tenant_id=t1", false},
+		{"trace_id=abc", false},
+		{"outcome=failure", false},
+		{"min_response_time=1", false},
+		{"max_tokens=10", false},
+		{"json=body.question", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.query)
+			f, err := Parse(params)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.query, err)
+			}
+			if got := f.RollupCompatible(); got != tt.want {
+				t.Errorf("RollupCompatible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
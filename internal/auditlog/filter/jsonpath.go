@@ -0,0 +1,269 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+)
+
+// maxPathPredicates bounds the json parameters of one request
+const maxPathPredicates = 10
+
+// pathRoots maps the first segment of a path to the JSON column it reads
+var pathRoots = map[string]string{
+	"body":     "body_raw",
+	"query":    "query_raw",
+	"response": "response_body",
+}
+
+// pathOperators are the allowed operators, longest first so that >= is not read as >
+var pathOperators = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+// pathKey is the allowed form of a key in a path; keys are also quoted wherever they
+// end up in a JSON path, so they never change its structure
+var pathKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Kinds of PathPredicate values
+const (
+	KindString = "string"
+	KindNumber = "number"
+	KindBool   = "bool"
+	KindNull   = "null"
+)
+
+// PathPredicate is a condition on a value inside body_raw, query_raw or response_body
+// Example: body.question~"sprint", query.board_id=12, response.data.status=error
+type PathPredicate struct {
+	Column string   // body_raw, query_raw or response_body
+	Path   []string // Object keys from the root of the column
+	Op     string   // One of pathOperators, or "" to test that the path exists
+	Negate bool     // Only with Op "": the path must not exist
+	Kind   string   // Kind of Value: KindString, KindNumber, KindBool or KindNull
+	Value  string   // The value as text; unquoted for strings
+}
+
+// ParsePathPredicate parses <body|query|response>.<key>[.<key>...]<operator><value>
+// Operators: = and != (equality), ~ and !~ (case-insensitive substring), <, <=, >, >=
+// Values are "JSON strings", numbers, true, false, null or bare words taken as strings.
+// Unquoted numbers and booleans also match their string form, since query parameters
+// are stored as strings. A path alone tests that it exists, and !path that it does not
+func ParsePathPredicate(input string) (PathPredicate, error) {
+	var p PathPredicate
+	rest, negated := strings.CutPrefix(strings.TrimSpace(input), "!")
+
+	end := strings.IndexAny(rest, "!=~<>")
+	if end < 0 {
+		end = len(rest)
+	}
+	segments := strings.Split(rest[:end], ".")
+	column, ok := pathRoots[segments[0]]
+	if !ok || len(segments) < 2 {
+		return p, fmt.Errorf("json: %q must start with body., query. or response.", input)
+	}
+	for _, key := range segments[1:] {
+		if !pathKey.MatchString(key) {
+			return p, fmt.Errorf("json: %q has an invalid key %q (letters, digits, _ and - only)", input, key)
+		}
+	}
+	p.Column = column
+	p.Path = segments[1:]
+
+	rest = rest[end:]
+	if rest == "" {
+		p.Negate = negated
+		return p, nil
+	}
+	if negated {
+		return p, fmt.Errorf("json: %q negates a comparison; use != or !~ instead", input)
+	}
+	for _, op := range pathOperators {
+		if value, found := strings.CutPrefix(rest, op); found {
+			p.Op = op
+			rest = strings.TrimSpace(value)
+			break
+		}
+	}
+	if p.Op == "" {
+		return p, fmt.Errorf("json: %q has no valid operator (=, !=, ~, !~, <, <=, >, >=)", input)
+	}
+
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		if err := json.Unmarshal([]byte(rest), &p.Value); err != nil {
+			return p, fmt.Errorf("json: %q has an invalid quoted value", input)
+		}
+		p.Kind = KindString
+	case rest == "":
+		return p, fmt.Errorf("json: %q has no value", input)
+	case rest == "true" || rest == "false":
+		p.Kind, p.Value = KindBool, rest
+	case rest == "null":
+		p.Kind, p.Value = KindNull, rest
+	case strings.ContainsAny(rest[:1], "!=~<>"):
+		return p, fmt.Errorf("json: %q has a value starting with an operator; quote it if that is intended", input)
+	default:
+		p.Kind, p.Value = KindString, rest
+		if _, err := strconv.ParseFloat(rest, 64); err == nil && json.Valid([]byte(rest)) {
+			p.Kind = KindNumber
+		}
+	}
+
+	switch p.Op {
+	case "<", "<=", ">", ">=":
+		if p.Kind != KindString && p.Kind != KindNumber {
+			return p, fmt.Errorf("json: %q compares with a value that is not a string or number", input)
+		}
+	case "~", "!~":
+		if p.Kind == KindNull {
+			return p, fmt.Errorf("json: %q matches a substring of null", input)
+		}
+	}
+	return p, nil
+}
+
+// sql renders the predicate for dialect, binding every key and value with param
+func (p PathPredicate) sql(dialect database.Dialect, param func(interface{}) string) string {
+	if dialect == database.SQLite {
+		return p.sqlite(param)
+	}
+	return p.postgres(param)
+}
+
+// postgres renders the predicate with @> (equality, which the GIN index on body_raw
+// serves), -> and ->> (existence and substrings) or jsonb_path_exists (comparisons)
+func (p PathPredicate) postgres(param func(interface{}) string) string {
+	switch p.Op {
+	case "":
+		if p.Negate {
+			return p.arrows(param, "->") + " IS NULL"
+		}
+		return p.arrows(param, "->") + " IS NOT NULL"
+	case "=", "!=":
+		// The value may also be an element of an array, as repeated query parameters are
+		literals := []string{p.literal()}
+		if p.Kind == KindNumber || p.Kind == KindBool {
+			quoted, _ := json.Marshal(p.Value)
+			literals = append(literals, string(quoted))
+		}
+		var matches []string
+		for _, literal := range literals {
+			matches = append(matches,
+				p.Column+" @> "+param(p.document(literal))+"::jsonb",
+				p.Column+" @> "+param(p.document("["+literal+"]"))+"::jsonb")
+		}
+		condition := "(" + strings.Join(matches, " OR ") + ")"
+		if p.Op == "!=" {
+			return "NOT COALESCE(" + condition + ", false)"
+		}
+		return condition
+	case "~", "!~":
+		condition := p.arrows(param, "->>") + " ILIKE " + param("%"+escapeLike(p.Value)+"%") + ` ESCAPE '\'`
+		if p.Op == "!~" {
+			return "NOT COALESCE(" + condition + ", false)"
+		}
+		return condition
+	}
+
+	// Numbers also compare with numeric strings, which .double() converts; silent
+	// errors make values of other types simply not match
+	item := "@"
+	if p.Kind == KindNumber {
+		item = "@.double()"
+	}
+	path := p.jsonPath() + " ? (" + item + " " + p.Op + " $v)"
+	vars := `{"v": ` + p.literal() + `}`
+	return "jsonb_path_exists(" + p.Column + ", " + param(path) + "::jsonpath, " + param(vars) + "::jsonb, true)"
+}
+
+// sqlite renders the predicate with json_type, json_extract and json_each
+func (p PathPredicate) sqlite(param func(interface{}) string) string {
+	path := param(p.jsonPath())
+
+	switch p.Op {
+	case "":
+		if p.Negate {
+			return "json_type(" + p.Column + ", " + path + ") IS NULL"
+		}
+		return "json_type(" + p.Column + ", " + path + ") IS NOT NULL"
+	case "~", "!~":
+		condition := "json_extract(" + p.Column + ", " + path + ") LIKE " + param("%"+escapeLike(p.Value)+"%") + ` ESCAPE '\'`
+		if p.Op == "!~" {
+			return "NOT COALESCE(" + condition + ", false)"
+		}
+		return condition
+	}
+
+	// json_each yields a scalar at the path itself, or each element of an array there;
+	// members of an object have text keys and are skipped
+	var match string
+	op := p.Op
+	if op == "!=" {
+		op = "="
+	}
+	switch p.Kind {
+	case KindNull:
+		match = "type = 'null'"
+	case KindBool:
+		match = "(type = " + param(p.Value) + " OR (type = 'text' AND value = " + param(p.Value) + "))"
+	case KindNumber:
+		// Strings count as numbers when they survive a round trip, as with .double()
+		number, _ := strconv.ParseFloat(p.Value, 64)
+		match = "(type IN ('integer', 'real') AND value " + op + " " + param(number) + ")"
+		if op == "=" {
+			match = "(" + match + " OR (type = 'text' AND value = " + param(p.Value) + "))"
+		} else {
+			match = "(" + match + " OR (type = 'text' AND CAST(CAST(value AS NUMERIC) AS TEXT) = value AND CAST(value AS NUMERIC) " + op + " " + param(number) + "))"
+		}
+	default:
+		match = "(type = 'text' AND value " + op + " " + param(p.Value) + ")"
+	}
+	condition := "EXISTS (SELECT 1 FROM json_each(" + p.Column + ", " + path + ") WHERE typeof(key) != 'text' AND " + match + ")"
+	if p.Op == "!=" {
+		return "NOT " + condition
+	}
+	return condition
+}
+
+// arrows renders column -> key ... with the last step using last (-> or ->>)
+func (p PathPredicate) arrows(param func(interface{}) string, last string) string {
+	expr := p.Column
+	for i, key := range p.Path {
+		arrow := "->"
+		if i == len(p.Path)-1 {
+			arrow = last
+		}
+		expr += " " + arrow + " " + param(key) + "::text"
+	}
+	return "(" + expr + ")"
+}
+
+// jsonPath renders the path as $."key"...; keys match pathKey, so quoting is enough
+func (p PathPredicate) jsonPath() string {
+	path := "$"
+	for _, key := range p.Path {
+		path += `."` + key + `"`
+	}
+	return path
+}
+
+// literal returns the value as JSON
+func (p PathPredicate) literal() string {
+	if p.Kind == KindString {
+		quoted, _ := json.Marshal(p.Value)
+		return string(quoted)
+	}
+	return p.Value
+}
+
+// document nests a JSON value under the path, for containment with @>
+func (p PathPredicate) document(value string) string {
+	for i := len(p.Path) - 1; i >= 0; i-- {
+		key, _ := json.Marshal(p.Path[i])
+		value = "{" + string(key) + ": " + value + "}"
+	}
+	return value
+}
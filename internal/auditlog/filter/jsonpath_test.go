@@ -0,0 +1,248 @@
+package filter
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	_ "modernc.org/sqlite"
+)
+
+func TestParsePathPredicate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  PathPredicate
+	}{
+		{
+			input: "body.question",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"question"}},
+		},
+		{
+			input: "!query.board_id",
+			want:  PathPredicate{Column: "query_raw", Path: []string{"board_id"}, Negate: true},
+		},
+		{
+			input: "response.data.status=error",
+			want:  PathPredicate{Column: "response_body", Path: []string{"data", "status"}, Op: "=", Kind: KindString, Value: "error"},
+		},
+		{
+			input: `body.question~"sprint review"`,
+			want:  PathPredicate{Column: "body_raw", Path: []string{"question"}, Op: "~", Kind: KindString, Value: "sprint review"},
+		},
+		{
+			input: `body.note!~"a=b"`,
+			want:  PathPredicate{Column: "body_raw", Path: []string{"note"}, Op: "!~", Kind: KindString, Value: "a=b"},
+		},
+		{
+			input: "query.board_id=12",
+			want:  PathPredicate{Column: "query_raw", Path: []string{"board_id"}, Op: "=", Kind: KindNumber, Value: "12"},
+		},
+		{
+			input: "body.score>=-1.5e2",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"score"}, Op: ">=", Kind: KindNumber, Value: "-1.5e2"},
+		},
+		{
+			input: "body.count<10",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"count"}, Op: "<", Kind: KindNumber, Value: "10"},
+		},
+		{
+			input: "body.enabled!=true",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"enabled"}, Op: "!=", Kind: KindBool, Value: "true"},
+		},
+		{
+			input: "body.parent=null",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"parent"}, Op: "=", Kind: KindNull, Value: "null"},
+		},
+		{
+			// Go accepts forms JSON does not, so these stay strings
+			input: "body.id=0x1F",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"id"}, Op: "=", Kind: KindString, Value: "0x1F"},
+		},
+		{
+			input: "body.version=Inf",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"version"}, Op: "=", Kind: KindString, Value: "Inf"},
+		},
+		{
+			input: " body.user-name_2=alice ",
+			want:  PathPredicate{Column: "body_raw", Path: []string{"user-name_2"}, Op: "=", Kind: KindString, Value: "alice"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePathPredicate(tt.input)
+			if err != nil {
+				t.Fatalf("ParsePathPredicate(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePathPredicate(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePathPredicateErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"payload.x=1", "must start with"},
+		{"body", "must start with"},
+		{"body.=1", "invalid key"},
+		{"body.a b=1", "invalid key"},
+		{`body."a"=1`, "invalid key"},
+		{"body.a..b", "invalid key"},
+		{"!body.a=1", "negates a comparison"},
+		{"body.a!1", "no valid operator"},
+		{"body.a=", "has no value"},
+		{"body.a==1", "starting with an operator"},
+		{`body.a="unterminated`, "invalid quoted value"},
+		{"body.a>true", "not a string or number"},
+		{"body.a<=null", "not a string or number"},
+		{"body.a~null", "substring of null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if _, err := ParsePathPredicate(tt.input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePathPredicate(%q) error = %v, want it to contain %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPathPredicatePostgres(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			input:    "body.question",
+			wantSQL:  "(body_raw -> $1::text) IS NOT NULL",
+			wantArgs: []interface{}{"question"},
+		},
+		{
+			input:    "!response.data.error",
+			wantSQL:  "(response_body -> $1::text -> $2::text) IS NULL",
+			wantArgs: []interface{}{"data", "error"},
+		},
+		{
+			input:    "body.status=done",
+			wantSQL:  "(body_raw @> $1::jsonb OR body_raw @> $2::jsonb)",
+			wantArgs: []interface{}{`{"status": "done"}`, `{"status": ["done"]}`},
+		},
+		{
+			input:   "query.board_id!=12",
+			wantSQL: "NOT COALESCE((query_raw @> $1::jsonb OR query_raw @> $2::jsonb OR query_raw @> $3::jsonb OR query_raw @> $4::jsonb), false)",
+			wantArgs: []interface{}{
+				`{"board_id": 12}`, `{"board_id": [12]}`, `{"board_id": "12"}`, `{"board_id": ["12"]}`,
+			},
+		},
+		{
+			input:    `body.question~"50%_off"`,
+			wantSQL:  `(body_raw ->> $1::text) ILIKE $2 ESCAPE '\'`,
+			wantArgs: []interface{}{"question", `%50\%\_off%`},
+		},
+		{
+			input:    "body.score>2",
+			wantSQL:  "jsonb_path_exists(body_raw, $1::jsonpath, $2::jsonb, true)",
+			wantArgs: []interface{}{`$."score" ? (@.double() > $v)`, `{"v": 2}`},
+		},
+		{
+			input:    "body.name<=m",
+			wantSQL:  "jsonb_path_exists(body_raw, $1::jsonpath, $2::jsonb, true)",
+			wantArgs: []interface{}{`$."name" ? (@ <= $v)`, `{"v": "m"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := ParsePathPredicate(tt.input)
+			if err != nil {
+				t.Fatalf("ParsePathPredicate(%q) error: %v", tt.input, err)
+			}
+			where, args := Filter{JSON: []PathPredicate{p}}.Where(database.Postgres, nil)
+			if want := " AND " + tt.wantSQL; where != want {
+				t.Errorf("Where() = %q, want %q", where, want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Where() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestPathPredicateSQLite runs the SQLite rendering of each predicate against sample rows
+func TestPathPredicateSQLite(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	rows := []string{
+		`{"status": "done", "score": 3, "tags": ["a", "b"], "enabled": true, "note": "50% off"}`,
+		`{"status": "open", "score": "10", "tags": "a", "enabled": "false", "parent": null}`,
+		`{"status": ["done", "open"], "score": 1.5, "nested": {"status": "done"}}`,
+		`{"other": 1}`,
+	}
+	if _, err := conn.Exec(`CREATE TABLE audit_logs (id INTEGER PRIMARY KEY, body_raw TEXT)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i, row := range rows {
+		if _, err := conn.Exec(`INSERT INTO audit_logs (id, body_raw) VALUES (?, ?)`, i+1, row); err != nil {
+			t.Fatalf("failed to insert row: %v", err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"body.status", []int{1, 2, 3}},
+		{"!body.status", []int{4}},
+		{"body.parent", []int{2}},
+		{"body.status=done", []int{1, 3}},
+		{"body.status!=done", []int{2, 4}},
+		{"body.nested.status=done", []int{3}},
+		{"body.tags=a", []int{1, 2}},
+		{"body.score=10", []int{2}},
+		{"body.score>2", []int{1, 2}},
+		{"body.score<=3", []int{1, 3}},
+		{"body.enabled=true", []int{1}},
+		{"body.enabled=false", []int{2}},
+		{"body.parent=null", []int{2}},
+		{`body.note~"50%"`, []int{1}},
+		{`body.note~"5_"`, nil},
+		// Substrings match the JSON text of arrays too, as ->> does on PostgreSQL
+		{"body.status~DON", []int{1, 3}},
+		{"body.note!~off", []int{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := ParsePathPredicate(tt.input)
+			if err != nil {
+				t.Fatalf("ParsePathPredicate(%q) error: %v", tt.input, err)
+			}
+			where, args := Filter{JSON: []PathPredicate{p}}.Where(database.SQLite, nil)
+			result, err := conn.Query(`SELECT id FROM audit_logs WHERE 1=1`+where+` ORDER BY id`, args...)
+			if err != nil {
+				t.Fatalf("query %q failed: %v", where, err)
+			}
+			defer result.Close()
+			var got []int
+			for result.Next() {
+				var id int
+				if err := result.Scan(&id); err != nil {
+					t.Fatalf("failed to scan id: %v", err)
+				}
+				got = append(got, id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s matched rows %v, want %v (where %s)", tt.input, got, tt.want, strconv.Quote(where))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+)
+
+// pageQuery appends the keyset condition, order and limit of a page to query
+// One row more than limit is fetched to tell whether another page follows
+func pageQuery(query string, args []interface{}, cursor *auditlog.Cursor, limit int) (string, []interface{}) {
+	argIndex := len(args) + 1
+	order := "DESC"
+	if cursor != nil {
+		comparison := "<"
+		if cursor.Backward {
+			comparison = ">"
+			order = "ASC"
+		}
+		query += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", comparison, argIndex, argIndex+1)
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		argIndex += 2
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT $%d", order, order, argIndex)
+	args = append(args, limit+1)
+	return query, args
+}
+
+// pageRow is a row fetched with pageQuery and its exact created_at, which
+// AuditLog.CreatedAt only keeps to the second
+type pageRow struct {
+	log       auditlog.AuditLog
+	createdAt time.Time
+}
+
+// newPage builds a newest-first page from the rows fetched with pageQuery
+func newPage(rows []pageRow, cursor *auditlog.Cursor, limit int) *auditlog.Page {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &auditlog.Page{Results: make([]auditlog.AuditLog, 0, len(rows)), Limit: limit}
+	for _, row := range rows {
+		page.Results = append(page.Results, row.log)
+	}
+
+	if len(rows) == 0 {
+		// Nothing left in this direction; the way back starts at the cursor itself
+		if cursor != nil {
+			back := auditlog.Cursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID, Backward: !backward}.Encode()
+			if backward {
+				page.NextCursor = &back
+			} else {
+				page.PrevCursor = &back
+			}
+		}
+		return page
+	}
+
+	// Older rows remain when more were fetched going forward, or when we came from them
+	if more || backward {
+		oldest := rows[len(rows)-1]
+		next := auditlog.Cursor{CreatedAt: oldest.createdAt, ID: int64(oldest.log.ID)}.Encode()
+		page.NextCursor = &next
+	}
+	// Newer rows remain when more were fetched going backward, or when we came from them
+	if (backward && more) || (!backward && cursor != nil) {
+		newest := rows[0]
+		prev := auditlog.Cursor{CreatedAt: newest.createdAt, ID: int64(newest.log.ID), Backward: true}.Encode()
+		page.PrevCursor = &prev
+	}
+	return page
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+)
+
+func TestPageQuery(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		cursor    *auditlog.Cursor
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "first page",
+			wantQuery: "SELECT id FROM audit_logs WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2",
+			wantArgs:  []interface{}{"u1", 11},
+		},
+		{
+			name:      "older rows",
+			cursor:    &auditlog.Cursor{CreatedAt: at, ID: 5},
+			wantQuery: "SELECT id FROM audit_logs WHERE user_id = $1 AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4",
+			wantArgs:  []interface{}{"u1", at, int64(5), 11},
+		},
+		{
+			name:      "newer rows",
+			cursor:    &auditlog.Cursor{CreatedAt: at, ID: 5, Backward: true},
+			wantQuery: "SELECT id FROM audit_logs WHERE user_id = $1 AND (created_at, id) > ($2, $3) ORDER BY created_at ASC, id ASC LIMIT $4",
+			wantArgs:  []interface{}{"u1", at, int64(5), 11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := pageQuery("SELECT id FROM audit_logs WHERE user_id = $1", []interface{}{"u1"}, tt.cursor, 10)
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	// rows returns pageRows for ids in the given order, each id one second after the previous one
+	rows := func(ids ...int) []pageRow {
+		var result []pageRow
+		for _, id := range ids {
+			result = append(result, pageRow{
+				log:       auditlog.AuditLog{ID: id},
+				createdAt: base.Add(time.Duration(id) * time.Second),
+			})
+		}
+		return result
+	}
+	cursorAt := func(id int, backward bool) *auditlog.Cursor {
+		return &auditlog.Cursor{CreatedAt: base.Add(time.Duration(id) * time.Second), ID: int64(id), Backward: backward}
+	}
+
+	tests := []struct {
+		name     string
+		rows     []pageRow
+		cursor   *auditlog.Cursor
+		wantIDs  []int
+		wantNext *auditlog.Cursor
+		wantPrev *auditlog.Cursor
+	}{
+		{
+			name:    "single page",
+			rows:    rows(9, 8),
+			wantIDs: []int{9, 8},
+		},
+		{
+			name:     "first page with more",
+			rows:     rows(9, 8, 7),
+			wantIDs:  []int{9, 8},
+			wantNext: cursorAt(8, false),
+		},
+		{
+			name:     "middle page going forward",
+			rows:     rows(7, 6, 5),
+			cursor:   cursorAt(8, false),
+			wantIDs:  []int{7, 6},
+			wantNext: cursorAt(6, false),
+			wantPrev: cursorAt(7, true),
+		},
+		{
+			name:     "last page going forward",
+			rows:     rows(5),
+			cursor:   cursorAt(6, false),
+			wantIDs:  []int{5},
+			wantPrev: cursorAt(5, true),
+		},
+		{
+			name:     "going backward with more newer rows",
+			rows:     rows(5, 6, 7),
+			cursor:   cursorAt(4, true),
+			wantIDs:  []int{6, 5},
+			wantNext: cursorAt(5, false),
+			wantPrev: cursorAt(6, true),
+		},
+		{
+			name:     "going backward to the newest rows",
+			rows:     rows(8, 9),
+			cursor:   cursorAt(7, true),
+			wantIDs:  []int{9, 8},
+			wantNext: cursorAt(8, false),
+		},
+		{
+			name:     "nothing older",
+			cursor:   cursorAt(1, false),
+			wantIDs:  []int{},
+			wantPrev: cursorAt(1, true),
+		},
+		{
+			name:     "nothing newer",
+			cursor:   cursorAt(9, true),
+			wantIDs:  []int{},
+			wantNext: cursorAt(9, false),
+		},
+		{
+			name:    "empty table",
+			wantIDs: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := newPage(tt.rows, tt.cursor, 2)
+			ids := []int{}
+			for _, result := range page.Results {
+				ids = append(ids, result.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			checkCursor(t, "next_cursor", page.NextCursor, tt.wantNext)
+			checkCursor(t, "prev_cursor", page.PrevCursor, tt.wantPrev)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name string, got *string, want *auditlog.Cursor) {
+	t.Helper()
+	if want == nil {
+		if got != nil {
+			t.Errorf("%s = %q, want null", name, *got)
+		}
+		return
+	}
+	if got == nil {
+		t.Errorf("%s = null, want %+v", name, *want)
+		return
+	}
+	if *got != want.Encode() {
+		decoded, _ := auditlog.DecodeCursor(*got)
+		t.Errorf("%s = %+v, want %+v", name, decoded, *want)
+	}
+}
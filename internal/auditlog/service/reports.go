@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"log"
@@ -9,17 +10,30 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	database "github.com/motiso/sparksai-audit-service/internal/db"
 	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
+	"github.com/motiso/sparksai-audit-service/internal/rollup"
+	"github.com/motiso/sparksai-audit-service/internal/search"
 )
 
 type ReportService struct {
-	DB *sql.DB
+	DB             database.Queryer // Read pool: reports never run on the write pool
+	Dialect        database.Dialect
+	Rollups        bool                // Read whole hours from audit_rollups_hourly where the filters allow it
+	FullTextSearch bool                // Full-text search is enabled (search.Config)
+	Crypto         *encryption.Keyring // Decrypts payload columns for readers; nil on SQLite
 }
 
-func NewReportService() *ReportService {
+func NewReportService(db database.Queryer, dialect database.Dialect, rollups bool, fullTextSearch bool, crypto *encryption.Keyring) *ReportService {
 	return &ReportService{
-		DB: database.Get(),
+		DB:             db,
+		Dialect:        dialect,
+		Rollups:        rollups,
+		FullTextSearch: fullTextSearch,
+		Crypto:         crypto,
 	}
 }
 
@@ -31,61 +45,77 @@ func (s *ReportService) GetReport(w http.ResponseWriter, r *http.Request) {
 	// Parse filters from query parameters
 	months := r.URL.Query().Get("months")
 	month := r.URL.Query().Get("month") // For specific month filter (e.g., "2026-01")
-	userID := r.URL.Query().Get("user_id")
-	httpMethod := r.URL.Query().Get("http_method")
-	action := r.URL.Query().Get("action")
-	minTokens := r.URL.Query().Get("min_tokens")
-	minResponseTime := r.URL.Query().Get("min_response_time")
-	statusCode := r.URL.Query().Get("status_code")
-	statusCodeMin := r.URL.Query().Get("status_code_min")
-	statusCodeMax := r.URL.Query().Get("status_code_max")
 	searchQuery := r.URL.Query().Get("search_query")
-	severity := r.URL.Query().Get("severity")
+	fullTextSearch := r.URL.Query().Get("search") // Full-text search syntax, see search.Parse
+	dateFrom := r.URL.Query().Get("date_from")    // audit-logs report only (YYYY-MM-DD, default: today)
+	limit := r.URL.Query().Get("limit")           // audit-logs report only
+	cursor := r.URL.Query().Get("cursor")         // audit-logs report only, see auditlog.Cursor
+
+	if cursor != "" {
+		if _, err := auditlog.DecodeCursor(cursor); err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	// Every report takes the filters of filter.Parse
+	logFilter, err := filter.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if fullTextSearch != "" {
+		if !s.FullTextSearch {
+			http.Error(w, "Invalid search parameter: full-text search is disabled", http.StatusBadRequest)
+			return
+		}
+		if _, err := search.Parse(fullTextSearch); err != nil {
+			http.Error(w, "Invalid search parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	filters := map[string]interface{}{
-		"months":            months,
-		"month":             month,
-		"user_id":           userID,
-		"http_method":       httpMethod,
-		"action":            action,
-		"min_tokens":        minTokens,
-		"min_response_time": minResponseTime,
-		"status_code":       statusCode,
-		"status_code_min":   statusCodeMin,
-		"status_code_max":   statusCodeMax,
-		"search_query":      searchQuery,
-		"severity":          severity,
+		"months":       months,
+		"month":        month,
+		"search_query": searchQuery,
+		"search":       fullTextSearch,
+		"date_from":    dateFrom,
+		"limit":        limit,
+		"cursor":       cursor,
+		"filter":       logFilter,
 	}
 
 	// Route to appropriate data function
 	var result interface{}
-	var err error
 
 	switch reportID {
 	case "audit-frequently-used-actions":
-		result, err = s.getFrequentlyUsedActions(filters)
+		result, err = s.getFrequentlyUsedActions(r.Context(), filters)
 	case "audit-issues-synced-trend":
-		result, err = s.getIssuesSyncedTrend(filters)
+		result, err = s.getIssuesSyncedTrend(r.Context(), filters)
 	case "audit-token-usage":
-		result, err = s.getTokenUsage(filters)
+		result, err = s.getTokenUsage(r.Context(), filters)
 	case "audit-slow-actions":
-		result, err = s.getSlowActions(filters)
+		result, err = s.getSlowActions(r.Context(), filters)
 	case "audit-failed-endpoints":
-		result, err = s.getFailedEndpoints(filters)
+		result, err = s.getFailedEndpoints(r.Context(), filters)
 	case "audit-user-questions":
-		result, err = s.getUserQuestions(filters)
+		result, err = s.getUserQuestions(r.Context(), filters)
 	case "audit-most-active-users":
-		result, err = s.getMostActiveUsers(filters)
+		result, err = s.getMostActiveUsers(r.Context(), filters)
 	case "audit-daily-active-users":
-		result, err = s.getDailyActiveUsers(filters)
+		result, err = s.getDailyActiveUsers(r.Context(), filters)
 	case "audit-logs":
-		result, err = s.getAuditLogs(filters)
+		result, err = s.getAuditLogs(r.Context(), filters)
 	default:
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
 
 	if err != nil {
+		if querytimeout.Aborted(w, r, "report "+reportID) {
+			return
+		}
 		log.Printf("Error getting report data for %s: %v", reportID, err)
 		http.Error(w, "Failed to get report data", http.StatusInternalServerError)
 		return
@@ -112,47 +142,57 @@ func calculateDateRange(monthsStr string) (time.Time, time.Time, error) {
 }
 
 type FrequentlyUsedAction struct {
-	Action           string  `json:"action"`
-	EndpointPath     string  `json:"endpoint_path"`
-	Count            int     `json:"count"`
-	Percentage       float64 `json:"percentage"`
-	AvgResponseTime  float64 `json:"avg_response_time"`
+	Action          string  `json:"action"`
+	EndpointPath    string  `json:"endpoint_path"`
+	Count           int     `json:"count"`
+	Percentage      float64 `json:"percentage"`
+	AvgResponseTime float64 `json:"avg_response_time"`
 }
 
-func (s *ReportService) getFrequentlyUsedActions(filters map[string]interface{}) ([]FrequentlyUsedAction, error) {
+func (s *ReportService) getFrequentlyUsedActions(ctx context.Context, filters map[string]interface{}) ([]FrequentlyUsedAction, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
-	query := `
-		SELECT 
-			COALESCE(action, endpoint_path, '') as action,
-			COALESCE(endpoint_path, '') as endpoint_path,
-			COUNT(*) as count,
-			AVG(response_time_seconds) as avg_response_time
-		FROM audit_logs
-		WHERE created_at >= $1
-	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if userID := getString(filters, "user_id", ""); userID != "" {
-		query += " AND user_id = $" + strconv.Itoa(argIndex)
-		args = append(args, userID)
-		argIndex++
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
 	}
 
-	if httpMethod := getString(filters, "http_method", ""); httpMethod != "" {
-		query += " AND http_method = $" + strconv.Itoa(argIndex)
-		args = append(args, httpMethod)
-		argIndex++
+	query := `
+		SELECT 
+			action,
+			endpoint_path,
+			SUM(count) as count,
+			SUM(response_time_sum) / SUM(count) as avg_response_time
+		FROM (
+			SELECT
+				COALESCE(action, endpoint_path, '') as action,
+				COALESCE(endpoint_path, '') as endpoint_path,
+				COUNT(*) as count,
+				SUM(response_time_seconds) as response_time_sum
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+			GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, '')`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				COALESCE(action, endpoint_path, ''),
+				COALESCE(endpoint_path, ''),
+				SUM(request_count),
+				SUM(response_time_sum)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+			GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, '')`
 	}
-
 	query += `
-		GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, '')
+		) counts
+		GROUP BY action, endpoint_path
 		ORDER BY count DESC
 		LIMIT 400
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -197,33 +237,48 @@ type IssuesSyncedTrend struct {
 	TotalRequests   int     `json:"total_requests"`
 }
 
-func (s *ReportService) getIssuesSyncedTrend(filters map[string]interface{}) ([]IssuesSyncedTrend, error) {
+func (s *ReportService) getIssuesSyncedTrend(ctx context.Context, filters map[string]interface{}) ([]IssuesSyncedTrend, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT 
-			DATE(created_at) as date,
-			AVG(count) as avg_issues_synced,
-			COUNT(*) as total_requests
-		FROM audit_logs
-		WHERE created_at >= $1
-			AND count IS NOT NULL
-	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if action := getString(filters, "action", ""); action != "" {
-		query += " AND action = $" + strconv.Itoa(argIndex)
-		args = append(args, action)
-		argIndex++
+			date,
+			SUM(count_sum) * 1.0 / SUM(total_requests) as avg_issues_synced,
+			SUM(total_requests) as total_requests
+		FROM (
+			SELECT
+				` + s.Dialect.Date("created_at") + ` as date,
+				SUM(count) as count_sum,
+				COUNT(*) as total_requests
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+				AND count IS NOT NULL
+			GROUP BY ` + s.Dialect.Date("created_at")
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				` + s.Dialect.Date("bucket") + `,
+				SUM(count_sum),
+				SUM(count_rows)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+				AND count_rows > 0
+			GROUP BY ` + s.Dialect.Date("bucket")
 	}
-
 	query += `
-		GROUP BY DATE(created_at)
+		) days
+		GROUP BY date
 		ORDER BY date ASC
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +286,7 @@ func (s *ReportService) getIssuesSyncedTrend(filters map[string]interface{}) ([]
 
 	var results []IssuesSyncedTrend
 	for rows.Next() {
-		var date time.Time
+		var date string
 		var avgIssuesSynced sql.NullFloat64
 		var totalRequests int
 		if err := rows.Scan(&date, &avgIssuesSynced, &totalRequests); err != nil {
@@ -242,7 +297,7 @@ func (s *ReportService) getIssuesSyncedTrend(filters map[string]interface{}) ([]
 			avg = avgIssuesSynced.Float64
 		}
 		results = append(results, IssuesSyncedTrend{
-			Date:            date.Format("2006-01-02"),
+			Date:            date,
 			AvgIssuesSynced: avg,
 			TotalRequests:   totalRequests,
 		})
@@ -258,42 +313,49 @@ type TokenUsage struct {
 	RequestCount int     `json:"request_count"`
 }
 
-func (s *ReportService) getTokenUsage(filters map[string]interface{}) ([]TokenUsage, error) {
+func (s *ReportService) getTokenUsage(ctx context.Context, filters map[string]interface{}) ([]TokenUsage, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
-	query := `
-		SELECT 
-			COALESCE(action, endpoint_path) as action,
-			SUM(tokens_used) as total_tokens,
-			AVG(tokens_used) as avg_tokens,
-			COUNT(*) as request_count
-		FROM audit_logs
-		WHERE created_at >= $1
-			AND tokens_used IS NOT NULL
-	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if action := getString(filters, "action", ""); action != "" {
-		query += " AND action = $" + strconv.Itoa(argIndex)
-		args = append(args, action)
-		argIndex++
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
 	}
 
-	if minTokens := getString(filters, "min_tokens", ""); minTokens != "" {
-		if min, err := strconv.Atoi(minTokens); err == nil {
-			query += " AND tokens_used >= $" + strconv.Itoa(argIndex)
-			args = append(args, min)
-			argIndex++
-		}
+	query := `
+		SELECT 
+			action,
+			SUM(total_tokens) as total_tokens,
+			SUM(total_tokens) * 1.0 / SUM(request_count) as avg_tokens,
+			SUM(request_count) as request_count
+		FROM (
+			SELECT
+				COALESCE(action, endpoint_path) as action,
+				SUM(tokens_used) as total_tokens,
+				COUNT(*) as request_count
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+				AND tokens_used IS NOT NULL
+			GROUP BY COALESCE(action, endpoint_path)`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				COALESCE(action, endpoint_path),
+				SUM(tokens_sum),
+				SUM(tokens_count)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+				AND tokens_count > 0
+			GROUP BY COALESCE(action, endpoint_path)`
 	}
-
 	query += `
-		GROUP BY COALESCE(action, endpoint_path)
+		) tokens
+		GROUP BY action
 		ORDER BY total_tokens DESC
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -328,52 +390,60 @@ func (s *ReportService) getTokenUsage(filters map[string]interface{}) ([]TokenUs
 }
 
 type SlowAction struct {
-	EndpointPath      string  `json:"endpoint_path"`
-	Action           string  `json:"action"`
-	AvgResponseTime  float64 `json:"avg_response_time"`
-	MaxResponseTime  float64 `json:"max_response_time"`
-	RequestCount     int     `json:"request_count"`
+	EndpointPath    string  `json:"endpoint_path"`
+	Action          string  `json:"action"`
+	AvgResponseTime float64 `json:"avg_response_time"`
+	MaxResponseTime float64 `json:"max_response_time"`
+	RequestCount    int     `json:"request_count"`
 }
 
-func (s *ReportService) getSlowActions(filters map[string]interface{}) ([]SlowAction, error) {
+func (s *ReportService) getSlowActions(ctx context.Context, filters map[string]interface{}) ([]SlowAction, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
-	query := `
-		SELECT 
-			COALESCE(endpoint_path, '') as endpoint_path,
-			COALESCE(action, '') as action,
-			AVG(response_time_seconds) as avg_response_time,
-			MAX(response_time_seconds) as max_response_time,
-			COUNT(*) as request_count
-		FROM audit_logs
-		WHERE created_at >= $1
-	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if minResponseTime := getString(filters, "min_response_time", ""); minResponseTime != "" {
-		if min, err := strconv.ParseFloat(minResponseTime, 64); err == nil {
-			query += " AND response_time_seconds >= $" + strconv.Itoa(argIndex)
-			args = append(args, min)
-			argIndex++
-		}
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
 	}
 
-	if statusCode := getString(filters, "status_code", ""); statusCode != "" {
-		if code, err := strconv.Atoi(statusCode); err == nil {
-			query += " AND status_code = $" + strconv.Itoa(argIndex)
-			args = append(args, code)
-			argIndex++
-		}
+	query := `
+		SELECT 
+			endpoint_path,
+			action,
+			SUM(response_time_sum) / SUM(request_count) as avg_response_time,
+			MAX(max_response_time) as max_response_time,
+			SUM(request_count) as request_count
+		FROM (
+			SELECT
+				COALESCE(endpoint_path, '') as endpoint_path,
+				COALESCE(action, '') as action,
+				SUM(response_time_seconds) as response_time_sum,
+				MAX(response_time_seconds) as max_response_time,
+				COUNT(*) as request_count
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+			GROUP BY COALESCE(endpoint_path, ''), COALESCE(action, '')`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				COALESCE(endpoint_path, ''),
+				COALESCE(action, ''),
+				SUM(response_time_sum),
+				MAX(response_time_max),
+				SUM(request_count)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+			GROUP BY COALESCE(endpoint_path, ''), COALESCE(action, '')`
 	}
-
 	query += `
-		GROUP BY COALESCE(endpoint_path, ''), COALESCE(action, '')
+		) latencies
+		GROUP BY endpoint_path, action
 		ORDER BY avg_response_time DESC
 		LIMIT 400
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -396,11 +466,11 @@ func (s *ReportService) getSlowActions(filters map[string]interface{}) ([]SlowAc
 			max = maxResponseTime.Float64
 		}
 		results = append(results, SlowAction{
-			EndpointPath:     endpointPath,
-			Action:           action,
-			AvgResponseTime:  avg,
-			MaxResponseTime:  max,
-			RequestCount:     requestCount,
+			EndpointPath:    endpointPath,
+			Action:          action,
+			AvgResponseTime: avg,
+			MaxResponseTime: max,
+			RequestCount:    requestCount,
 		})
 	}
 
@@ -416,42 +486,55 @@ type FailedEndpoint struct {
 	Percentage   float64 `json:"percentage"`
 }
 
-func (s *ReportService) getFailedEndpoints(filters map[string]interface{}) ([]FailedEndpoint, error) {
+func (s *ReportService) getFailedEndpoints(ctx context.Context, filters map[string]interface{}) ([]FailedEndpoint, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT 
-			COALESCE(action, endpoint_path, '') as action,
-			COALESCE(endpoint_path, '') as endpoint_path,
+			action,
+			endpoint_path,
 			status_code,
 			severity,
-			COUNT(*) as count
-		FROM audit_logs
-		WHERE created_at >= $1
-			AND status_code::integer >= 400
-	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if httpMethod := getString(filters, "http_method", ""); httpMethod != "" {
-		query += " AND http_method = $" + strconv.Itoa(argIndex)
-		args = append(args, httpMethod)
-		argIndex++
-	}
-
-	if severity := getString(filters, "severity", ""); severity != "" {
-		query += " AND severity = $" + strconv.Itoa(argIndex)
-		args = append(args, severity)
-		argIndex++
+			SUM(count) as count
+		FROM (
+			SELECT
+				COALESCE(action, endpoint_path, '') as action,
+				COALESCE(endpoint_path, '') as endpoint_path,
+				status_code,
+				severity,
+				COUNT(*) as count
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+				AND status_code >= 400
+			GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, ''), status_code, severity`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				COALESCE(action, endpoint_path, ''),
+				COALESCE(endpoint_path, ''),
+				status_code,
+				severity,
+				SUM(request_count)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+				AND status_code >= 400
+			GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, ''), status_code, severity`
 	}
-
 	query += `
-		GROUP BY COALESCE(action, endpoint_path, ''), COALESCE(endpoint_path, ''), status_code, severity
+		) failures
+		GROUP BY action, endpoint_path, status_code, severity
 		ORDER BY count DESC
 		LIMIT 400
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -497,9 +580,18 @@ type UserQuestion struct {
 	InsightsID          *int    `json:"insights_id"`
 }
 
-func (s *ReportService) getUserQuestions(filters map[string]interface{}) ([]UserQuestion, error) {
+func (s *ReportService) getUserQuestions(ctx context.Context, filters map[string]interface{}) ([]UserQuestion, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
+	// Readers also get the questions of encrypted bodies, decrypted after the query;
+	// text filters cannot look inside envelopes, so they only match plaintext rows
+	encryptedColumns := "NULL, NULL"
+	questionFilter := "body_raw->>'question' IS NOT NULL AND body_raw->>'question' != ''"
+	if s.Crypto != nil && encryption.IsReader(ctx) {
+		encryptedColumns = "CASE WHEN body_raw ? '$enc' THEN body_raw::text END, CASE WHEN response_body ? '$enc' THEN response_body::text END"
+		questionFilter = "((" + questionFilter + ") OR body_raw ? '$enc')"
+	}
+
 	query := `
 		SELECT 
 			created_at,
@@ -509,33 +601,51 @@ func (s *ReportService) getUserQuestions(filters map[string]interface{}) ([]User
 			COALESCE(tokens_used, 0) as tokens_used,
 			response_time_seconds,
 			status_code,
-			insights_id
+			insights_id,
+			` + encryptedColumns + `
 		FROM audit_logs
-		WHERE created_at >= $1
-			AND body_raw->>'question' IS NOT NULL
-			AND body_raw->>'question' != ''
+		WHERE ` + questionFilter + `
 	`
-	args := []interface{}{dateFrom}
-	argIndex := 2
-
-	if userID := getString(filters, "user_id", ""); userID != "" {
-		query += " AND user_id = $" + strconv.Itoa(argIndex)
-		args = append(args, userID)
-		argIndex++
+	logFilter := reportFilter(filters)
+	if logFilter.From == nil {
+		logFilter.From = &dateFrom
 	}
+	where, args := logFilter.Where(s.Dialect, nil)
+	query += where
+	argIndex := len(args) + 1
 
 	if searchQuery := getString(filters, "search_query", ""); searchQuery != "" {
-		query += " AND body_raw->>'question' ILIKE $" + strconv.Itoa(argIndex)
+		query += " AND body_raw->>'question' " + s.Dialect.ILike() + " $" + strconv.Itoa(argIndex)
 		args = append(args, "%"+searchQuery+"%")
 		argIndex++
 	}
 
+	if fullTextSearch := getString(filters, "search", ""); fullTextSearch != "" {
+		parsed, err := search.Parse(fullTextSearch)
+		if err != nil {
+			return nil, err
+		}
+		if s.Dialect == database.SQLite {
+			// No full-text index: every word must appear in the question or the answer
+			for _, term := range parsed.Terms {
+				query += " AND (body_raw->>'question' LIKE $" + strconv.Itoa(argIndex) +
+					" OR response_body->'data'->>'response' LIKE $" + strconv.Itoa(argIndex) + ")"
+				args = append(args, "%"+term+"%")
+				argIndex++
+			}
+		} else {
+			query += " AND search_vector @@ to_tsquery('english', $" + strconv.Itoa(argIndex) + ")"
+			args = append(args, parsed.TSQuery)
+			argIndex++
+		}
+	}
+
 	query += `
 		ORDER BY created_at DESC
 		LIMIT 400
 	`
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -544,13 +654,40 @@ func (s *ReportService) getUserQuestions(filters map[string]interface{}) ([]User
 	var results []UserQuestion
 	for rows.Next() {
 		var createdAt time.Time
-		var userID, question, answer sql.NullString
+		var userID, question, answer, encryptedBody, encryptedResponse sql.NullString
 		var tokensUsed, insightsID sql.NullInt64
 		var responseTimeSeconds float64
 		var statusCode int
-		if err := rows.Scan(&createdAt, &userID, &question, &answer, &tokensUsed, &responseTimeSeconds, &statusCode, &insightsID); err != nil {
+		if err := rows.Scan(&createdAt, &userID, &question, &answer, &tokensUsed, &responseTimeSeconds, &statusCode, &insightsID, &encryptedBody, &encryptedResponse); err != nil {
 			return nil, err
 		}
+		if encryptedBody.Valid {
+			body, err := s.Crypto.Decrypt(ctx, "body_raw", encryptedBody.String)
+			if err != nil {
+				return nil, err
+			}
+			var decoded struct {
+				Question string `json:"question"`
+			}
+			json.Unmarshal([]byte(body), &decoded)
+			if decoded.Question == "" {
+				continue
+			}
+			question = sql.NullString{String: decoded.Question, Valid: true}
+		}
+		if encryptedResponse.Valid {
+			response, err := s.Crypto.Decrypt(ctx, "response_body", encryptedResponse.String)
+			if err != nil {
+				return nil, err
+			}
+			var decoded struct {
+				Data struct {
+					Response string `json:"response"`
+				} `json:"data"`
+			}
+			json.Unmarshal([]byte(response), &decoded)
+			answer = sql.NullString{String: decoded.Data.Response, Valid: true}
+		}
 		userIDStr := ""
 		if userID.Valid {
 			userIDStr = userID.String
@@ -588,27 +725,51 @@ func (s *ReportService) getUserQuestions(filters map[string]interface{}) ([]User
 }
 
 type MostActiveUser struct {
-	UserID        string  `json:"user_id"`
-	RequestCount  int     `json:"request_count"`
-	Percentage    float64 `json:"percentage"`
+	UserID       string  `json:"user_id"`
+	RequestCount int     `json:"request_count"`
+	Percentage   float64 `json:"percentage"`
 }
 
-func (s *ReportService) getMostActiveUsers(filters map[string]interface{}) ([]MostActiveUser, error) {
+func (s *ReportService) getMostActiveUsers(ctx context.Context, filters map[string]interface{}) ([]MostActiveUser, error) {
 	dateFrom, _, _ := calculateDateRange(getString(filters, "months", "1"))
 
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), dateFrom, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT 
-			COALESCE(user_id, 'Unknown') as user_id,
-			COUNT(*) as request_count
-		FROM audit_logs
-		WHERE created_at >= $1
-			AND user_id IS NOT NULL
+			user_id,
+			SUM(request_count) as request_count
+		FROM (
+			SELECT
+				user_id,
+				COUNT(*) as request_count
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+				AND user_id IS NOT NULL
+			GROUP BY user_id`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				user_id,
+				SUM(request_count)
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+				AND user_id IS NOT NULL
+			GROUP BY user_id`
+	}
+	query += `
+		) users
 		GROUP BY user_id
 		ORDER BY request_count DESC
 		LIMIT 400
 	`
 
-	rows, err := s.DB.Query(query, dateFrom)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -646,10 +807,10 @@ type DailyActiveUsers struct {
 	UniqueUsers int    `json:"unique_users"`
 }
 
-func (s *ReportService) getDailyActiveUsers(filters map[string]interface{}) ([]DailyActiveUsers, error) {
+func (s *ReportService) getDailyActiveUsers(ctx context.Context, filters map[string]interface{}) ([]DailyActiveUsers, error) {
 	// Get month filter (e.g., "2026-01")
 	month := getString(filters, "month", "")
-	
+
 	// Default to current month if not provided
 	if month == "" {
 		month = time.Now().UTC().Format("2006-01")
@@ -663,20 +824,46 @@ func (s *ReportService) getDailyActiveUsers(filters map[string]interface{}) ([]D
 	}
 	monthEnd := monthStart.AddDate(0, 1, 0)
 
+	args := []interface{}{}
+	rawWhere, rollupWhere, err := s.reportRange(ctx, &args, reportFilter(filters), monthStart, monthEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Distinct users per day from each source; the outer query counts them per day
 	query := `
 		SELECT 
-			DATE(created_at) as date,
-			EXTRACT(DAY FROM created_at)::integer as day,
+			date,
+			day,
 			COUNT(DISTINCT user_id) as unique_users
-		FROM audit_logs
-		WHERE created_at >= $1
-			AND created_at < $2
-			AND user_id IS NOT NULL
-		GROUP BY DATE(created_at), EXTRACT(DAY FROM created_at)
+		FROM (
+			SELECT
+				` + s.Dialect.Date("created_at") + ` as date,
+				` + s.Dialect.DayOfMonth("created_at") + ` as day,
+				user_id
+			FROM audit_logs
+			WHERE ` + rawWhere + `
+				AND user_id IS NOT NULL
+			GROUP BY ` + s.Dialect.Date("created_at") + `, ` + s.Dialect.DayOfMonth("created_at") + `, user_id`
+	if rollupWhere != "" {
+		query += `
+			UNION ALL
+			SELECT
+				` + s.Dialect.Date("bucket") + `,
+				` + s.Dialect.DayOfMonth("bucket") + `,
+				user_id
+			FROM audit_rollups_hourly
+			WHERE ` + rollupWhere + `
+				AND user_id IS NOT NULL
+			GROUP BY ` + s.Dialect.Date("bucket") + `, ` + s.Dialect.DayOfMonth("bucket") + `, user_id`
+	}
+	query += `
+		) users
+		GROUP BY date, day
 		ORDER BY date ASC
 	`
 
-	rows, err := s.DB.Query(query, monthStart, monthEnd)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -684,14 +871,14 @@ func (s *ReportService) getDailyActiveUsers(filters map[string]interface{}) ([]D
 
 	var results []DailyActiveUsers
 	for rows.Next() {
-		var date time.Time
+		var date string
 		var day int
 		var uniqueUsers int
 		if err := rows.Scan(&date, &day, &uniqueUsers); err != nil {
 			return nil, err
 		}
 		results = append(results, DailyActiveUsers{
-			Date:        date.Format("2006-01-02"),
+			Date:        date,
 			Day:         day,
 			UniqueUsers: uniqueUsers,
 		})
@@ -700,6 +887,82 @@ func (s *ReportService) getDailyActiveUsers(filters map[string]interface{}) ([]D
 	return results, nil
 }
 
+// timeRange builds the time conditions of a report over [from, to) (open-ended when to is zero)
+// Whole hours covered by the hourly rollups are read from audit_rollups_hourly (rollupWhere)
+// and the rest from audit_logs (rawWhere); rollupWhere is empty when the rollups are disabled,
+// do not cover any of those hours or allowRollups is false because a filter needs the raw rows
+// The bounds are appended to args
+func (s *ReportService) timeRange(ctx context.Context, args *[]interface{}, from time.Time, to time.Time, allowRollups bool) (string, string, error) {
+	param := func(value interface{}) string {
+		*args = append(*args, value)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	rawWhere := "created_at >= " + param(from)
+	if !to.IsZero() {
+		rawWhere += " AND created_at < " + param(to)
+	}
+	if !s.Rollups || !allowRollups {
+		return rawWhere, "", nil
+	}
+
+	// Read from the same pool as the report, so the coverage matches the rollups it reads
+	coverage, found, err := rollup.ReadCoverage(ctx, s.DB)
+	if err != nil || !found {
+		return rawWhere, "", err
+	}
+	lower := from.Truncate(time.Hour)
+	if lower.Before(from) {
+		lower = lower.Add(time.Hour)
+	}
+	if lower.Before(coverage.From) {
+		lower = coverage.From
+	}
+	upper := coverage.To
+	if !to.IsZero() && to.Truncate(time.Hour).Before(upper) {
+		upper = to.Truncate(time.Hour)
+	}
+	if !lower.Before(upper) {
+		return rawWhere, "", nil
+	}
+
+	lowerParam, upperParam := param(lower), param(upper)
+	rawWhere += " AND NOT (created_at >= " + lowerParam + " AND created_at < " + upperParam + ")"
+	return rawWhere, "bucket >= " + lowerParam + " AND bucket < " + upperParam, nil
+}
+
+// reportRange builds the conditions of an aggregate report over [from, to): the time range
+// of timeRange followed by the other conditions of f, which apply to both tables
+// from and to of f replace the report's own range; conditions on columns the rollups do
+// not keep make the whole range read audit_logs
+func (s *ReportService) reportRange(ctx context.Context, args *[]interface{}, f filter.Filter, from time.Time, to time.Time) (string, string, error) {
+	if f.From != nil {
+		from = *f.From
+	}
+	if f.To != nil {
+		to = *f.To
+	}
+	rawWhere, rollupWhere, err := s.timeRange(ctx, args, from, to, f.RollupCompatible())
+	if err != nil {
+		return "", "", err
+	}
+
+	f.From, f.To = nil, nil
+	where, extended := f.Where(s.Dialect, *args)
+	*args = extended
+	rawWhere += where
+	if rollupWhere != "" {
+		rollupWhere += where
+	}
+	return rawWhere, rollupWhere, nil
+}
+
+// reportFilter returns the filter.Filter that GetReport parsed
+func reportFilter(filters map[string]interface{}) filter.Filter {
+	f, _ := filters["filter"].(filter.Filter)
+	return f
+}
+
 // Helper function to get string from filters map
 func getString(filters map[string]interface{}, key, defaultValue string) string {
 	if val, ok := filters[key]; ok {
@@ -719,15 +982,15 @@ func min(a, b int) int {
 }
 
 // GetAuditLogsFilterValues retrieves all distinct values for filter dropdowns
-func (s *ReportService) GetAuditLogsFilterValues() (map[string]interface{}, error) {
+func (s *ReportService) GetAuditLogsFilterValues(ctx context.Context) (map[string]interface{}, error) {
 	query := `
 		WITH http_methods AS (
-			SELECT DISTINCT http_method::text as value, 'http_method' as type
+			SELECT DISTINCT CAST(http_method AS TEXT) as value, 'http_method' as type
 			FROM audit_logs
 			WHERE http_method IS NOT NULL
 		),
 		status_codes AS (
-			SELECT DISTINCT status_code::text as value, 'status_code' as type
+			SELECT DISTINCT CAST(status_code AS TEXT) as value, 'status_code' as type
 			FROM audit_logs
 		),
 		severities AS (
@@ -757,7 +1020,7 @@ func (s *ReportService) GetAuditLogsFilterValues() (map[string]interface{}, erro
 		ORDER BY type, value
 	`
 
-	rows, err := s.DB.Query(query)
+	rows, err := s.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -766,9 +1029,9 @@ func (s *ReportService) GetAuditLogsFilterValues() (map[string]interface{}, erro
 	result := map[string]interface{}{
 		"http_methods": []string{},
 		"status_codes": []int{},
-		"severities":  []string{},
+		"severities":   []string{},
 		"user_ids":     []string{},
-		"actions":     []string{},
+		"actions":      []string{},
 	}
 
 	httpMethods := []string{}
@@ -812,17 +1075,13 @@ func (s *ReportService) GetAuditLogsFilterValues() (map[string]interface{}, erro
 	return result, nil
 }
 
-// getAuditLogs retrieves audit logs with filters
-func (s *ReportService) getAuditLogs(filters map[string]interface{}) ([]auditlog.AuditLog, error) {
+// getAuditLogs retrieves one page of audit logs with filters, newest first
+func (s *ReportService) getAuditLogs(ctx context.Context, filters map[string]interface{}) (*auditlog.Page, error) {
 	// Parse filters
-	userID := getString(filters, "user_id", "")
-	severity := getString(filters, "severity", "")
-	action := getString(filters, "action", "")
-	httpMethod := getString(filters, "http_method", "")
-	statusCodeStr := getString(filters, "status_code", "")
+	logFilter := reportFilter(filters)
 	dateFromStr := getString(filters, "date_from", "")
-	minTokensStr := getString(filters, "min_tokens", "")
 	limitStr := getString(filters, "limit", "100")
+	cursorStr := getString(filters, "cursor", "")
 
 	// Parse limit (default 100, max 500)
 	limit := 100
@@ -836,143 +1095,57 @@ func (s *ReportService) getAuditLogs(filters map[string]interface{}) ([]auditlog
 		}
 	}
 
-	// Build query
-	query := `
-		SELECT 
-			id, user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
-			response_time_seconds, created_at, ip_address, user_agent,
-			chat_history_id, insights_id, tokens_used,
-			query_raw, body_raw, response_body
-		FROM audit_logs
-		WHERE 1=1
-	`
-	args := []interface{}{}
-	argIndex := 1
-
-	// Default date_from to today if not provided
-	var dateFrom time.Time
-	if dateFromStr != "" {
-		parsed, err := time.Parse("2006-01-02", dateFromStr)
-		if err == nil {
-			dateFrom = parsed.UTC()
-		} else {
-			dateFrom = time.Now().UTC().Truncate(24 * time.Hour)
+	var cursor *auditlog.Cursor
+	if cursorStr != "" {
+		parsed, err := auditlog.DecodeCursor(cursorStr)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		dateFrom = time.Now().UTC().Truncate(24 * time.Hour)
-	}
-	query += " AND created_at >= $" + strconv.Itoa(argIndex)
-	args = append(args, dateFrom)
-	argIndex++
-
-	if userID != "" {
-		query += " AND user_id = $" + strconv.Itoa(argIndex)
-		args = append(args, userID)
-		argIndex++
+		cursor = parsed
 	}
 
-	if severity != "" {
-		query += " AND severity = $" + strconv.Itoa(argIndex)
-		args = append(args, severity)
-		argIndex++
-	}
-
-	if action != "" {
-		query += " AND action = $" + strconv.Itoa(argIndex)
-		args = append(args, action)
-		argIndex++
-	}
-
-	if httpMethod != "" {
-		query += " AND http_method = $" + strconv.Itoa(argIndex)
-		args = append(args, httpMethod)
-		argIndex++
-	}
-
-	if statusCodeStr != "" {
-		if statusCode, err := strconv.Atoi(statusCodeStr); err == nil {
-			query += " AND status_code = $" + strconv.Itoa(argIndex)
-			args = append(args, statusCode)
-			argIndex++
+	// Default the range to date_from, or today, unless from is given
+	if logFilter.From == nil {
+		dateFrom := time.Now().UTC().Truncate(24 * time.Hour)
+		if parsed, err := time.Parse("2006-01-02", dateFromStr); err == nil {
+			dateFrom = parsed.UTC()
 		}
+		logFilter.From = &dateFrom
 	}
 
-	if minTokensStr != "" {
-		if minTokens, err := strconv.Atoi(minTokensStr); err == nil {
-			query += " AND tokens_used >= $" + strconv.Itoa(argIndex)
-			args = append(args, minTokens)
-			argIndex++
-		}
-	}
+	// Build query; created_at is selected again at full precision for the cursors
+	where, args := logFilter.Where(s.Dialect, nil)
+	query := `SELECT ` + auditLogColumns + `, response_body, created_at
+		FROM audit_logs
+		WHERE 1=1` + where
 
-	query += " ORDER BY created_at DESC LIMIT $" + strconv.Itoa(argIndex)
-	args = append(args, limit)
+	query, args = pageQuery(query, args, cursor, limit)
 
-	rows, err := s.DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var logs []auditlog.AuditLog
+	var fetched []pageRow
 	for rows.Next() {
-		var logEntry auditlog.AuditLog
-		var userIDVal, sessionIDVal, actionVal, ipAddressVal, userAgentVal sql.NullString
-		var chatHistoryIDVal, insightsIDVal, tokensUsedVal, countVal sql.NullInt64
-		var queryRawVal, bodyRawVal, responseBodyVal sql.NullString
-		var createdAt, actionDateVal sql.NullTime
-
-		err := rows.Scan(
-			&logEntry.ID,
-			&userIDVal,
-			&logEntry.Severity,
-			&logEntry.EndpointPath,
-			&sessionIDVal,
-			&actionVal,
-			&actionDateVal,
-			&countVal,
-			&logEntry.HTTPMethod,
-			&logEntry.StatusCode,
-			&logEntry.ResponseTimeSeconds,
-			&createdAt,
-			&ipAddressVal,
-			&userAgentVal,
-			&chatHistoryIDVal,
-			&insightsIDVal,
-			&tokensUsedVal,
-			&queryRawVal,
-			&bodyRawVal,
-			&responseBodyVal,
-		)
+		var responseBodyVal sql.NullString
+		var createdAt time.Time
+		logEntry, err := scanAuditLog(rows, &responseBodyVal, &createdAt)
 		if err != nil {
 			return nil, err
 		}
-
-		// Convert nullable fields
-		logEntry.UserID = nullStringToPtr(userIDVal)
-		logEntry.SessionID = nullStringToPtr(sessionIDVal)
-		logEntry.Action = nullStringToPtr(actionVal)
-		logEntry.IPAddress = nullStringToPtr(ipAddressVal)
-		logEntry.UserAgent = nullStringToPtr(userAgentVal)
-		logEntry.QueryRaw = nullStringToPtr(queryRawVal)
-		logEntry.BodyRaw = nullStringToPtr(bodyRawVal)
 		logEntry.ResponseBody = nullStringToPtr(responseBodyVal)
+		if err := revealPayloads(ctx, s.Crypto, &logEntry); err != nil {
+			return nil, err
+		}
 
-		logEntry.ActionDate = nullTimeToRFC3339Ptr(actionDateVal)
-		logEntry.CreatedAt = nullTimeToRFC3339(createdAt)
-
-		logEntry.Count = nullInt64ToIntPtr(countVal)
-		logEntry.ChatHistoryID = nullInt64ToIntPtr(chatHistoryIDVal)
-		logEntry.InsightsID = nullInt64ToIntPtr(insightsIDVal)
-		logEntry.TokensUsed = nullInt64ToIntPtr(tokensUsedVal)
-
-		logs = append(logs, logEntry)
+		fetched = append(fetched, pageRow{log: logEntry, createdAt: createdAt})
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return logs, nil
+	return newPage(fetched, cursor, limit), nil
 }
-
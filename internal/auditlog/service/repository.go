@@ -1,22 +1,37 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
 
-	database "github.com/motiso/sparksai-audit-service/internal/db"
 	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
 )
 
 type AuditLogDB struct {
-	*sql.DB
+	*sql.DB                     // Write pool, also used by reads that must see the latest rows
+	Reads   database.Queryer    // Read pool (replicas with primary fallback) for GetAuditLogs
+	Crypto  *encryption.Keyring // Encrypts payload columns on insert; nil on SQLite
+	Dialect database.Dialect
 }
 
-func GetAuditLogDataStore() auditlog.AuditLogDatastore {
-	return &AuditLogDB{database.Get()}
+// NewAuditLogDataStore returns the datastore implementation for the database dialect
+func NewAuditLogDataStore(db *sql.DB, reads database.Queryer, dialect database.Dialect, crypto *encryption.Keyring) auditlog.AuditLogDatastore {
+	if dialect == database.SQLite {
+		return &SQLiteAuditLogDB{AuditLogDB{DB: db, Reads: reads, Dialect: dialect}}
+	}
+	return &AuditLogDB{DB: db, Reads: reads, Crypto: crypto, Dialect: dialect}
 }
 
 // parseQueryRaw parses raw query string into JSONB object
@@ -24,14 +39,14 @@ func parseQueryRaw(queryRaw string) interface{} {
 	if queryRaw == "" {
 		return nil
 	}
-	
+
 	// Parse query string
 	values, err := url.ParseQuery(queryRaw)
 	if err != nil {
 		// If parsing fails, return as string
 		return queryRaw
 	}
-	
+
 	// Convert to map (take first value for each key)
 	queryMap := make(map[string]interface{})
 	for k, v := range values {
@@ -43,11 +58,11 @@ func parseQueryRaw(queryRaw string) interface{} {
 			}
 		}
 	}
-	
+
 	if len(queryMap) == 0 {
 		return nil
 	}
-	
+
 	return queryMap
 }
 
@@ -56,14 +71,14 @@ func parseBodyRaw(bodyRaw string) interface{} {
 	if bodyRaw == "" {
 		return nil
 	}
-	
+
 	// Try to parse as JSON
 	var bodyJSON interface{}
 	if err := json.Unmarshal([]byte(bodyRaw), &bodyJSON); err == nil {
 		// Valid JSON - return as object
 		return bodyJSON
 	}
-	
+
 	// Not JSON - return as string
 	return bodyRaw
 }
@@ -74,37 +89,83 @@ func marshalToJSONBString(parsed interface{}, rawString string) interface{} {
 	if parsed == nil {
 		return nil
 	}
-	
+
 	// Marshal to JSON string for JSONB column
 	if jsonBytes, err := json.Marshal(parsed); err == nil {
 		return string(jsonBytes)
 	}
-	
+
 	// If marshaling fails, store as original string
 	return rawString
 }
 
 // BatchInsertAuditLogs inserts multiple audit logs in a single transaction
-func (db *AuditLogDB) BatchInsertAuditLogs(logs []auditlog.AuditLog) error {
+// Each row is appended to the hash chain: the transaction holds the chain advisory
+// lock, so ids, created_at and prev_hash are assigned in one serialized order
+func (db *AuditLogDB) BatchInsertAuditLogs(ctx context.Context, logs []auditlog.AuditLog) error {
 	if len(logs) == 0 {
 		return nil
 	}
 
 	// Start transaction
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Serialize chain appends across goroutines and service instances
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, chain.LockKey); err != nil {
+		return fmt.Errorf("failed to acquire chain lock: %w", err)
+	}
+
+	ids, err := allocateIDs(ctx, tx, len(logs))
+	if err != nil {
+		return err
+	}
+	if err := appendChain(ctx, tx, logs, ids, db.Crypto); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Successfully inserted %d audit log entries", len(logs))
+	return nil
+}
+
+// appendChain inserts logs with the pre-allocated ids (ascending) as the next links of
+// the hash chain and moves the chain head. The caller serializes appends.
+// Payload columns are encrypted before hashing, so the chain covers the stored ciphertext.
+func appendChain(ctx context.Context, tx *sql.Tx, logs []auditlog.AuditLog, ids []int64, crypto *encryption.Keyring) error {
+	prevHash := chain.GenesisHash
+	var lastCreatedAt time.Time
+	err := tx.QueryRowContext(ctx, `SELECT last_hash, last_created_at FROM audit_chain_head WHERE id = 1`).Scan(&prevHash, &lastCreatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read chain head: %w", err)
+	}
+
+	// created_at is assigned here (not by the column default) so it is part of the hash,
+	// and never goes backwards so id order and time order agree
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	if createdAt.Before(lastCreatedAt) {
+		createdAt = lastCreatedAt
+	}
+
 	// Prepare statement
-	stmt, err := tx.Prepare(`
+	stmt, err := tx.PrepareContext(ctx, `
 		INSERT INTO audit_logs (
+			id, created_at,
 			user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
 			response_time_seconds, ip_address, user_agent,
 			chat_history_id, insights_id, tokens_used,
-			query_raw, body_raw, response_body
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+			query_raw, body_raw, response_body,
+			resource_type, resource_id, outcome, actor_type, impersonated_by, tenant_id, trace_id,
+			before_snapshot, after_snapshot, change_diff,
+			prev_hash, content_hash, row_hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
@@ -112,89 +173,155 @@ func (db *AuditLogDB) BatchInsertAuditLogs(logs []auditlog.AuditLog) error {
 	defer stmt.Close()
 
 	// Insert each log
-	for _, logEntry := range logs {
-		var chatHistoryIDVal, insightsIDVal, tokensUsedVal interface{}
-		if logEntry.ChatHistoryID != nil {
-			chatHistoryIDVal = *logEntry.ChatHistoryID
-		}
-		if logEntry.InsightsID != nil {
-			insightsIDVal = *logEntry.InsightsID
-		}
-		if logEntry.TokensUsed != nil {
-			tokensUsedVal = *logEntry.TokensUsed
+	var lastID int64
+	for i, logEntry := range logs {
+		entry := chain.Entry{
+			ID:             ids[i],
+			CreatedAt:      createdAt,
+			UserID:         logEntry.UserID,
+			Severity:       logEntry.Severity,
+			EndpointPath:   logEntry.EndpointPath,
+			SessionID:      logEntry.SessionID,
+			Action:         logEntry.Action,
+			ActionDate:     parseActionDate(logEntry.ActionDate),
+			Count:          intToInt64Ptr(logEntry.Count),
+			HTTPMethod:     logEntry.HTTPMethod,
+			StatusCode:     int64(logEntry.StatusCode),
+			ResponseTime:   strconv.FormatFloat(logEntry.ResponseTimeSeconds, 'f', 3, 64),
+			UserAgent:      logEntry.UserAgent,
+			ChatHistoryID:  intToInt64Ptr(logEntry.ChatHistoryID),
+			InsightsID:     intToInt64Ptr(logEntry.InsightsID),
+			TokensUsed:     intToInt64Ptr(logEntry.TokensUsed),
+			ResourceType:   logEntry.ResourceType,
+			ResourceID:     logEntry.ResourceID,
+			ActorType:      logEntry.ActorType,
+			ImpersonatedBy: logEntry.ImpersonatedBy,
+			TenantID:       logEntry.TenantID,
+			TraceID:        logEntry.TraceID,
+			Before:         rawJSONToPtr(logEntry.Before),
+			After:          rawJSONToPtr(logEntry.After),
+			ChangeDiff:     rawJSONToPtr(logEntry.ChangeDiff),
 		}
-
-		var sessionIDVal interface{}
-		if logEntry.SessionID != nil {
-			sessionIDVal = *logEntry.SessionID
-		}
-
-		var actionDateVal interface{}
-		if logEntry.ActionDate != nil {
-			actionDateVal = *logEntry.ActionDate
+		if logEntry.Outcome != "" {
+			entry.Outcome = &logEntry.Outcome
 		}
-
-		var countVal interface{}
-		if logEntry.Count != nil {
-			countVal = *logEntry.Count
+		if logEntry.IPAddress != nil {
+			normalized := chain.NormalizeIP(*logEntry.IPAddress)
+			entry.IPAddress = &normalized
 		}
 
 		// Parse query_raw and body_raw before storing, then marshal to JSON string for JSONB
-		var queryRawVal interface{}
 		if logEntry.QueryRaw != nil {
 			parsed := parseQueryRaw(*logEntry.QueryRaw)
-			queryRawVal = marshalToJSONBString(parsed, *logEntry.QueryRaw)
+			entry.QueryRaw = jsonbStringToPtr(marshalToJSONBString(parsed, *logEntry.QueryRaw))
 		}
-
-		var bodyRawVal interface{}
 		if logEntry.BodyRaw != nil {
 			parsed := parseBodyRaw(*logEntry.BodyRaw)
-			bodyRawVal = marshalToJSONBString(parsed, *logEntry.BodyRaw)
+			entry.BodyRaw = jsonbStringToPtr(marshalToJSONBString(parsed, *logEntry.BodyRaw))
 		}
-
-		var responseBodyVal interface{}
 		if logEntry.ResponseBody != nil {
 			parsed := parseBodyRaw(*logEntry.ResponseBody)
-			responseBodyVal = marshalToJSONBString(parsed, *logEntry.ResponseBody)
+			entry.ResponseBody = jsonbStringToPtr(marshalToJSONBString(parsed, *logEntry.ResponseBody))
+		}
+		if entry.QueryRaw, err = crypto.Encrypt(ctx, "query_raw", entry.QueryRaw); err != nil {
+			return err
+		}
+		if entry.BodyRaw, err = crypto.Encrypt(ctx, "body_raw", entry.BodyRaw); err != nil {
+			return err
+		}
+		if entry.ResponseBody, err = crypto.Encrypt(ctx, "response_body", entry.ResponseBody); err != nil {
+			return err
 		}
 
-		_, err := stmt.Exec(
-			logEntry.UserID,
-			logEntry.Severity,
-			logEntry.EndpointPath,
-			sessionIDVal,
-			logEntry.Action,
-			actionDateVal,
-			countVal,
-			logEntry.HTTPMethod,
-			logEntry.StatusCode,
-			logEntry.ResponseTimeSeconds,
-			logEntry.IPAddress,
-			logEntry.UserAgent,
-			chatHistoryIDVal,
-			insightsIDVal,
-			tokensUsedVal,
-			queryRawVal,
-			bodyRawVal,
-			responseBodyVal,
+		contentHash := entry.ContentHash()
+		rowHash := chain.RowHash(prevHash, contentHash)
+
+		_, err := stmt.ExecContext(ctx,
+			entry.ID,
+			entry.CreatedAt,
+			entry.UserID,
+			entry.Severity,
+			entry.EndpointPath,
+			entry.SessionID,
+			entry.Action,
+			entry.ActionDate,
+			entry.Count,
+			entry.HTTPMethod,
+			entry.StatusCode,
+			entry.ResponseTime,
+			entry.IPAddress,
+			entry.UserAgent,
+			entry.ChatHistoryID,
+			entry.InsightsID,
+			entry.TokensUsed,
+			entry.QueryRaw,
+			entry.BodyRaw,
+			entry.ResponseBody,
+			entry.ResourceType,
+			entry.ResourceID,
+			entry.Outcome,
+			entry.ActorType,
+			entry.ImpersonatedBy,
+			entry.TenantID,
+			entry.TraceID,
+			entry.Before,
+			entry.After,
+			entry.ChangeDiff,
+			prevHash,
+			contentHash,
+			rowHash,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert audit log: %w", err)
 		}
-	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		prevHash = rowHash
+		lastID = entry.ID
 	}
 
-	log.Printf("Successfully inserted %d audit log entries", len(logs))
+	// Move the chain head to the last inserted row
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_chain_head (id, last_id, last_hash, last_created_at, updated_at)
+		VALUES (1, $1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (id) DO UPDATE SET
+			last_id = EXCLUDED.last_id,
+			last_hash = EXCLUDED.last_hash,
+			last_created_at = EXCLUDED.last_created_at,
+			updated_at = EXCLUDED.updated_at
+	`, lastID, prevHash, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to update chain head: %w", err)
+	}
 	return nil
 }
 
-// GetAuditLogs retrieves audit logs with optional filters
-// userID and action are optional filters, limit defaults to 500 (max 500)
-func (db *AuditLogDB) GetAuditLogs(userID *string, action *string, limit int) ([]auditlog.AuditLog, error) {
+// allocateIDs reserves n ids from the audit_logs sequence, in increasing order
+func allocateIDs(ctx context.Context, tx *sql.Tx, n int) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT nextval(pg_get_serial_sequence('audit_logs', 'id')) FROM generate_series(1, $1)`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan allocated id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating allocated ids: %w", err)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// GetAuditLogs retrieves one page of audit logs with optional filters, newest first
+// userID and action are optional filters, cursor continues from an earlier page,
+// limit defaults to 500 (max 500)
+func (db *AuditLogDB) GetAuditLogs(ctx context.Context, f filter.Filter, cursor *auditlog.Cursor, limit int) (*auditlog.Page, error) {
 	// Validate and set limit
 	if limit <= 0 {
 		limit = 500
@@ -203,103 +330,42 @@ func (db *AuditLogDB) GetAuditLogs(userID *string, action *string, limit int) ([
 		limit = 500
 	}
 
-	// Build query with optional filters
-	query := `
-		SELECT 
-			id, user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
-			response_time_seconds, created_at, ip_address, user_agent,
-			chat_history_id, insights_id, tokens_used,
-			query_raw, body_raw
+	// Build query with optional filters; created_at is selected again at full precision for the cursors
+	where, args := f.Where(db.Dialect, nil)
+	query := `SELECT ` + auditLogColumns + `, created_at
 		FROM audit_logs
-		WHERE 1=1
-	`
-	
-	args := []interface{}{}
-	argPos := 1
+		WHERE 1=1` + where
 
-	if userID != nil && *userID != "" {
-		query += fmt.Sprintf(" AND user_id = $%d", argPos)
-		args = append(args, *userID)
-		argPos++
-	}
+	query, args = pageQuery(query, args, cursor, limit)
 
-	if action != nil && *action != "" {
-		query += fmt.Sprintf(" AND action = $%d", argPos)
-		args = append(args, *action)
-		argPos++
-	}
-
-	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argPos)
-	args = append(args, limit)
-
-	rows, err := db.Query(query, args...)
+	rows, err := db.Reads.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit logs: %w", err)
 	}
 	defer rows.Close()
 
-		var logs []auditlog.AuditLog
-		for rows.Next() {
-			var logEntry auditlog.AuditLog
-			var userIDVal, sessionIDVal, actionVal, ipAddressVal, userAgentVal sql.NullString
-			var chatHistoryIDVal, insightsIDVal, tokensUsedVal, countVal sql.NullInt64
-			var queryRawVal, bodyRawVal sql.NullString
-			var createdAt, actionDateVal sql.NullTime
-
-			err := rows.Scan(
-				&logEntry.ID,
-				&userIDVal,
-				&logEntry.Severity,
-				&logEntry.EndpointPath,
-				&sessionIDVal,
-				&actionVal,
-				&actionDateVal,
-				&countVal,
-				&logEntry.HTTPMethod,
-				&logEntry.StatusCode,
-				&logEntry.ResponseTimeSeconds,
-				&createdAt,
-				&ipAddressVal,
-				&userAgentVal,
-				&chatHistoryIDVal,
-				&insightsIDVal,
-				&tokensUsedVal,
-				&queryRawVal,
-				&bodyRawVal,
-			)
+	var fetched []pageRow
+	for rows.Next() {
+		var createdAt time.Time
+		logEntry, err := scanAuditLog(rows, &createdAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan audit log: %w", err)
 		}
-
-		// Convert nullable fields using helper functions
-		logEntry.UserID = nullStringToPtr(userIDVal)
-		logEntry.SessionID = nullStringToPtr(sessionIDVal)
-		logEntry.Action = nullStringToPtr(actionVal)
-		logEntry.IPAddress = nullStringToPtr(ipAddressVal)
-		logEntry.UserAgent = nullStringToPtr(userAgentVal)
-		logEntry.QueryRaw = nullStringToPtr(queryRawVal)
-		logEntry.BodyRaw = nullStringToPtr(bodyRawVal)
-		
-		logEntry.ActionDate = nullTimeToRFC3339Ptr(actionDateVal)
-		logEntry.CreatedAt = nullTimeToRFC3339(createdAt)
-		
-		logEntry.Count = nullInt64ToIntPtr(countVal)
-		logEntry.ChatHistoryID = nullInt64ToIntPtr(chatHistoryIDVal)
-		logEntry.InsightsID = nullInt64ToIntPtr(insightsIDVal)
-		logEntry.TokensUsed = nullInt64ToIntPtr(tokensUsedVal)
-
-		logs = append(logs, logEntry)
+		if err := revealPayloads(ctx, db.Crypto, &logEntry); err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, pageRow{log: logEntry, createdAt: createdAt})
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating audit logs: %w", err)
 	}
 
-	return logs, nil
+	return newPage(fetched, cursor, limit), nil
 }
 
 // GetDistinctActions retrieves all distinct action values from audit_logs
-func (db *AuditLogDB) GetDistinctActions() ([]string, error) {
+func (db *AuditLogDB) GetDistinctActions(ctx context.Context) ([]string, error) {
 	query := `
 		SELECT DISTINCT action
 		FROM audit_logs
@@ -307,7 +373,7 @@ func (db *AuditLogDB) GetDistinctActions() ([]string, error) {
 		ORDER BY action ASC
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query distinct actions: %w", err)
 	}
@@ -329,8 +395,355 @@ func (db *AuditLogDB) GetDistinctActions() ([]string, error) {
 	return actions, nil
 }
 
+// GetResourceHistory retrieves one page of the audit log entries recorded against a single
+// resource, newest first, with the same cursors as GetAuditLogs
+func (db *AuditLogDB) GetResourceHistory(ctx context.Context, resourceType string, resourceID string, cursor *auditlog.Cursor, limit int) (*auditlog.Page, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+
+	// created_at is selected again at full precision for the cursors
+	query := `SELECT ` + auditLogColumns + `, created_at
+		FROM audit_logs
+		WHERE resource_type = $1 AND resource_id = $2`
+	query, args := pageQuery(query, []interface{}{resourceType, resourceID}, cursor, limit)
+
+	rows, err := db.Reads.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resource history: %w", err)
+	}
+	defer rows.Close()
+
+	var fetched []pageRow
+	for rows.Next() {
+		var createdAt time.Time
+		logEntry, err := scanAuditLog(rows, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if err := revealPayloads(ctx, db.Crypto, &logEntry); err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, pageRow{log: logEntry, createdAt: createdAt})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating resource history: %w", err)
+	}
+
+	return newPage(fetched, cursor, limit), nil
+}
+
+// GetAuditLogChange retrieves the before/after snapshots and stored diff of one audit log entry
+// Returns nil when the entry does not exist or has no change capture
+func (db *AuditLogDB) GetAuditLogChange(ctx context.Context, id int) (*auditlog.AuditLogChange, error) {
+	query := `
+		SELECT id, user_id, http_method, endpoint_path, resource_type, resource_id, created_at,
+			before_snapshot, after_snapshot, change_diff
+		FROM audit_logs
+		WHERE id = $1 AND change_diff IS NOT NULL
+	`
+
+	var change auditlog.AuditLogChange
+	var userIDVal, resourceTypeVal, resourceIDVal sql.NullString
+	var beforeVal, afterVal sql.NullString
+	var createdAt sql.NullTime
+	var patch string
+	err := db.QueryRowContext(ctx, query, id).Scan(
+		&change.ID,
+		&userIDVal,
+		&change.HTTPMethod,
+		&change.EndpointPath,
+		&resourceTypeVal,
+		&resourceIDVal,
+		&createdAt,
+		&beforeVal,
+		&afterVal,
+		&patch,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log change: %w", err)
+	}
+
+	change.UserID = nullStringToPtr(userIDVal)
+	change.ResourceType = nullStringToPtr(resourceTypeVal)
+	change.ResourceID = nullStringToPtr(resourceIDVal)
+	change.CreatedAt = nullTimeToRFC3339(createdAt)
+	if beforeVal.Valid {
+		change.Before = json.RawMessage(beforeVal.String)
+	}
+	if afterVal.Valid {
+		change.After = json.RawMessage(afterVal.String)
+	}
+	change.Patch = json.RawMessage(patch)
+
+	return &change, nil
+}
+
+// GetAuditLog retrieves one audit log entry with every stored column
+// Returns nil when the entry does not exist
+func (db *AuditLogDB) GetAuditLog(ctx context.Context, id int) (*auditlog.AuditLogRecord, error) {
+	query := `SELECT ` + auditLogColumns + `, response_body,
+			before_snapshot, after_snapshot, change_diff, redacted_at, prev_hash, content_hash, row_hash
+		FROM audit_logs
+		WHERE id = $1
+	`
+
+	var responseBodyVal, beforeVal, afterVal, changeDiffVal sql.NullString
+	var prevHashVal, contentHashVal, rowHashVal sql.NullString
+	var redactedAt sql.NullTime
+	logEntry, err := scanAuditLog(db.QueryRowContext(ctx, query, id),
+		&responseBodyVal, &beforeVal, &afterVal, &changeDiffVal, &redactedAt, &prevHashVal, &contentHashVal, &rowHashVal)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	logEntry.ResponseBody = nullStringToPtr(responseBodyVal)
+	if err := revealPayloads(ctx, db.Crypto, &logEntry); err != nil {
+		return nil, err
+	}
+	if beforeVal.Valid {
+		logEntry.Before = json.RawMessage(beforeVal.String)
+	}
+	if afterVal.Valid {
+		logEntry.After = json.RawMessage(afterVal.String)
+	}
+	if changeDiffVal.Valid {
+		logEntry.ChangeDiff = json.RawMessage(changeDiffVal.String)
+	}
+
+	return &auditlog.AuditLogRecord{
+		AuditLog:     logEntry,
+		QueryRaw:     ptrToRawJSON(logEntry.QueryRaw),
+		BodyRaw:      ptrToRawJSON(logEntry.BodyRaw),
+		ResponseBody: ptrToRawJSON(logEntry.ResponseBody),
+		RedactedAt:   nullTimeToRFC3339Ptr(redactedAt),
+		PrevHash:     nullStringToPtr(prevHashVal),
+		ContentHash:  nullStringToPtr(contentHashVal),
+		RowHash:      nullStringToPtr(rowHashVal),
+	}, nil
+}
+
+// GetRelatedAuditLogs retrieves up to limit entries on each side of entry that share its
+// session, chat history or trace
+func (db *AuditLogDB) GetRelatedAuditLogs(ctx context.Context, entry *auditlog.AuditLogRecord, limit int) (*auditlog.RelatedAuditLogs, error) {
+	related := &auditlog.RelatedAuditLogs{}
+	var err error
+	if entry.SessionID != nil {
+		if related.Session, err = db.getNeighbours(ctx, "session_id", *entry.SessionID, entry.ID, limit); err != nil {
+			return nil, err
+		}
+	}
+	if entry.ChatHistoryID != nil {
+		if related.ChatHistory, err = db.getNeighbours(ctx, "chat_history_id", *entry.ChatHistoryID, entry.ID, limit); err != nil {
+			return nil, err
+		}
+	}
+	if entry.TraceID != nil {
+		if related.Trace, err = db.getNeighbours(ctx, "trace_id", *entry.TraceID, entry.ID, limit); err != nil {
+			return nil, err
+		}
+	}
+	return related, nil
+}
+
+// getNeighbours retrieves up to limit entries before and after entry id whose column equals
+// value, oldest first and without the entry itself
+func (db *AuditLogDB) getNeighbours(ctx context.Context, column string, value interface{}, id int, limit int) ([]auditlog.AuditLog, error) {
+	logs := []auditlog.AuditLog{}
+	for _, side := range []struct{ comparison, order string }{{"<", "DESC"}, {">", "ASC"}} {
+		query := `SELECT ` + auditLogColumns + `
+			FROM audit_logs
+			WHERE ` + column + ` = $1
+				AND (created_at, id) ` + side.comparison + ` (SELECT created_at, id FROM audit_logs WHERE id = $2)
+			ORDER BY created_at ` + side.order + `, id ` + side.order + `
+			LIMIT $3
+		`
+
+		rows, err := db.QueryContext(ctx, query, value, id, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query related audit logs: %w", err)
+		}
+		var sideLogs []auditlog.AuditLog
+		for rows.Next() {
+			logEntry, err := scanAuditLog(rows)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan audit log: %w", err)
+			}
+			if err := revealPayloads(ctx, db.Crypto, &logEntry); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			sideLogs = append(sideLogs, logEntry)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating related audit logs: %w", err)
+		}
+
+		// Earlier entries were fetched newest first
+		if side.order == "DESC" {
+			slices.Reverse(sideLogs)
+		}
+		logs = append(logs, sideLogs...)
+	}
+	return logs, nil
+}
+
+// auditLogColumns is the column list read by scanAuditLog
+// response_body is not included - callers that need it append it and pass an extra scan target
+const auditLogColumns = `
+			id, user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
+			response_time_seconds, created_at, ip_address, user_agent,
+			chat_history_id, insights_id, tokens_used,
+			query_raw, body_raw,
+			resource_type, resource_id, outcome, actor_type, impersonated_by, tenant_id, trace_id`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAuditLog scans a row selected with auditLogColumns into an AuditLog
+// extra scan targets are appended after the standard columns
+func scanAuditLog(row rowScanner, extra ...interface{}) (auditlog.AuditLog, error) {
+	var logEntry auditlog.AuditLog
+	var userIDVal, sessionIDVal, actionVal, ipAddressVal, userAgentVal sql.NullString
+	var chatHistoryIDVal, insightsIDVal, tokensUsedVal, countVal sql.NullInt64
+	var queryRawVal, bodyRawVal sql.NullString
+	var resourceTypeVal, resourceIDVal, outcomeVal, actorTypeVal, impersonatedByVal, tenantIDVal, traceIDVal sql.NullString
+	var createdAt, actionDateVal sql.NullTime
+
+	dest := []interface{}{
+		&logEntry.ID,
+		&userIDVal,
+		&logEntry.Severity,
+		&logEntry.EndpointPath,
+		&sessionIDVal,
+		&actionVal,
+		&actionDateVal,
+		&countVal,
+		&logEntry.HTTPMethod,
+		&logEntry.StatusCode,
+		&logEntry.ResponseTimeSeconds,
+		&createdAt,
+		&ipAddressVal,
+		&userAgentVal,
+		&chatHistoryIDVal,
+		&insightsIDVal,
+		&tokensUsedVal,
+		&queryRawVal,
+		&bodyRawVal,
+		&resourceTypeVal,
+		&resourceIDVal,
+		&outcomeVal,
+		&actorTypeVal,
+		&impersonatedByVal,
+		&tenantIDVal,
+		&traceIDVal,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return logEntry, err
+	}
+
+	// Convert nullable fields using helper functions
+	logEntry.UserID = nullStringToPtr(userIDVal)
+	logEntry.SessionID = nullStringToPtr(sessionIDVal)
+	logEntry.Action = nullStringToPtr(actionVal)
+	logEntry.IPAddress = nullStringToPtr(ipAddressVal)
+	logEntry.UserAgent = nullStringToPtr(userAgentVal)
+	logEntry.QueryRaw = nullStringToPtr(queryRawVal)
+	logEntry.BodyRaw = nullStringToPtr(bodyRawVal)
+	logEntry.ResourceType = nullStringToPtr(resourceTypeVal)
+	logEntry.ResourceID = nullStringToPtr(resourceIDVal)
+	logEntry.ActorType = nullStringToPtr(actorTypeVal)
+	logEntry.ImpersonatedBy = nullStringToPtr(impersonatedByVal)
+	logEntry.TenantID = nullStringToPtr(tenantIDVal)
+	logEntry.TraceID = nullStringToPtr(traceIDVal)
+	logEntry.Outcome = outcomeVal.String
+
+	logEntry.ActionDate = nullTimeToRFC3339Ptr(actionDateVal)
+	logEntry.CreatedAt = nullTimeToRFC3339(createdAt)
+
+	logEntry.Count = nullInt64ToIntPtr(countVal)
+	logEntry.ChatHistoryID = nullInt64ToIntPtr(chatHistoryIDVal)
+	logEntry.InsightsID = nullInt64ToIntPtr(insightsIDVal)
+	logEntry.TokensUsed = nullInt64ToIntPtr(tokensUsedVal)
+
+	return logEntry, nil
+}
+
+// revealPayloads decrypts the encrypted payload columns of a log for readers and masks
+// them for everyone else (see encryption.Keyring.Reveal)
+func revealPayloads(ctx context.Context, crypto *encryption.Keyring, logEntry *auditlog.AuditLog) error {
+	var err error
+	if logEntry.QueryRaw, err = crypto.Reveal(ctx, "query_raw", logEntry.QueryRaw); err != nil {
+		return err
+	}
+	if logEntry.BodyRaw, err = crypto.Reveal(ctx, "body_raw", logEntry.BodyRaw); err != nil {
+		return err
+	}
+	logEntry.ResponseBody, err = crypto.Reveal(ctx, "response_body", logEntry.ResponseBody)
+	return err
+}
+
 // Helper functions for nullable field conversions
 
+// rawJSONToPtr converts an empty json.RawMessage to nil
+func rawJSONToPtr(raw json.RawMessage) *string {
+	if len(raw) == 0 {
+		return nil
+	}
+	value := string(raw)
+	return &value
+}
+
+// ptrToRawJSON converts a JSON string column to json.RawMessage, nil when unset
+func ptrToRawJSON(value *string) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return json.RawMessage(*value)
+}
+
+// jsonbStringToPtr converts the result of marshalToJSONBString to *string
+func jsonbStringToPtr(value interface{}) *string {
+	if str, ok := value.(string); ok {
+		return &str
+	}
+	return nil
+}
+
+// intToInt64Ptr converts *int to *int64
+func intToInt64Ptr(value *int) *int64 {
+	if value == nil {
+		return nil
+	}
+	converted := int64(*value)
+	return &converted
+}
+
+// parseActionDate parses action_date in the formats accepted on ingest
+// Returns nil when the value is missing or cannot be parsed
+func parseActionDate(value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+	parsed, err := auditlog.ParseActionDate(*value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
 // nullStringToPtr converts sql.NullString to *string
 func nullStringToPtr(ns sql.NullString) *string {
 	if ns.Valid {
@@ -339,6 +752,14 @@ func nullStringToPtr(ns sql.NullString) *string {
 	return nil
 }
 
+// nullInt64ToPtr converts sql.NullInt64 to *int64
+func nullInt64ToPtr(ni sql.NullInt64) *int64 {
+	if ni.Valid {
+		return &ni.Int64
+	}
+	return nil
+}
+
 // nullInt64ToIntPtr converts sql.NullInt64 to *int
 func nullInt64ToIntPtr(ni sql.NullInt64) *int {
 	if ni.Valid {
@@ -365,3 +786,169 @@ func nullTimeToRFC3339(nt sql.NullTime) string {
 	return ""
 }
 
+// VerifyChain recomputes the hash chain over rows created in [from, to)
+// Live rows, retention tombstones and the range tombstones of detached partitions are
+// merged in id order. With no lower bound the
+// chain must start at the genesis hash; with no upper bound the last row must match
+// the recorded chain head, which detects deleted tail rows.
+func (db *AuditLogDB) VerifyChain(ctx context.Context, from *time.Time, to *time.Time) (*chain.VerifyResult, error) {
+	var headID int64
+	var headHash string
+	if to == nil {
+		err := db.QueryRowContext(ctx, `SELECT last_id, last_hash FROM audit_chain_head WHERE id = 1`).Scan(&headID, &headHash)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to read chain head: %w", err)
+		}
+	}
+
+	where := " WHERE row_hash IS NOT NULL"
+	args := []interface{}{}
+	argIndex := 1
+	if from != nil {
+		where += " AND created_at >= $" + strconv.Itoa(argIndex)
+		args = append(args, *from)
+		argIndex++
+	}
+	if to != nil {
+		where += " AND created_at < $" + strconv.Itoa(argIndex)
+		args = append(args, *to)
+		argIndex++
+	} else if headID > 0 {
+		// Rows committed after the head was read are left for the next verification
+		where += " AND id <= $" + strconv.Itoa(argIndex)
+		args = append(args, headID)
+		argIndex++
+	}
+
+	// A redacted row is only taken as erased on purpose when an erasure job covers its id and
+	// wrote its pseudonym, as user_id or in a reference column. Jobs still pending or running
+	// count too, since their range only grows with committed batches; rows of a failed job and
+	// any other redacted row are checked as edited. SQLite has no erasure, so every redacted
+	// row there is checked as edited
+	redacted := `FALSE`
+	if db.Dialect != database.SQLite {
+		redacted = `redacted_at IS NOT NULL AND EXISTS (
+				SELECT 1 FROM audit_erasure_jobs j
+				WHERE j.status IN ('pending', 'running', 'completed')
+					AND audit_logs.id BETWEEN j.first_id AND j.last_id
+					AND 'pseudonym-' || LEFT(j.subject_hash, 32) IN (audit_logs.user_id, audit_logs.impersonated_by, audit_logs.resource_id)
+			)`
+	}
+	query := `
+		SELECT FALSE, 0, ` + redacted + `, id, created_at, prev_hash, content_hash, row_hash,
+			user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
+			response_time_seconds, ip_address, user_agent, chat_history_id, insights_id, tokens_used,
+			query_raw, body_raw, response_body,
+			resource_type, resource_id, outcome, actor_type, impersonated_by, tenant_id, trace_id,
+			before_snapshot, after_snapshot, change_diff
+		FROM audit_logs` + where + `
+		UNION ALL
+		SELECT TRUE, 0, FALSE, id, created_at, prev_hash, content_hash, row_hash,
+			NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL,
+			NULL, NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL
+		FROM audit_chain_tombstones` + where + `
+		UNION ALL
+		SELECT TRUE, last_id, FALSE, id, created_at, prev_hash, NULL, row_hash,
+			NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL,
+			NULL, NULL, NULL, NULL, NULL, NULL, NULL,
+			NULL, NULL, NULL
+		FROM audit_chain_ranges` + where + `
+		ORDER BY 4 ASC, 2 DESC`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	verifier := chain.NewVerifier(from == nil)
+	for rows.Next() {
+		var link chain.Link
+		var createdAt time.Time
+		var contentHash sql.NullString
+		var userID, severity, endpointPath, sessionID, action, httpMethod sql.NullString
+		var ipAddress, userAgent, queryRaw, bodyRaw, responseBody sql.NullString
+		var resourceType, resourceID, outcome, actorType, impersonatedBy, tenantID, traceID sql.NullString
+		var before, after, changeDiff sql.NullString
+		var actionDate sql.NullTime
+		var count, statusCode, chatHistoryID, insightsID, tokensUsed sql.NullInt64
+		var responseTime sql.NullFloat64
+
+		err := rows.Scan(
+			&link.Tombstone, &link.LastID, &link.Redacted, &link.ID, &createdAt, &link.PrevHash, &contentHash, &link.RowHash,
+			&userID, &severity, &endpointPath, &sessionID, &action, &actionDate, &count, &httpMethod, &statusCode,
+			&responseTime, &ipAddress, &userAgent, &chatHistoryID, &insightsID, &tokensUsed,
+			&queryRaw, &bodyRaw, &responseBody,
+			&resourceType, &resourceID, &outcome, &actorType, &impersonatedBy, &tenantID, &traceID,
+			&before, &after, &changeDiff,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit chain link: %w", err)
+		}
+		link.CreatedAt = chain.FormatTime(createdAt)
+		link.ContentHash = contentHash.String
+
+		if !link.Tombstone {
+			entry := &chain.Entry{
+				ID:             link.ID,
+				CreatedAt:      createdAt,
+				UserID:         nullStringToPtr(userID),
+				Severity:       severity.String,
+				EndpointPath:   endpointPath.String,
+				SessionID:      nullStringToPtr(sessionID),
+				Action:         nullStringToPtr(action),
+				Count:          nullInt64ToPtr(count),
+				HTTPMethod:     httpMethod.String,
+				StatusCode:     statusCode.Int64,
+				ResponseTime:   strconv.FormatFloat(responseTime.Float64, 'f', 3, 64),
+				IPAddress:      nullStringToPtr(ipAddress),
+				UserAgent:      nullStringToPtr(userAgent),
+				ChatHistoryID:  nullInt64ToPtr(chatHistoryID),
+				InsightsID:     nullInt64ToPtr(insightsID),
+				TokensUsed:     nullInt64ToPtr(tokensUsed),
+				QueryRaw:       nullStringToPtr(queryRaw),
+				BodyRaw:        nullStringToPtr(bodyRaw),
+				ResponseBody:   nullStringToPtr(responseBody),
+				ResourceType:   nullStringToPtr(resourceType),
+				ResourceID:     nullStringToPtr(resourceID),
+				Outcome:        nullStringToPtr(outcome),
+				ActorType:      nullStringToPtr(actorType),
+				ImpersonatedBy: nullStringToPtr(impersonatedBy),
+				TenantID:       nullStringToPtr(tenantID),
+				TraceID:        nullStringToPtr(traceID),
+				Before:         nullStringToPtr(before),
+				After:          nullStringToPtr(after),
+				ChangeDiff:     nullStringToPtr(changeDiff),
+			}
+			if actionDate.Valid {
+				entry.ActionDate = &actionDate.Time
+			}
+			link.Entry = entry
+		}
+
+		if !verifier.Check(link) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit chain: %w", err)
+	}
+
+	if to == nil {
+		verifier.CheckHead(headID, headHash)
+	}
+
+	result := verifier.Result
+	if from != nil {
+		result.From = from.UTC().Format(time.RFC3339)
+	}
+	if to != nil {
+		result.To = to.UTC().Format(time.RFC3339)
+	}
+	return &result, nil
+}
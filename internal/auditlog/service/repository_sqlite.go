@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+)
+
+// SQLiteAuditLogDB is the AuditLogDatastore backed by an embedded SQLite file
+// Read queries are shared with AuditLogDB; only the chain append differs, because
+// SQLite has no advisory locks or sequences
+type SQLiteAuditLogDB struct {
+	AuditLogDB
+}
+
+// BatchInsertAuditLogs inserts multiple audit logs in a single transaction
+// The pool has a single connection, so appends are already serialized
+func (db *SQLiteAuditLogDB) BatchInsertAuditLogs(ctx context.Context, logs []auditlog.AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	// Start transaction
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids, err := allocateSQLiteIDs(ctx, tx, len(logs))
+	if err != nil {
+		return err
+	}
+	if err := appendChain(ctx, tx, logs, ids, db.Crypto); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Successfully inserted %d audit log entries", len(logs))
+	return nil
+}
+
+// allocateSQLiteIDs reserves the next n ids of audit_logs
+// AUTOINCREMENT keeps the highest id ever used in sqlite_sequence, so ids of deleted
+// rows are never reused; inserting explicit ids advances it
+func allocateSQLiteIDs(ctx context.Context, tx *sql.Tx, n int) ([]int64, error) {
+	var last int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT MAX(
+			COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'audit_logs'), 0),
+			COALESCE((SELECT MAX(id) FROM audit_chain_tombstones), 0)
+		)`).Scan(&last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate ids: %w", err)
+	}
+
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = last + int64(i) + 1
+	}
+	return ids, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
+	"github.com/motiso/sparksai-audit-service/internal/search"
+)
+
+// headlineOptions marks matches in snippets with <mark> and keeps up to two fragments
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" ... \""
+
+// SearchResult is one audit log matched by a full-text search
+type SearchResult struct {
+	ID           int64   `json:"id"`
+	CreatedAt    string  `json:"created_at"`
+	UserID       *string `json:"user_id"`
+	Action       *string `json:"action"`
+	EndpointPath string  `json:"endpoint_path"`
+	Question     *string `json:"question"`
+	Rank         float64 `json:"rank"`
+	Snippet      string  `json:"snippet"` // Best matching fragments, matches wrapped in <mark>
+}
+
+// SearchFilters narrow a full-text search
+type SearchFilters struct {
+	Filter filter.Filter
+	Limit  int
+	Offset int
+}
+
+// SearchHandler handles GET /api/audit-logs/search
+// Query parameters: q (required), the filters of filter.Parse, limit (default 50, max 200), offset
+// Results are ordered by rank, then newest first
+func (s *ReportService) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query, err := search.Parse(params.Get("q"))
+	if err != nil {
+		http.Error(w, "Invalid q parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters := SearchFilters{Limit: 50}
+	if filters.Filter, err = filter.Parse(params); err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limitParam := params.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = min(limit, 200)
+	}
+	if offsetParam := params.Get("offset"); offsetParam != "" {
+		offset, err := strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Offset = offset
+	}
+
+	results, err := s.Search(r.Context(), query, filters)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "Search") {
+			return
+		}
+		log.Printf("error occurred during Search: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   params.Get("q"),
+		"limit":   filters.Limit,
+		"offset":  filters.Offset,
+		"results": results,
+	})
+}
+
+// Search runs a full-text search over questions, answers and request bodies (PostgreSQL only)
+// Only the returned page gets snippets, since ts_headline re-parses each document
+func (s *ReportService) Search(ctx context.Context, query search.Query, filters SearchFilters) ([]SearchResult, error) {
+	if s.Dialect == database.SQLite {
+		return nil, errors.New("full-text search requires PostgreSQL")
+	}
+
+	where, args := filters.Filter.Where(s.Dialect, []interface{}{query.TSQuery})
+	argIndex := len(args) + 1
+
+	limitParam := "$" + strconv.Itoa(argIndex)
+	offsetParam := "$" + strconv.Itoa(argIndex+1)
+	optionsParam := "$" + strconv.Itoa(argIndex+2)
+	args = append(args, filters.Limit, filters.Offset, headlineOptions)
+
+	// The snippet document is the question and answer, then the other body strings
+	// (none for encrypted bodies, whose envelope strings are ciphertext)
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH matches AS (
+			SELECT id, created_at, user_id, action, endpoint_path, body_raw, response_body,
+				ts_rank_cd(search_vector, q) AS rank
+			FROM audit_logs, to_tsquery('english', $1) q
+			WHERE search_vector @@ q`+where+`
+			ORDER BY rank DESC, created_at DESC, id DESC
+			LIMIT `+limitParam+` OFFSET `+offsetParam+`
+		)
+		SELECT id, created_at, user_id, action, endpoint_path, body_raw->>'question', rank,
+			ts_headline('english',
+				concat_ws(' ... ',
+					body_raw->>'question',
+					response_body->'data'->>'response',
+					(SELECT string_agg(value #>> '{}', ' ')
+					FROM jsonb_path_query(
+						CASE WHEN body_raw ? '$enc' THEN 'null'
+							WHEN jsonb_typeof(body_raw) = 'object' THEN body_raw - 'question'
+							ELSE COALESCE(body_raw, 'null') END,
+						'strict $.** ? (@.type() == "string")') value)),
+				to_tsquery('english', $1),
+				`+optionsParam+`)
+		FROM matches
+		ORDER BY rank DESC, created_at DESC, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var createdAt time.Time
+		var userID, action, question sql.NullString
+		if err := rows.Scan(&result.ID, &createdAt, &userID, &action, &result.EndpointPath, &question, &result.Rank, &result.Snippet); err != nil {
+			return nil, err
+		}
+		result.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		result.UserID = nullStringToPtr(userID)
+		result.Action = nullStringToPtr(action)
+		result.Question = nullStringToPtr(question)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
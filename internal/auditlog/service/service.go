@@ -9,8 +9,12 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gorilla/mux"
 	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
 	"github.com/motiso/sparksai-audit-service/internal/buffer"
+	"github.com/motiso/sparksai-audit-service/internal/jsonpatch"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
 )
 
 var numericEndingRegex = regexp.MustCompile(`/\d+$`)
@@ -39,23 +43,18 @@ func normalizeAction(action string, endpointPath string) string {
 	return action
 }
 
-var auditService *AuditService
-
-func Get() *AuditService {
-	if auditService == nil {
-		datastore := GetAuditLogDataStore()
-		auditService = &AuditService{
-			DB:     datastore,
-			Buffer: buffer.Get(datastore),
-		}
-		return auditService
-	}
-	return auditService
+type AuditService struct {
+	DB      auditlog.AuditLogDatastore
+	Buffer  *buffer.Buffer
+	Reports *ReportService
 }
 
-type AuditService struct {
-	DB     auditlog.AuditLogDatastore
-	Buffer *buffer.Buffer
+func NewAuditService(datastore auditlog.AuditLogDatastore, buf *buffer.Buffer, reports *ReportService) *AuditService {
+	return &AuditService{
+		DB:      datastore,
+		Buffer:  buf,
+		Reports: reports,
+	}
 }
 
 // CreateAuditLogsHandler handles POST /api/audit-logs
@@ -92,6 +91,52 @@ func (as *AuditService) CreateAuditLogsHandler(w http.ResponseWriter, r *http.Re
 			req.Logs[i].Severity = "NONE"
 		}
 
+		if req.Logs[i].ActorType != nil && !auditlog.IsValidActorType(*req.Logs[i].ActorType) {
+			http.Error(w, fmt.Sprintf("actor_type must be one of user, service, system for log entry %d", i), http.StatusBadRequest)
+			return
+		}
+		if (req.Logs[i].ResourceType == nil) != (req.Logs[i].ResourceID == nil) {
+			http.Error(w, fmt.Sprintf("resource_type and resource_id must be set together for log entry %d", i), http.StatusBadRequest)
+			return
+		}
+
+		// action_date and ip_address are normalized before hashing, so reject values that cannot be
+		if req.Logs[i].ActionDate != nil {
+			if _, err := auditlog.ParseActionDate(*req.Logs[i].ActionDate); err != nil {
+				http.Error(w, fmt.Sprintf("action_date must be an RFC 3339 timestamp or date for log entry %d", i), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Logs[i].IPAddress != nil && !auditlog.IsValidIPAddress(*req.Logs[i].IPAddress) {
+			http.Error(w, fmt.Sprintf("ip_address must be an IPv4 or IPv6 address for log entry %d", i), http.StatusBadRequest)
+			return
+		}
+
+		// Derive outcome from status code when the producer did not send one
+		if req.Logs[i].Outcome == "" {
+			req.Logs[i].Outcome = auditlog.OutcomeFromStatusCode(req.Logs[i].StatusCode)
+		} else if !auditlog.IsValidOutcome(req.Logs[i].Outcome) {
+			http.Error(w, fmt.Sprintf("outcome must be one of success, failure, denied, error for log entry %d", i), http.StatusBadRequest)
+			return
+		}
+
+		// Compute the change diff for mutating requests that carry snapshots
+		if len(req.Logs[i].Before) > 0 || len(req.Logs[i].After) > 0 {
+			if !auditlog.IsMutatingMethod(req.Logs[i].HTTPMethod) {
+				http.Error(w, fmt.Sprintf("before/after snapshots are only accepted for PUT, PATCH and DELETE (log entry %d)", i), http.StatusBadRequest)
+				return
+			}
+			ops, err := jsonpatch.DiffJSON(req.Logs[i].Before, req.Logs[i].After)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid before/after snapshot for log entry %d", i), http.StatusBadRequest)
+				return
+			}
+			diff, _ := json.Marshal(ops)
+			req.Logs[i].ChangeDiff = diff
+		} else {
+			req.Logs[i].ChangeDiff = nil // Computed by the service only
+		}
+
 		// Normalize action and endpoint
 		if req.Logs[i].Action != nil {
 			normalized := normalizeAction(*req.Logs[i].Action, req.Logs[i].EndpointPath)
@@ -114,24 +159,19 @@ func (as *AuditService) CreateAuditLogsHandler(w http.ResponseWriter, r *http.Re
 }
 
 // GetAuditLogsHandler handles GET /api/audit-logs
-// Query parameters: user_id (optional), action (optional), limit (optional, default: 500, max: 500)
-// Results are ordered by created_at DESC (latest first)
+// Query parameters: the filters of filter.Parse (optional), cursor (optional, next_cursor or prev_cursor
+// of an earlier page), limit (optional, default: 500, max: 500)
+// Results are ordered by created_at DESC, id DESC (latest first)
 func (as *AuditService) GetAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
-	userIDParam := r.URL.Query().Get("user_id")
-	actionParam := r.URL.Query().Get("action")
 	limitParam := r.URL.Query().Get("limit")
+	cursorParam := r.URL.Query().Get("cursor")
 
-	// Parse user_id (optional)
-	var userID *string
-	if userIDParam != "" {
-		userID = &userIDParam
-	}
-
-	// Parse action (optional)
-	var action *string
-	if actionParam != "" {
-		action = &actionParam
+	// Parse filters (optional)
+	f, err := filter.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Parse limit (optional, default 500, max 500)
@@ -149,9 +189,23 @@ func (as *AuditService) GetAuditLogsHandler(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	// Parse cursor (optional)
+	var cursor *auditlog.Cursor
+	if cursorParam != "" {
+		parsedCursor, err := auditlog.DecodeCursor(cursorParam)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		cursor = parsedCursor
+	}
+
 	// Get audit logs from database
-	logs, err := as.DB.GetAuditLogs(userID, action, limit)
+	page, err := as.DB.GetAuditLogs(r.Context(), f, cursor, limit)
 	if err != nil {
+		if querytimeout.Aborted(w, r, "GetAuditLogs") {
+			return
+		}
 		log.Printf("error occurred during GetAuditLogs: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -159,15 +213,18 @@ func (as *AuditService) GetAuditLogsHandler(w http.ResponseWriter, r *http.Reque
 
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	json.NewEncoder(w).Encode(page)
 }
 
 // GetActionsHandler handles GET /api/audit-logs/actions
 // Returns a list of all distinct action values from audit logs
 func (as *AuditService) GetActionsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get distinct actions from database
-	actions, err := as.DB.GetDistinctActions()
+	actions, err := as.DB.GetDistinctActions(r.Context())
 	if err != nil {
+		if querytimeout.Aborted(w, r, "GetDistinctActions") {
+			return
+		}
 		log.Printf("error occurred during GetDistinctActions: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -181,9 +238,11 @@ func (as *AuditService) GetActionsHandler(w http.ResponseWriter, r *http.Request
 // GetAuditLogsFilterValuesHandler handles GET /api/audit-logs/filter-values
 // Returns all distinct values for filter dropdowns in a single response
 func (as *AuditService) GetAuditLogsFilterValuesHandler(w http.ResponseWriter, r *http.Request) {
-	reportSvc := NewReportService()
-	values, err := reportSvc.GetAuditLogsFilterValues()
+	values, err := as.Reports.GetAuditLogsFilterValues(r.Context())
 	if err != nil {
+		if querytimeout.Aborted(w, r, "GetAuditLogsFilterValues") {
+			return
+		}
 		log.Printf("error occurred during GetAuditLogsFilterValues: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -194,3 +253,187 @@ func (as *AuditService) GetAuditLogsFilterValuesHandler(w http.ResponseWriter, r
 	json.NewEncoder(w).Encode(values)
 }
 
+// GetResourceHistoryHandler handles GET /api/audit-logs/resources/{resource_type}/{resource_id}
+// Query parameters: limit (optional, default: 500, max: 500), cursor (optional)
+// Returns the audit history of a single resource (e.g. every action on sprint 42), newest
+// first, in pages like GetAuditLogsHandler
+func (as *AuditService) GetResourceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	resourceType := vars["resource_type"]
+	resourceID := vars["resource_id"]
+
+	// Parse limit (optional, default 500, max 500)
+	limit := 500
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsedLimit, 500)
+	}
+
+	// Parse cursor (optional)
+	var cursor *auditlog.Cursor
+	if cursorParam := r.URL.Query().Get("cursor"); cursorParam != "" {
+		parsedCursor, err := auditlog.DecodeCursor(cursorParam)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		cursor = parsedCursor
+	}
+
+	page, err := as.DB.GetResourceHistory(r.Context(), resourceType, resourceID, cursor, limit)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "GetResourceHistory") {
+			return
+		}
+		log.Printf("error occurred during GetResourceHistory: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ResourceType string `json:"resource_type"`
+		ResourceID   string `json:"resource_id"`
+		*auditlog.Page
+	}{resourceType, resourceID, page})
+}
+
+// GetAuditLogHandler handles GET /api/audit-logs/{id}
+// Query parameters: include (optional, "related"), related_limit (optional, default: 10, max: 50)
+// Returns the full entry with decoded payloads and, with include=related, up to related_limit
+// entries on each side of it that share its session, chat history or trace
+func (as *AuditService) GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	include := r.URL.Query().Get("include")
+	if include != "" && include != "related" {
+		http.Error(w, "include must be related", http.StatusBadRequest)
+		return
+	}
+	relatedLimit := 10
+	if limitParam := r.URL.Query().Get("related_limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid related_limit parameter", http.StatusBadRequest)
+			return
+		}
+		relatedLimit = min(parsed, 50)
+	}
+
+	entry, err := as.DB.GetAuditLog(r.Context(), id)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "GetAuditLog") {
+			return
+		}
+		log.Printf("error occurred during GetAuditLog: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if entry == nil {
+		http.Error(w, "Audit log entry not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{"entry": entry}
+	if include == "related" {
+		related, err := as.DB.GetRelatedAuditLogs(r.Context(), entry, relatedLimit)
+		if err != nil {
+			if querytimeout.Aborted(w, r, "GetRelatedAuditLogs") {
+				return
+			}
+			log.Printf("error occurred during GetRelatedAuditLogs: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response["related"] = related
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAuditLogDiffHandler handles GET /api/audit-logs/{id}/diff
+// Returns the stored JSON Patch of a mutating request and its field-level changes
+func (as *AuditService) GetAuditLogDiffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	change, err := as.DB.GetAuditLogChange(r.Context(), id)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "GetAuditLogChange") {
+			return
+		}
+		log.Printf("error occurred during GetAuditLogChange: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if change == nil {
+		http.Error(w, "Audit log entry not found or has no change capture", http.StatusNotFound)
+		return
+	}
+
+	var ops []jsonpatch.Operation
+	if err := json.Unmarshal(change.Patch, &ops); err != nil {
+		log.Printf("error occurred decoding change_diff for audit log %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	changes, err := jsonpatch.Changes(change.Before, ops)
+	if err != nil {
+		log.Printf("error occurred rendering changes for audit log %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"change":  change,
+		"changes": changes,
+	})
+}
+
+// VerifyAuditLogsHandler handles GET /api/audit-logs/verify
+// Query parameters: from, to (optional, RFC 3339 timestamp or YYYY-MM-DD; to is exclusive)
+// Recomputes the hash chain and reports the first broken link, if any
+func (as *AuditService) VerifyAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := filter.ParseTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+	to, err := filter.ParseTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	result, err := as.DB.VerifyChain(r.Context(), from, to)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "VerifyChain") {
+			return
+		}
+		log.Printf("error occurred during VerifyChain: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !result.Valid {
+		log.Printf("[AUDIT CHAIN] Verification failed at id %d: %s", result.FirstBroken.ID, result.FirstBroken.Reason)
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
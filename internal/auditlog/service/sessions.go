@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/filter"
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
+)
+
+// failedCondition matches requests that did not succeed; rows from before outcome was
+// recorded fall back to their status code, as in auditlog.OutcomeFromStatusCode
+const failedCondition = "(outcome IN ('failure', 'denied', 'error') OR (outcome IS NULL AND status_code >= 400))"
+
+// defaultSessionsWindow is how far back the sessions list looks without a from parameter
+const defaultSessionsWindow = 30 * 24 * time.Hour
+
+// SessionSummary is one session of a user in the sessions list
+type SessionSummary struct {
+	SessionID       string  `json:"session_id"`
+	UserID          *string `json:"user_id"`
+	StartedAt       string  `json:"started_at"`
+	EndedAt         string  `json:"ended_at"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	EventCount      int64   `json:"event_count"`
+	FailedCount     int64   `json:"failed_count"` // Requests whose outcome is failure, denied or error
+	TokensUsed      int64   `json:"tokens_used"`
+}
+
+// TimelineEvent is one request in a session timeline
+type TimelineEvent struct {
+	auditlog.AuditLog
+	OffsetSeconds    float64  `json:"offset_seconds"`    // Since the first event of the session
+	GapSeconds       *float64 `json:"gap_seconds"`       // Since the previous event; null for the first
+	Failed           bool     `json:"failed"`            // Outcome is failure, denied or error
+	CumulativeTokens int64    `json:"cumulative_tokens"` // Tokens used by the session up to and including this event
+}
+
+// Timeline is a session replayed in order, with totals over all of its events
+type Timeline struct {
+	SessionID           string          `json:"session_id"`
+	UserIDs             []string        `json:"user_ids"` // Distinct users, in order of appearance
+	StartedAt           string          `json:"started_at"`
+	EndedAt             string          `json:"ended_at"`
+	DurationSeconds     float64         `json:"duration_seconds"`
+	EventCount          int64           `json:"event_count"`
+	FailedCount         int64           `json:"failed_count"`
+	TokensUsed          int64           `json:"tokens_used"`
+	ResponseTimeSeconds float64         `json:"response_time_seconds"` // Sum of the request durations
+	LongestGapSeconds   float64         `json:"longest_gap_seconds"`   // Among the returned events
+	Truncated           bool            `json:"truncated"`             // Events stop at limit; the totals still cover the whole session
+	Events              []TimelineEvent `json:"events"`
+}
+
+// SessionTimelineHandler handles GET /api/sessions/{session_id}/timeline
+// Query parameters: limit (optional, default: 1000, max: 10000)
+// Returns the events of the session oldest first, with the gap before each one, durations,
+// failures and token totals, so support can replay what the user did
+func (s *ReportService) SessionTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	limit := 1000
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, 10000)
+	}
+
+	timeline, err := s.GetSessionTimeline(r.Context(), sessionID, limit)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "GetSessionTimeline") {
+			return
+		}
+		log.Printf("error occurred during GetSessionTimeline: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if timeline == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// ListSessionsHandler handles GET /api/sessions
+// Query parameters: the filters of filter.Parse (from defaults to 30 days ago),
+// limit (optional, default: 100, max: 500), offset (optional)
+// Sessions are summarized per user and ordered by their latest event, newest first
+func (s *ReportService) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := filter.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, 500)
+	}
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	sessions, err := s.ListSessions(r.Context(), f, limit, offset)
+	if err != nil {
+		if querytimeout.Aborted(w, r, "ListSessions") {
+			return
+		}
+		log.Printf("error occurred during ListSessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"limit":    limit,
+		"offset":   offset,
+		"sessions": sessions,
+	})
+}
+
+// GetSessionTimeline retrieves up to limit events of a session, oldest first, with totals
+// over the whole session. Returns nil when the session has no events
+func (s *ReportService) GetSessionTimeline(ctx context.Context, sessionID string, limit int) (*Timeline, error) {
+	timeline := &Timeline{SessionID: sessionID, UserIDs: []string{}, Events: []TimelineEvent{}}
+
+	totals, err := s.DB.QueryContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE `+failedCondition+`),
+			COALESCE(SUM(tokens_used), 0), COALESCE(SUM(response_time_seconds), 0),
+			MIN(created_at), MAX(created_at)
+		FROM audit_logs
+		WHERE session_id = $1`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer totals.Close()
+	var startedAt, endedAt database.Timestamp
+	if totals.Next() {
+		if err := totals.Scan(&timeline.EventCount, &timeline.FailedCount, &timeline.TokensUsed,
+			&timeline.ResponseTimeSeconds, &startedAt, &endedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := totals.Err(); err != nil {
+		return nil, err
+	}
+	totals.Close()
+	if timeline.EventCount == 0 {
+		return nil, nil
+	}
+	timeline.StartedAt = startedAt.Time.UTC().Format(time.RFC3339Nano)
+	timeline.EndedAt = endedAt.Time.UTC().Format(time.RFC3339Nano)
+	timeline.DurationSeconds = endedAt.Time.Sub(startedAt.Time).Seconds()
+	timeline.Truncated = timeline.EventCount > int64(limit)
+
+	// created_at is selected again at full precision for the gaps
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+auditLogColumns+`, created_at
+		FROM audit_logs
+		WHERE session_id = $1
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2`, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var previous time.Time
+	var cumulativeTokens int64
+	for rows.Next() {
+		var createdAt time.Time
+		logEntry, err := scanAuditLog(rows, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := revealPayloads(ctx, s.Crypto, &logEntry); err != nil {
+			return nil, err
+		}
+
+		event := TimelineEvent{AuditLog: logEntry, OffsetSeconds: createdAt.Sub(startedAt.Time).Seconds()}
+		if len(timeline.Events) > 0 {
+			gap := createdAt.Sub(previous).Seconds()
+			event.GapSeconds = &gap
+			timeline.LongestGapSeconds = max(timeline.LongestGapSeconds, gap)
+		}
+		outcome := logEntry.Outcome
+		if outcome == "" {
+			outcome = auditlog.OutcomeFromStatusCode(logEntry.StatusCode)
+		}
+		event.Failed = outcome != auditlog.OutcomeSuccess
+		if logEntry.TokensUsed != nil {
+			cumulativeTokens += int64(*logEntry.TokensUsed)
+		}
+		event.CumulativeTokens = cumulativeTokens
+		if logEntry.UserID != nil && !slices.Contains(timeline.UserIDs, *logEntry.UserID) {
+			timeline.UserIDs = append(timeline.UserIDs, *logEntry.UserID)
+		}
+
+		timeline.Events = append(timeline.Events, event)
+		previous = createdAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
+
+// ListSessions summarizes the sessions with events matching f, per session and user,
+// latest activity first
+func (s *ReportService) ListSessions(ctx context.Context, f filter.Filter, limit int, offset int) ([]SessionSummary, error) {
+	if f.From == nil {
+		from := time.Now().UTC().Add(-defaultSessionsWindow)
+		f.From = &from
+	}
+	where, args := f.Where(s.Dialect, nil)
+	limitParam := "$" + strconv.Itoa(len(args)+1)
+	offsetParam := "$" + strconv.Itoa(len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT session_id, user_id, MIN(created_at), MAX(created_at), COUNT(*),
+			COUNT(*) FILTER (WHERE `+failedCondition+`), COALESCE(SUM(tokens_used), 0)
+		FROM audit_logs
+		WHERE session_id IS NOT NULL`+where+`
+		GROUP BY session_id, user_id
+		ORDER BY MAX(created_at) DESC, session_id ASC
+		LIMIT `+limitParam+` OFFSET `+offsetParam, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var session SessionSummary
+		var userID *string
+		var startedAt, endedAt database.Timestamp
+		if err := rows.Scan(&session.SessionID, &userID, &startedAt, &endedAt,
+			&session.EventCount, &session.FailedCount, &session.TokensUsed); err != nil {
+			return nil, err
+		}
+		session.UserID = userID
+		session.StartedAt = startedAt.Time.UTC().Format(time.RFC3339Nano)
+		session.EndedAt = endedAt.Time.UTC().Format(time.RFC3339Nano)
+		session.DurationSeconds = endedAt.Time.Sub(startedAt.Time).Seconds()
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -1,6 +1,8 @@
 package buffer
 
 import (
+	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -8,52 +10,92 @@ import (
 	"github.com/spf13/viper"
 )
 
-var bufferInstance *Buffer
-
-func Get(datastore auditlog.AuditLogDatastore) *Buffer {
-	if bufferInstance == nil {
-		bufferInstance = NewBuffer(datastore)
-		return bufferInstance
-	}
-	return bufferInstance
+// Config sizes the buffer and controls how often it is flushed
+type Config struct {
+	MaxSize       int           // Max entries in buffer before new ones are dropped
+	FlushInterval time.Duration // Time-based flush interval
+	BatchSize     int           // Max entries per batch insert
 }
 
-type Buffer struct {
-	logChan      chan auditlog.AuditLog
-	maxSize      int
-	flushInterval time.Duration
-	batchSize    int
-	auditLogRepo auditlog.AuditLogDatastore
-}
-
-func NewBuffer(datastore auditlog.AuditLogDatastore) *Buffer {
+// LoadConfig reads the buffer settings from viper
+func LoadConfig() Config {
 	maxSize := viper.GetInt("AUDIT_BUFFER_MAX_SIZE")
-	if maxSize == 0 {
+	if maxSize <= 0 {
 		maxSize = 100 // default
 	}
 
 	flushIntervalSeconds := viper.GetInt("AUDIT_BUFFER_FLUSH_INTERVAL")
-	if flushIntervalSeconds == 0 {
+	if flushIntervalSeconds <= 0 {
 		flushIntervalSeconds = 30 // default
 	}
 
 	batchSize := viper.GetInt("AUDIT_BUFFER_BATCH_SIZE")
-	if batchSize == 0 {
+	if batchSize <= 0 {
 		batchSize = 100 // default
 	}
 
-	b := &Buffer{
-		logChan:      make(chan auditlog.AuditLog, maxSize), // Buffered channel
-		maxSize:      maxSize,
-		flushInterval: time.Duration(flushIntervalSeconds) * time.Second,
-		batchSize:    batchSize,
-		auditLogRepo: datastore,
+	return Config{
+		MaxSize:       maxSize,
+		FlushInterval: time.Duration(flushIntervalSeconds) * time.Second,
+		BatchSize:     batchSize,
 	}
+}
 
-	// Start background worker
+type Buffer struct {
+	logChan       chan auditlog.AuditLog
+	maxSize       int
+	flushInterval time.Duration
+	batchSize     int
+	auditLogRepo  auditlog.AuditLogDatastore
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// Validate rejects sizes and intervals the worker cannot run with
+func (c Config) Validate() error {
+	var errs []error
+	if c.MaxSize <= 0 {
+		errs = append(errs, errors.New("buffer max size must be positive"))
+	}
+	if c.FlushInterval <= 0 {
+		errs = append(errs, errors.New("buffer flush interval must be positive"))
+	}
+	if c.BatchSize <= 0 {
+		errs = append(errs, errors.New("buffer batch size must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// NewBuffer creates a buffer that flushes into datastore; Start runs its worker
+func NewBuffer(datastore auditlog.AuditLogDatastore, cfg Config) (*Buffer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Buffer{
+		logChan:       make(chan auditlog.AuditLog, cfg.MaxSize), // Buffered channel
+		maxSize:       cfg.MaxSize,
+		flushInterval: cfg.FlushInterval,
+		batchSize:     cfg.BatchSize,
+		auditLogRepo:  datastore,
+	}, nil
+}
+
+// Start runs the background worker that batches and flushes queued logs
+func (b *Buffer) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
 	go b.startWorker()
+}
 
-	return b
+// Stop flushes every queued log and stops the worker
+// Logs added after Stop stay queued until the buffer is full and are never flushed
+func (b *Buffer) Stop() {
+	if b.stop == nil {
+		return
+	}
+	close(b.stop)
+	<-b.done
+	b.stop = nil
 }
 
 // AddLogs adds audit logs to the buffer (non-blocking, thread-safe via channel)
@@ -74,6 +116,8 @@ func (b *Buffer) AddLogs(logs []auditlog.AuditLog) int {
 
 // startWorker runs in background, continuously consuming from channel and batching
 func (b *Buffer) startWorker() {
+	defer close(b.done)
+
 	batch := make([]auditlog.AuditLog, 0, b.batchSize)
 	ticker := time.NewTicker(b.flushInterval)
 	defer ticker.Stop()
@@ -96,6 +140,22 @@ func (b *Buffer) startWorker() {
 				b.flush(batch)
 				batch = batch[:0] // Reset batch slice but keep capacity
 			}
+
+		case <-b.stop:
+			// Drain what is already queued, then flush the rest
+			for {
+				select {
+				case logEntry := <-b.logChan:
+					batch = append(batch, logEntry)
+					if len(batch) >= b.batchSize {
+						b.flush(batch)
+						batch = batch[:0]
+					}
+				default:
+					b.flush(batch)
+					return
+				}
+			}
 		}
 	}
 }
@@ -107,7 +167,7 @@ func (b *Buffer) flush(logs []auditlog.AuditLog) {
 	}
 
 	// Perform batch insert
-	if err := b.auditLogRepo.BatchInsertAuditLogs(logs); err != nil {
+	if err := b.auditLogRepo.BatchInsertAuditLogs(context.Background(), logs); err != nil {
 		log.Printf("[AUDIT BUFFER ERROR] Failed to flush audit logs: %v", err)
 		// Optionally: re-add logs to channel or handle error differently
 	} else {
@@ -0,0 +1,205 @@
+package checkpoint
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+)
+
+// Supported checkpoint periods
+const (
+	PeriodHour = "hour"
+	PeriodDay  = "day"
+)
+
+// messageVersion prefixes every signed message so the format can change later
+const messageVersion = "sparksai-audit-checkpoint/v1"
+
+// Checkpoint is a signed Merkle root over the row hashes of one period
+type Checkpoint struct {
+	ID          int64  `json:"id"`
+	Period      string `json:"period"`
+	PeriodStart string `json:"period_start"`
+	PeriodEnd   string `json:"period_end"`
+	FirstID     *int64 `json:"first_id,omitempty"`
+	LastID      *int64 `json:"last_id,omitempty"`
+	LeafCount   int64  `json:"leaf_count"`
+	MerkleRoot  string `json:"merkle_root"`
+	Signature   string `json:"signature"` // base64 ed25519 signature of Message()
+	KeyID       string `json:"key_id"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+// Message is the exact byte string that is signed
+func (c Checkpoint) Message() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n%s\n%d\n%s\n",
+		messageVersion, c.Period, c.PeriodStart, c.PeriodEnd, c.LeafCount, c.MerkleRoot))
+}
+
+// Proof shows that one chained row (or its tombstone) is included in a signed checkpoint
+// It holds everything needed to verify offline with only the public key
+type Proof struct {
+	ID          int64      `json:"id"`
+	CreatedAt   string     `json:"created_at"`
+	PrevHash    string     `json:"prev_hash"`
+	ContentHash string     `json:"content_hash"`
+	RowHash     string     `json:"row_hash"`
+	Tombstone   bool       `json:"tombstone"`
+	LeafIndex   int64      `json:"leaf_index"`
+	Path        []string   `json:"path"` // Hex sibling hashes, leaf to root
+	Checkpoint  Checkpoint `json:"checkpoint"`
+}
+
+// KeyID identifies a public key: the first 16 hex characters of sha256(public key)
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// Sign fills in the signature and key id of a checkpoint
+func Sign(c *Checkpoint, key ed25519.PrivateKey) {
+	c.KeyID = KeyID(key.Public().(ed25519.PublicKey))
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, c.Message()))
+}
+
+// VerifySignature checks the checkpoint signature against a public key
+func VerifySignature(c Checkpoint, publicKey ed25519.PublicKey) error {
+	if c.KeyID != KeyID(publicKey) {
+		return fmt.Errorf("checkpoint was signed by key %s, not %s", c.KeyID, KeyID(publicKey))
+	}
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, c.Message(), signature) {
+		return errors.New("invalid checkpoint signature")
+	}
+	return nil
+}
+
+// VerifyProof checks a proof end to end: the row hash links prev_hash and content_hash,
+// the row lies in the checkpoint period, the Merkle path leads to the checkpoint root
+// and the checkpoint is signed by publicKey
+func VerifyProof(proof Proof, publicKey ed25519.PublicKey) error {
+	if expected := chain.RowHash(proof.PrevHash, proof.ContentHash); expected != proof.RowHash {
+		return fmt.Errorf("row_hash does not match prev_hash and content_hash (expected %s)", expected)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, proof.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("invalid created_at: %w", err)
+	}
+	periodStart, err := time.Parse(time.RFC3339, proof.Checkpoint.PeriodStart)
+	if err != nil {
+		return fmt.Errorf("invalid period_start: %w", err)
+	}
+	periodEnd, err := time.Parse(time.RFC3339, proof.Checkpoint.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("invalid period_end: %w", err)
+	}
+	if createdAt.Before(periodStart) || !createdAt.Before(periodEnd) {
+		return errors.New("row was created outside the checkpoint period")
+	}
+
+	leaves, err := Leaves([]string{proof.RowHash})
+	if err != nil {
+		return err
+	}
+	path := make([][]byte, len(proof.Path))
+	for i, sibling := range proof.Path {
+		if path[i], err = hex.DecodeString(sibling); err != nil {
+			return fmt.Errorf("invalid path hash %q: %w", sibling, err)
+		}
+	}
+	root, err := hex.DecodeString(proof.Checkpoint.MerkleRoot)
+	if err != nil {
+		return fmt.Errorf("invalid merkle_root: %w", err)
+	}
+	if !VerifyInclusion(leaves[0], proof.LeafIndex, proof.Checkpoint.LeafCount, path, root) {
+		return errors.New("inclusion path does not lead to the checkpoint root")
+	}
+
+	return VerifySignature(proof.Checkpoint, publicKey)
+}
+
+// GenerateKey creates a new signing key, writes it to path as a PKCS #8 PEM file
+// and returns the matching public key in PKIX PEM form
+func GenerateKey(path string) ([]byte, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	if err := os.WriteFile(path, privatePEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write private key: %w", err)
+	}
+	return EncodePublicKey(publicKey)
+}
+
+// EncodePublicKey returns a public key in PKIX PEM form
+func EncodePublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), nil
+}
+
+// LoadPrivateKey reads a PKCS #8 PEM ed25519 private key
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %s: %w", path, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return privateKey, nil
+}
+
+// LoadPublicKey reads a PKIX PEM ed25519 public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %w", path, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", path)
+	}
+	return publicKey, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
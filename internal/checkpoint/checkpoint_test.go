@@ -0,0 +1,171 @@
+package checkpoint
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+)
+
+// testProof builds a signed checkpoint over four chained rows and returns the proof for the third
+func testProof(t *testing.T, key ed25519.PrivateKey) Proof {
+	t.Helper()
+	prev := chain.GenesisHash
+	var prevHashes, contentHashes, rowHashes []string
+	for i := 0; i < 4; i++ {
+		content := chain.RowHash(chain.GenesisHash, strings.Repeat(string(rune('a'+i)), 64))
+		row := chain.RowHash(prev, content)
+		prevHashes = append(prevHashes, prev)
+		contentHashes = append(contentHashes, content)
+		rowHashes = append(rowHashes, row)
+		prev = row
+	}
+	leaves, err := Leaves(rowHashes)
+	if err != nil {
+		t.Fatalf("Leaves() error: %v", err)
+	}
+	c := Checkpoint{
+		Period:      PeriodHour,
+		PeriodStart: "2026-03-01T10:00:00Z",
+		PeriodEnd:   "2026-03-01T11:00:00Z",
+		LeafCount:   int64(len(leaves)),
+		MerkleRoot:  hex.EncodeToString(Root(leaves)),
+	}
+	Sign(&c, key)
+
+	var path []string
+	for _, sibling := range InclusionPath(2, leaves) {
+		path = append(path, hex.EncodeToString(sibling))
+	}
+	return Proof{
+		ID:          3,
+		CreatedAt:   "2026-03-01T10:30:00.123456Z",
+		PrevHash:    prevHashes[2],
+		ContentHash: contentHashes[2],
+		RowHash:     rowHashes[2],
+		LeafIndex:   2,
+		Path:        path,
+		Checkpoint:  c,
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	otherKey := ed25519.NewKeyFromSeed([]byte(strings.Repeat("k", ed25519.SeedSize)))
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name      string
+		mutate    func(p *Proof)
+		publicKey ed25519.PublicKey
+		wantErr   string
+	}{
+		{name: "valid"},
+		{
+			name:   "created at the start of the period",
+			mutate: func(p *Proof) { p.CreatedAt = "2026-03-01T10:00:00Z" },
+		},
+		{
+			name:    "tampered content hash",
+			mutate:  func(p *Proof) { p.ContentHash = chain.GenesisHash },
+			wantErr: "row_hash does not match",
+		},
+		{
+			name:    "tampered prev hash",
+			mutate:  func(p *Proof) { p.PrevHash = chain.GenesisHash },
+			wantErr: "row_hash does not match",
+		},
+		{
+			name:    "created at the end of the period",
+			mutate:  func(p *Proof) { p.CreatedAt = "2026-03-01T11:00:00Z" },
+			wantErr: "outside the checkpoint period",
+		},
+		{
+			name:    "created before the period",
+			mutate:  func(p *Proof) { p.CreatedAt = "2026-03-01T09:59:59.999999Z" },
+			wantErr: "outside the checkpoint period",
+		},
+		{
+			name:    "invalid created_at",
+			mutate:  func(p *Proof) { p.CreatedAt = "2026-03-01 10:30:00" },
+			wantErr: "invalid created_at",
+		},
+		{
+			name:    "invalid period_end",
+			mutate:  func(p *Proof) { p.Checkpoint.PeriodEnd = "tomorrow" },
+			wantErr: "invalid period_end",
+		},
+		{
+			name:    "other leaf index",
+			mutate:  func(p *Proof) { p.LeafIndex = 1 },
+			wantErr: "does not lead to the checkpoint root",
+		},
+		{
+			name:    "tampered path",
+			mutate:  func(p *Proof) { p.Path[0] = chain.GenesisHash },
+			wantErr: "does not lead to the checkpoint root",
+		},
+		{
+			name:    "path is not hex",
+			mutate:  func(p *Proof) { p.Path[1] = "zz" },
+			wantErr: "invalid path hash",
+		},
+		{
+			name:    "tampered merkle root",
+			mutate:  func(p *Proof) { p.Checkpoint.MerkleRoot = chain.GenesisHash },
+			wantErr: "does not lead to the checkpoint root",
+		},
+		{
+			name:    "other leaf count",
+			mutate:  func(p *Proof) { p.Checkpoint.LeafCount = 3 },
+			wantErr: "does not lead to the checkpoint root",
+		},
+		{
+			name:    "widened period with the old signature",
+			mutate:  func(p *Proof) { p.Checkpoint.PeriodStart = "2026-03-01T09:00:00Z" },
+			wantErr: "invalid checkpoint signature",
+		},
+		{
+			name:      "other public key",
+			publicKey: otherKey.Public().(ed25519.PublicKey),
+			wantErr:   "was signed by key",
+		},
+		{
+			name: "signed by another key under the same key id",
+			mutate: func(p *Proof) {
+				Sign(&p.Checkpoint, otherKey)
+				p.Checkpoint.KeyID = KeyID(publicKey)
+			},
+			wantErr: "invalid checkpoint signature",
+		},
+		{
+			name:    "signature is not base64",
+			mutate:  func(p *Proof) { p.Checkpoint.Signature = "!" },
+			wantErr: "invalid signature encoding",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := testProof(t, key)
+			if tt.mutate != nil {
+				tt.mutate(&proof)
+			}
+			verifyKey := publicKey
+			if tt.publicKey != nil {
+				verifyKey = tt.publicKey
+			}
+			err := VerifyProof(proof, verifyKey)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyProof() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyProof() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
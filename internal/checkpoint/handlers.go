@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ListCheckpointsHandler handles GET /api/audit-checkpoints
+// Query parameters: from, to (optional, RFC 3339 timestamp or YYYY-MM-DD, matched against period_start)
+func (m *Manager) ListCheckpointsHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	checkpoints, err := m.List(from, to)
+	if err != nil {
+		log.Printf("error occurred during ListCheckpoints: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoints)
+}
+
+// PublicKeyHandler handles GET /api/audit-checkpoints/public-key
+// Returns the PEM public key that verifies checkpoint signatures
+func (m *Manager) PublicKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if m.Key == nil {
+		http.Error(w, "Checkpoint signing key is not configured", http.StatusNotFound)
+		return
+	}
+	publicKey := m.Key.Public().(ed25519.PublicKey)
+	encoded, err := EncodePublicKey(publicKey)
+	if err != nil {
+		log.Printf("error occurred during EncodePublicKey: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key_id":     KeyID(publicKey),
+		"algorithm":  "ed25519",
+		"public_key": string(encoded),
+	})
+}
+
+// ProofHandler handles GET /api/audit-logs/{id}/proof
+// Returns the Merkle inclusion proof of an entry in its signed checkpoint
+func (m *Manager) ProofHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := m.Proof(id)
+	if errors.Is(err, ErrRootMismatch) {
+		log.Printf("[CHECKPOINT ERROR] Audit log %d: %v", id, err)
+		http.Error(w, "Audit data no longer matches the signed checkpoint", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("error occurred during Proof: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if proof == nil {
+		http.Error(w, "Audit log entry not found or not checkpointed yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proof)
+}
+
+// RunHandler handles POST /api/admin/checkpoints/run
+// Signs checkpoints for completed periods immediately instead of waiting for the background job
+func (m *Manager) RunHandler(w http.ResponseWriter, r *http.Request) {
+	if m.Key == nil {
+		http.Error(w, "Checkpoint signing key is not configured", http.StatusConflict)
+		return
+	}
+
+	created, err := m.Run()
+	if err != nil {
+		log.Printf("error occurred during RunCheckpoints: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if created == nil {
+		created = []Checkpoint{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"created": created,
+	})
+}
+
+// parseTime parses an optional RFC 3339 timestamp or YYYY-MM-DD date
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	parsed = parsed.UTC()
+	return &parsed, nil
+}
@@ -0,0 +1,408 @@
+package checkpoint
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+	"github.com/spf13/viper"
+)
+
+// settleDelay is how long after a period ends before it is checkpointed,
+// leaving room for clock skew between service instances
+const settleDelay = time.Minute
+
+// maxPeriodsPerRun bounds the backfill done by a single run
+const maxPeriodsPerRun = 168
+
+// ErrRootMismatch is returned when the rows of a checkpointed period no longer
+// produce the signed Merkle root, i.e. the period was rewritten after signing
+var ErrRootMismatch = errors.New("rows no longer match the signed checkpoint root")
+
+// Manager builds and signs a checkpoint for every completed period and serves inclusion proofs
+type Manager struct {
+	DB            *sql.DB
+	Key           ed25519.PrivateKey // Nil when no key is configured
+	KeyErr        error              // Set when AUDIT_CHECKPOINT_KEY_FILE could not be loaded
+	Enabled       bool
+	Period        string        // hour or day
+	CheckInterval time.Duration // How often Run is called in the background
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// Config controls checkpoint signing
+type Config struct {
+	Enabled       bool
+	KeyFile       string        // PKCS #8 PEM ed25519 private key
+	Period        string        // hour or day
+	CheckInterval time.Duration // How often Run is called in the background
+}
+
+// LoadConfig reads the checkpoint settings from viper
+func LoadConfig() Config {
+	period := strings.ToLower(viper.GetString("AUDIT_CHECKPOINT_PERIOD"))
+	if period != PeriodHour {
+		period = PeriodDay // default
+	}
+
+	checkIntervalMinutes := viper.GetInt("AUDIT_CHECKPOINT_INTERVAL")
+	if checkIntervalMinutes <= 0 {
+		checkIntervalMinutes = 60 // default
+	}
+
+	return Config{
+		Enabled:       viper.GetBool("AUDIT_CHECKPOINT_ENABLED"),
+		KeyFile:       viper.GetString("AUDIT_CHECKPOINT_KEY_FILE"),
+		Period:        period,
+		CheckInterval: time.Duration(checkIntervalMinutes) * time.Minute,
+	}
+}
+
+// NewManager loads the signing key; checkpoints are disabled when it cannot be loaded
+func NewManager(db *sql.DB, cfg Config) *Manager {
+	var key ed25519.PrivateKey
+	var keyErr error
+	if cfg.KeyFile != "" {
+		key, keyErr = LoadPrivateKey(cfg.KeyFile)
+	} else {
+		keyErr = errors.New("AUDIT_CHECKPOINT_KEY_FILE is not set")
+	}
+	enabled := cfg.Enabled
+	if enabled && keyErr != nil {
+		log.Printf("[CHECKPOINT ERROR] %v - checkpoints are disabled", keyErr)
+		enabled = false
+	}
+
+	return &Manager{
+		DB:            db,
+		Key:           key,
+		KeyErr:        keyErr,
+		Enabled:       enabled,
+		Period:        cfg.Period,
+		CheckInterval: cfg.CheckInterval,
+	}
+}
+
+// Start runs Run now and then periodically in the background when checkpoints are enabled
+func (m *Manager) Start() {
+	if !m.Enabled {
+		log.Printf("[CHECKPOINT] Scheduled checkpoints disabled")
+		return
+	}
+	log.Printf("[CHECKPOINT] Signing %s checkpoints with key %s", m.Period, KeyID(m.Key.Public().(ed25519.PublicKey)))
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.CheckInterval)
+		defer ticker.Stop()
+		for {
+			if created, err := m.Run(); err != nil {
+				log.Printf("[CHECKPOINT ERROR] Failed to build checkpoints: %v", err)
+			} else if len(created) > 0 {
+				log.Printf("[CHECKPOINT] Signed %d checkpoint(s) up to %s", len(created), created[len(created)-1].PeriodEnd)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background job, waiting for a run in progress
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+// Run signs a checkpoint for every completed period after the latest existing one
+// (or from the first chained row), up to maxPeriodsPerRun periods
+func (m *Manager) Run() ([]Checkpoint, error) {
+	if m.Key == nil {
+		return nil, m.KeyErr
+	}
+
+	// Wait for chain appends in flight: they hold the chain lock until commit
+	if err := m.waitForAppends(); err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().UTC().Add(-settleDelay)
+
+	start, err := m.nextPeriodStart()
+	if err != nil || start.IsZero() {
+		return nil, err
+	}
+
+	var created []Checkpoint
+	for i := 0; i < maxPeriodsPerRun; i++ {
+		end := m.nextPeriod(start)
+		if end.After(cutoff) {
+			break
+		}
+		checkpoint, err := m.build(start, end)
+		if err != nil {
+			return created, err
+		}
+		if checkpoint != nil {
+			created = append(created, *checkpoint)
+		}
+		start = end
+	}
+	return created, nil
+}
+
+func (m *Manager) waitForAppends() error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, chain.LockKey); err != nil {
+		return fmt.Errorf("failed to acquire chain lock: %w", err)
+	}
+	return tx.Commit()
+}
+
+// nextPeriodStart returns the start of the first period without a checkpoint,
+// or the zero time when there is nothing to checkpoint yet
+func (m *Manager) nextPeriodStart() (time.Time, error) {
+	var lastEnd sql.NullTime
+	err := m.DB.QueryRow(`SELECT MAX(period_end) FROM audit_checkpoints WHERE period = $1`, m.Period).Scan(&lastEnd)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read latest checkpoint: %w", err)
+	}
+	if lastEnd.Valid {
+		return lastEnd.Time.UTC(), nil
+	}
+
+	var first sql.NullTime
+	err = m.DB.QueryRow(`
+		SELECT MIN(created_at) FROM (
+			SELECT MIN(created_at) AS created_at FROM audit_logs WHERE row_hash IS NOT NULL
+			UNION ALL
+			SELECT MIN(created_at) FROM audit_chain_tombstones
+		) firsts`).Scan(&first)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read first chained row: %w", err)
+	}
+	if !first.Valid {
+		return time.Time{}, nil
+	}
+	return m.periodStart(first.Time.UTC()), nil
+}
+
+// build signs and stores the checkpoint of one period
+// Returns nil when another instance stored it first
+func (m *Manager) build(start time.Time, end time.Time) (*Checkpoint, error) {
+	ids, rowHashes, err := m.periodLeaves(start, end)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := Leaves(rowHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{
+		Period:      m.Period,
+		PeriodStart: start.Format(time.RFC3339),
+		PeriodEnd:   end.Format(time.RFC3339),
+		LeafCount:   int64(len(leaves)),
+		MerkleRoot:  hex.EncodeToString(Root(leaves)),
+	}
+	if len(ids) > 0 {
+		checkpoint.FirstID = &ids[0]
+		checkpoint.LastID = &ids[len(ids)-1]
+	}
+	Sign(checkpoint, m.Key)
+
+	var createdAt time.Time
+	err = m.DB.QueryRow(`
+		INSERT INTO audit_checkpoints (period, period_start, period_end, first_id, last_id, leaf_count, merkle_root, signature, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (period, period_start) DO NOTHING
+		RETURNING id, created_at`,
+		checkpoint.Period, start, end, checkpoint.FirstID, checkpoint.LastID,
+		checkpoint.LeafCount, checkpoint.MerkleRoot, checkpoint.Signature, checkpoint.KeyID,
+	).Scan(&checkpoint.ID, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store checkpoint: %w", err)
+	}
+	checkpoint.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return checkpoint, nil
+}
+
+// periodLeaves returns the ids and row hashes of every chained row created in [start, end),
+// including rows since removed by retention (tombstones), in id order
+func (m *Manager) periodLeaves(start time.Time, end time.Time) ([]int64, []string, error) {
+	rows, err := m.DB.Query(`
+		SELECT id, row_hash FROM audit_logs
+		WHERE row_hash IS NOT NULL AND created_at >= $1 AND created_at < $2
+		UNION
+		SELECT id, row_hash FROM audit_chain_tombstones
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY 1 ASC`, start, end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query checkpoint leaves: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var rowHashes []string
+	for rows.Next() {
+		var id int64
+		var rowHash string
+		if err := rows.Scan(&id, &rowHash); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan checkpoint leaf: %w", err)
+		}
+		ids = append(ids, id)
+		rowHashes = append(rowHashes, rowHash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating checkpoint leaves: %w", err)
+	}
+	return ids, rowHashes, nil
+}
+
+// Proof builds the inclusion proof of one chained row in its checkpoint
+// Returns nil when the row does not exist or its period has no checkpoint yet
+func (m *Manager) Proof(id int64) (*Proof, error) {
+	proof := &Proof{}
+	var createdAt time.Time
+	err := m.DB.QueryRow(`
+		SELECT id, created_at, prev_hash, content_hash, row_hash, FALSE
+		FROM audit_logs WHERE id = $1 AND row_hash IS NOT NULL
+		UNION ALL
+		SELECT id, created_at, prev_hash, content_hash, row_hash, TRUE
+		FROM audit_chain_tombstones WHERE id = $1
+		LIMIT 1`, id).Scan(&proof.ID, &createdAt, &proof.PrevHash, &proof.ContentHash, &proof.RowHash, &proof.Tombstone)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log %d: %w", id, err)
+	}
+	proof.CreatedAt = chain.FormatTime(createdAt)
+
+	// Prefer the finest checkpoint when both hourly and daily ones exist
+	checkpoints, err := m.list(`WHERE period_start <= $1 AND period_end > $1 ORDER BY period_end - period_start ASC LIMIT 1`, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	proof.Checkpoint = checkpoints[0]
+
+	start, _ := time.Parse(time.RFC3339, proof.Checkpoint.PeriodStart)
+	end, _ := time.Parse(time.RFC3339, proof.Checkpoint.PeriodEnd)
+	ids, rowHashes, err := m.periodLeaves(start, end)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := Leaves(rowHashes)
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(Root(leaves)) != proof.Checkpoint.MerkleRoot {
+		return nil, ErrRootMismatch
+	}
+
+	proof.LeafIndex = -1
+	for i := range ids {
+		if ids[i] == proof.ID && rowHashes[i] == proof.RowHash {
+			proof.LeafIndex = int64(i)
+			break
+		}
+	}
+	if proof.LeafIndex < 0 {
+		return nil, ErrRootMismatch
+	}
+	proof.Path = []string{}
+	for _, sibling := range InclusionPath(int(proof.LeafIndex), leaves) {
+		proof.Path = append(proof.Path, hex.EncodeToString(sibling))
+	}
+	return proof, nil
+}
+
+// List returns checkpoints whose period starts in [from, to), oldest first (max 500)
+func (m *Manager) List(from *time.Time, to *time.Time) ([]Checkpoint, error) {
+	where := `WHERE period_start >= $1 AND period_start < $2`
+	lower := time.Unix(0, 0).UTC()
+	upper := time.Now().UTC()
+	if from != nil {
+		lower = *from
+	}
+	if to != nil {
+		upper = *to
+	}
+	return m.list(where+` ORDER BY period_start ASC, period ASC LIMIT 500`, lower, upper)
+}
+
+func (m *Manager) list(where string, args ...interface{}) ([]Checkpoint, error) {
+	rows, err := m.DB.Query(`
+		SELECT id, period, period_start, period_end, first_id, last_id, leaf_count, merkle_root, signature, key_id, created_at
+		FROM audit_checkpoints `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		var c Checkpoint
+		var periodStart, periodEnd, createdAt time.Time
+		var firstID, lastID sql.NullInt64
+		err := rows.Scan(&c.ID, &c.Period, &periodStart, &periodEnd, &firstID, &lastID,
+			&c.LeafCount, &c.MerkleRoot, &c.Signature, &c.KeyID, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint: %w", err)
+		}
+		c.PeriodStart = periodStart.UTC().Format(time.RFC3339)
+		c.PeriodEnd = periodEnd.UTC().Format(time.RFC3339)
+		c.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		if firstID.Valid {
+			c.FirstID = &firstID.Int64
+		}
+		if lastID.Valid {
+			c.LastID = &lastID.Int64
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// periodStart truncates t to the start of its checkpoint period (UTC)
+func (m *Manager) periodStart(t time.Time) time.Time {
+	if m.Period == PeriodHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (m *Manager) nextPeriod(start time.Time) time.Time {
+	if m.Period == PeriodHour {
+		return start.Add(time.Hour)
+	}
+	return start.AddDate(0, 0, 1)
+}
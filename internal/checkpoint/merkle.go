@@ -0,0 +1,101 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// The Merkle tree follows RFC 6962 (Certificate Transparency): leaves and interior
+// nodes are hashed with different prefixes so a leaf can never pass as a node
+
+// LeafHash hashes one leaf: sha256(0x00 || leaf)
+func LeafHash(leaf []byte) []byte {
+	sum := sha256.Sum256(append([]byte{0x00}, leaf...))
+	return sum[:]
+}
+
+// nodeHash hashes two children: sha256(0x01 || left || right)
+func nodeHash(left []byte, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, 0x01)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+// Leaves decodes hex row hashes into leaf hashes
+func Leaves(rowHashes []string) ([][]byte, error) {
+	leaves := make([][]byte, len(rowHashes))
+	for i, rowHash := range rowHashes {
+		raw, err := hex.DecodeString(rowHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row hash %q: %w", rowHash, err)
+		}
+		leaves[i] = LeafHash(raw)
+	}
+	return leaves, nil
+}
+
+// Root computes the Merkle tree hash over leaf hashes
+// The root of an empty tree is sha256 of the empty string
+func Root(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return nodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// InclusionPath returns the sibling hashes from leaf index up to the root
+func InclusionPath(index int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(InclusionPath(index, leaves[:k]), Root(leaves[k:]))
+	}
+	return append(InclusionPath(index-k, leaves[k:]), Root(leaves[:k]))
+}
+
+// VerifyInclusion checks that leafHash is at index in a tree of treeSize leaves with the given root
+// Implements the verification algorithm of RFC 9162 section 2.1.3.2
+func VerifyInclusion(leafHash []byte, index int64, treeSize int64, path [][]byte, root []byte) bool {
+	if index < 0 || index >= treeSize {
+		return false
+	}
+	fn, sn := index, treeSize-1
+	r := leafHash
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && hex.EncodeToString(r) == hex.EncodeToString(root)
+}
+
+// splitPoint returns the largest power of two smaller than n (n > 1)
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
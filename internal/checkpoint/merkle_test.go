@@ -0,0 +1,105 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// rfc6962Inputs are the leaf inputs of the Certificate Transparency reference tree
+var rfc6962Inputs = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+func TestRoot(t *testing.T) {
+	tests := []struct {
+		size int
+		want string
+	}{
+		{0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{1, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"},
+		{2, "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125"},
+		{3, "aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77"},
+		{4, "d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7"},
+		{5, "4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4"},
+		{6, "76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef"},
+		{7, "ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c"},
+		{8, "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328"},
+	}
+	for _, tt := range tests {
+		leaves, err := Leaves(rfc6962Inputs[:tt.size])
+		if err != nil {
+			t.Fatalf("Leaves() error: %v", err)
+		}
+		if got := hex.EncodeToString(Root(leaves)); got != tt.want {
+			t.Errorf("Root() of %d leaves = %s, want %s", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestLeavesInvalidHash(t *testing.T) {
+	if _, err := Leaves([]string{"00", "not hex"}); err == nil {
+		t.Error("Leaves() accepted a row hash that is not hex")
+	}
+}
+
+func TestInclusionPathVerifies(t *testing.T) {
+	all, err := Leaves(rfc6962Inputs)
+	if err != nil {
+		t.Fatalf("Leaves() error: %v", err)
+	}
+	for size := 1; size <= len(all); size++ {
+		leaves := all[:size]
+		root := Root(leaves)
+		for index := 0; index < size; index++ {
+			path := InclusionPath(index, leaves)
+			if !VerifyInclusion(leaves[index], int64(index), int64(size), path, root) {
+				t.Errorf("leaf %d of %d: path %d hashes does not verify", index, size, len(path))
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionRejects(t *testing.T) {
+	leaves, err := Leaves(rfc6962Inputs[:7])
+	if err != nil {
+		t.Fatalf("Leaves() error: %v", err)
+	}
+	root := Root(leaves)
+	path := InclusionPath(4, leaves)
+
+	tests := []struct {
+		name     string
+		leaf     []byte
+		index    int64
+		treeSize int64
+		path     [][]byte
+		root     []byte
+	}{
+		{"other leaf", leaves[3], 4, 7, path, root},
+		{"other index", leaves[4], 5, 7, path, root},
+		{"negative index", leaves[4], -1, 7, path, root},
+		{"index past the tree", leaves[4], 7, 7, path, root},
+		{"larger tree", leaves[4], 4, 16, path, root},
+		{"smaller tree", leaves[4], 4, 5, path, root},
+		{"truncated path", leaves[4], 4, 7, path[:len(path)-1], root},
+		{"extra hash", leaves[4], 4, 7, append(append([][]byte{}, path...), root), root},
+		{"tampered hash", leaves[4], 4, 7, [][]byte{path[0], bytes.Repeat([]byte{0xff}, 32), path[2]}, root},
+		{"other root", leaves[4], 4, 7, path, Root(leaves[:6])},
+		{"leaf hash used as node", root, 0, 1, nil, leaves[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyInclusion(tt.leaf, tt.index, tt.treeSize, tt.path, tt.root) {
+				t.Error("VerifyInclusion() = true, want false")
+			}
+		})
+	}
+}
@@ -5,38 +5,236 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/motiso/sparksai-audit-service/internal/db/migrate"
 	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
 )
 
 // Database application name for PostgreSQL connection identification
 const DB_APPLICATION_NAME = "SparksAI-Audit"
 
-var db *sql.DB
+// Config locates the database and controls how the schema is managed
+type Config struct {
+	Driver              Dialect // postgres or sqlite
+	DSN                 string  // key=value DSN or postgres:// URL; replaces Host, Port, User, Password and Name
+	Host                string
+	Port                string
+	User                string
+	Password            string
+	Name                string
+	SSLMode             string // Overrides the DSN's sslmode; disable when neither sets one
+	SSLRootCert         string // CA certificate file for verify-ca / verify-full
+	SSLCert             string // Client certificate file
+	SSLKey              string // Client key file
+	CreateDatabase      bool   // Create the database (via the postgres database) when it is missing
+	MaintenanceUser     string // Member of audit_maintenance; empty to use the main pool
+	MaintenancePassword string
+	SQLitePath          string
+	AutoMigrate         bool // Apply pending migrations on Open
 
-func initDB() {
-	db = connectDB()
-	db.SetMaxIdleConns(4)
-	db.SetMaxOpenConns(4)
+	// Writes (ingest, maintenance) and reads (reports, listings) use separate pools
+	WritePoolSize        int
+	ReadPoolSize         int           // Per read pool: the primary read pool and each replica
+	ReadReplicas         []string      // Replica DSNs (key=value or postgres:// URL)
+	ReplicaMaxLag        time.Duration // Replicas further behind are skipped
+	ReplicaCheckInterval time.Duration // How often replica health and lag are checked
+	ConnMaxLifetime      time.Duration // Zero keeps connections open indefinitely
+	ConnMaxIdleTime      time.Duration // Zero keeps idle connections open indefinitely
 }
 
-func Get() *sql.DB {
-	if db == nil {
-		initDB()
+// LoadConfig reads the database settings from viper
+func LoadConfig() Config {
+	driver := Postgres
+	if strings.ToLower(viper.GetString("DB_DRIVER")) == string(SQLite) {
+		driver = SQLite
+	}
+
+	sqlitePath := viper.GetString("SQLITE_PATH")
+	if sqlitePath == "" {
+		sqlitePath = "audit.db" // default, relative to the working directory
+	}
+
+	// AUTO_MIGRATE defaults to true; migrations can also be run separately (audit_service migrate)
+	autoMigrate := true
+	if viper.IsSet("AUTO_MIGRATE") {
+		autoMigrate = viper.GetBool("AUTO_MIGRATE")
+	}
+
+	// Managed PostgreSQL often forbids CREATE DATABASE and connections to the postgres database
+	createDatabase := true
+	if viper.IsSet("POSTGRES_CREATE_DATABASE") {
+		createDatabase = viper.GetBool("POSTGRES_CREATE_DATABASE")
+	}
+
+	writePoolSize := viper.GetInt("POSTGRES_WRITE_POOL_SIZE")
+	if writePoolSize <= 0 {
+		writePoolSize = 4 // default
+	}
+
+	readPoolSize := viper.GetInt("POSTGRES_READ_POOL_SIZE")
+	if readPoolSize <= 0 {
+		readPoolSize = 4 // default
+	}
+
+	var replicas []string
+	for _, dsn := range strings.Split(viper.GetString("POSTGRES_READ_REPLICAS"), ";") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicas = append(replicas, dsn)
+		}
+	}
+
+	maxLagSeconds := viper.GetInt("POSTGRES_REPLICA_MAX_LAG")
+	if maxLagSeconds <= 0 {
+		maxLagSeconds = 10 // default
+	}
+
+	checkIntervalSeconds := viper.GetInt("POSTGRES_REPLICA_CHECK_INTERVAL")
+	if checkIntervalSeconds <= 0 {
+		checkIntervalSeconds = 5 // default
+	}
+
+	return Config{
+		Driver:              driver,
+		DSN:                 viper.GetString("POSTGRES_DSN"),
+		Host:                viper.GetString("POSTGRES_HOST"),
+		Port:                viper.GetString("POSTGRES_PORT"),
+		User:                viper.GetString("POSTGRES_USER"),
+		Password:            viper.GetString("POSTGRES_PASSWORD"),
+		Name:                strings.ToLower(viper.GetString("POSTGRES_DB")), // Standardize to lowercase
+		SSLMode:             viper.GetString("POSTGRES_SSLMODE"),
+		SSLRootCert:         viper.GetString("POSTGRES_SSLROOTCERT"),
+		SSLCert:             viper.GetString("POSTGRES_SSLCERT"),
+		SSLKey:              viper.GetString("POSTGRES_SSLKEY"),
+		CreateDatabase:      createDatabase,
+		MaintenanceUser:     viper.GetString("POSTGRES_MAINTENANCE_USER"),
+		MaintenancePassword: viper.GetString("POSTGRES_MAINTENANCE_PASSWORD"),
+		SQLitePath:          sqlitePath,
+		AutoMigrate:         autoMigrate,
+
+		WritePoolSize:        writePoolSize,
+		ReadPoolSize:         readPoolSize,
+		ReadReplicas:         replicas,
+		ReplicaMaxLag:        time.Duration(maxLagSeconds) * time.Second,
+		ReplicaCheckInterval: time.Duration(checkIntervalSeconds) * time.Second,
+		ConnMaxLifetime:      time.Duration(viper.GetInt("POSTGRES_CONN_MAX_LIFETIME")) * time.Second,
+		ConnMaxIdleTime:      time.Duration(viper.GetInt("POSTGRES_CONN_MAX_IDLE_TIME")) * time.Second,
 	}
-	return db
 }
 
-func getDBConfig() (username string, password string,
-	databasename string, databaseHost string, databasePort string) {
+// Open connects the main (write) pool and brings the schema up to date when AutoMigrate is set
+func Open(cfg Config) (*sql.DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == SQLite {
+		// SQLite allows a single writer; one connection serializes every statement
+		db.SetMaxOpenConns(1)
+	} else {
+		configurePool(db, cfg.WritePoolSize, cfg)
+	}
 
-	databasename = strings.ToLower(viper.GetString("POSTGRES_DB")) // Standardize to lowercase
-	databaseHost = viper.GetString("POSTGRES_HOST")
-	username = viper.GetString("POSTGRES_USER")
-	password = viper.GetString("POSTGRES_PASSWORD")
-	databasePort = viper.GetString("POSTGRES_PORT")
-	return
+	if cfg.AutoMigrate {
+		migrator, err := migrate.New(db, string(cfg.Driver))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+		if _, err := migrator.Up(0); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// Connect opens a connection (creating the database if needed) without running migrations
+// Used by the migrate command, which manages the schema itself
+func Connect(cfg Config) (*sql.DB, error) {
+	if cfg.Driver == SQLite {
+		return connectSQLite(cfg)
+	}
+	return connectDB(cfg)
+}
+
+// OpenMaintenance returns the pool used by retention and erasure jobs
+// It connects as MaintenanceUser, a member of the audit_maintenance role that the
+// append-only triggers exempt. Without it nil is returned: the triggers reject every
+// delete and update on the main connection, so the jobs that remove audit rows are disabled
+func OpenMaintenance(cfg Config, mainDB *sql.DB) (*sql.DB, error) {
+	// SQLite has no roles or append-only triggers
+	if cfg.Driver == SQLite {
+		return mainDB, nil
+	}
+
+	if cfg.MaintenanceUser == "" {
+		log.Printf("[DB] POSTGRES_MAINTENANCE_USER is not set - retention, erasure and partition removal are disabled")
+		return nil, nil
+	}
+
+	params, err := cfg.postgresParams(cfg.MaintenanceUser, cfg.MaintenancePassword, DB_APPLICATION_NAME+"-Maintenance")
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Connecting maintenance pool: %s", describeDSN(params))
+
+	conn, err := sql.Open("postgres", formatDSN(params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect maintenance pool: %w", err)
+	}
+	configurePool(conn, 2, cfg)
+	return conn, nil
+}
+
+// postgresParams returns the connection parameters of the configured database
+// A non-empty user (with its password) replaces the configured one
+func (cfg Config) postgresParams(user string, password string, applicationName string) (map[string]string, error) {
+	params := map[string]string{}
+	if cfg.DSN != "" {
+		parsed, err := parseDSN(cfg.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POSTGRES_DSN: %w", err)
+		}
+		params = parsed
+	} else {
+		for key, value := range map[string]string{
+			"host": cfg.Host, "port": cfg.Port, "user": cfg.User, "password": cfg.Password, "dbname": cfg.Name,
+		} {
+			if value != "" {
+				params[key] = value
+			}
+		}
+	}
+
+	if user != "" {
+		params["user"] = user
+		params["password"] = password
+	}
+	if cfg.SSLMode != "" {
+		params["sslmode"] = cfg.SSLMode
+	} else if params["sslmode"] == "" {
+		params["sslmode"] = "disable"
+	}
+	for key, value := range map[string]string{
+		"sslrootcert": cfg.SSLRootCert, "sslcert": cfg.SSLCert, "sslkey": cfg.SSLKey,
+	} {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	params["application_name"] = applicationName
+	return params, nil
+}
+
+// configurePool sizes a pool and applies the connection lifetime settings
+func configurePool(conn *sql.DB, size int, cfg Config) {
+	conn.SetMaxIdleConns(size)
+	conn.SetMaxOpenConns(size)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 }
 
 func checkDBExists(db *sql.DB, dbName string) (bool, error) {
@@ -46,92 +244,81 @@ func checkDBExists(db *sql.DB, dbName string) (bool, error) {
 	return exists, err
 }
 
-func createAndOpen(name string, dsn string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", dsn+" dbname=postgres")
+// createDatabase creates the database named in params unless it exists, connecting
+// through the postgres maintenance database
+func createDatabase(params map[string]string) error {
+	name := params["dbname"]
+	adminParams := map[string]string{}
+	for key, value := range params {
+		adminParams[key] = value
+	}
+	adminParams["dbname"] = "postgres"
+
+	db, err := sql.Open("postgres", formatDSN(adminParams))
 	if err != nil {
-		log.Fatal("Error connecting to PostgreSQL:", err)
+		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
 	defer db.Close()
 
-	// Create a new database
 	exists, err := checkDBExists(db, name)
 	if err != nil {
-		log.Fatal("Error checking database existence:", err)
+		return fmt.Errorf("failed to check whether database %s exists (set POSTGRES_CREATE_DATABASE=false if it is managed elsewhere): %w", name, err)
 	}
 
 	if exists {
 		log.Println("Database already exists:", name)
-	} else {
-		// Create the database if it does not exist, with quoting
-		_, err = db.Exec(fmt.Sprintf(`CREATE DATABASE "%s"`, name))
-		if err != nil {
-			log.Fatal("Error creating database:", err)
-		}
-		log.Println("Database created successfully:", name)
+		return nil
 	}
 
-	newConnStr := fmt.Sprintf(dsn+" dbname=%s", name)
-	newDB, err := sql.Open("postgres", newConnStr)
-	if err != nil {
-		log.Fatal("Error connecting to new database:", err)
-	}
-
-	statements := []string{
-		`CREATE EXTENSION IF NOT EXISTS "pgcrypto";`,
-		`CREATE TABLE IF NOT EXISTS audit_logs (
-			id SERIAL PRIMARY KEY,
-			user_id VARCHAR(255),
-			severity VARCHAR(20) DEFAULT 'NONE' NOT NULL,
-			endpoint_path VARCHAR(500) NOT NULL,
-			session_id VARCHAR(255),
-			action VARCHAR(255),
-			action_date TIMESTAMP WITH TIME ZONE,
-			count INTEGER,
-			http_method VARCHAR(20) NOT NULL,
-			status_code INTEGER NOT NULL,
-			response_time_seconds NUMERIC(10, 3) NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			ip_address INET,
-			user_agent TEXT,
-			chat_history_id INTEGER,
-			insights_id INTEGER,
-			tokens_used INTEGER,
-			query_raw JSONB,
-			body_raw JSONB,
-			response_body JSONB
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);`,
-		`CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);`,
-		`CREATE INDEX IF NOT EXISTS idx_audit_logs_severity ON audit_logs(severity);`,
-		`CREATE INDEX IF NOT EXISTS idx_audit_logs_body_raw ON audit_logs USING GIN (body_raw);`,
-	}
-
-	// Execute table creation statements
-	for _, stmt := range statements {
-		if _, err := newDB.Exec(stmt); err != nil {
-			return nil, err
-		}
+	// Create the database if it does not exist, with quoting
+	if _, err := db.Exec(`CREATE DATABASE ` + pq.QuoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", name, err)
 	}
-
-	return newDB, nil
+	log.Println("Database created successfully:", name)
+	return nil
 }
 
-func connectDB() *sql.DB {
-	username, password, databasename, databaseHost, databasePort := getDBConfig()
+func connectDB(cfg Config) (*sql.DB, error) {
+	params, err := cfg.postgresParams("", "", DB_APPLICATION_NAME)
+	if err != nil {
+		return nil, err
+	}
 
 	// Debug: Print database config (without password)
-	log.Printf("Connecting to database: host=%s port=%s user=%s db=%s", databaseHost, databasePort, username, databasename)
+	log.Printf("Connecting to database: %s", describeDSN(params))
 
-	dbURI := fmt.Sprintf("host=%s port=%s user=%s password=%s sslmode=disable application_name=%s ", databaseHost, databasePort, username, password, DB_APPLICATION_NAME)
+	if cfg.CreateDatabase && params["dbname"] != "" {
+		if err := createDatabase(params); err != nil {
+			return nil, err
+		}
+	}
 
-	//connect to db URI
-	db, err := createAndOpen(databasename, dbURI)
+	db, err := sql.Open("postgres", formatDSN(params))
 	if err != nil {
-		fmt.Println("error", err)
-		log.Fatalf(err.Error())
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-
-	return db
+	// sql.Open does not connect, so check the settings now rather than on the first query
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database %s: %w", params["dbname"], err)
+	}
+	return db, nil
 }
 
+// connectSQLite opens the embedded SQLite database file
+func connectSQLite(cfg Config) (*sql.DB, error) {
+	path := cfg.SQLitePath
+	log.Printf("Opening SQLite database: %s", path)
+
+	// Times are stored as "YYYY-MM-DD HH:MM:SS.sss+00:00" text, which SQLite date functions understand
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_time_format=sqlite"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Dialect is the SQL database behind the service, selected with DB_DRIVER (default postgres)
+type Dialect string
+
+// Supported dialects
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// Date renders expr (a timestamp) as YYYY-MM-DD text
+func (d Dialect) Date(expr string) string {
+	if d == SQLite {
+		return "DATE(" + expr + ")"
+	}
+	return "to_char(" + expr + ", 'YYYY-MM-DD')"
+}
+
+// DayOfMonth renders the day of month of expr (a timestamp) as an integer
+func (d Dialect) DayOfMonth(expr string) string {
+	if d == SQLite {
+		return "CAST(strftime('%d', " + expr + ") AS INTEGER)"
+	}
+	return "EXTRACT(DAY FROM " + expr + ")::integer"
+}
+
+// ILike is the case-insensitive LIKE operator (SQLite LIKE ignores ASCII case already)
+func (d Dialect) ILike() string {
+	if d == SQLite {
+		return "LIKE"
+	}
+	return "ILIKE"
+}
+
+// sqliteTimeLayout is how timestamps are stored with _time_format=sqlite
+const sqliteTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+// Timestamp scans a nullable timestamp, including aggregates such as MIN(created_at),
+// which SQLite returns as text because they have no declared column type
+type Timestamp struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner
+func (t *Timestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+	case time.Time:
+		t.Time, t.Valid = v, true
+	case string:
+		parsed, err := time.Parse(sqliteTimeLayout, v)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", v, err)
+		}
+		t.Time, t.Valid = parsed, true
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", value)
+	}
+	return nil
+}
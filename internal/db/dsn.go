@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// parseDSN parses a key=value connection string or a postgres:// URL into its parameters
+func parseDSN(dsn string) (map[string]string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		converted, err := pq.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		dsn = converted
+	}
+
+	params := map[string]string{}
+	s := strings.TrimSpace(dsn)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("missing \"=\" after %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " \t\n") {
+			return nil, fmt.Errorf("invalid parameter name %q", key)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\n")
+
+		var value strings.Builder
+		quoted := strings.HasPrefix(s, "'")
+		if quoted {
+			s = s[1:]
+		}
+		closed := false
+		for len(s) > 0 {
+			c := s[0]
+			if c == '\\' && len(s) > 1 {
+				value.WriteByte(s[1])
+				s = s[2:]
+				continue
+			}
+			if (quoted && c == '\'') || (!quoted && (c == ' ' || c == '\t' || c == '\n')) {
+				s = s[1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+			s = s[1:]
+		}
+		if quoted && !closed {
+			return nil, fmt.Errorf("unterminated quoted value for %q", key)
+		}
+		params[key] = value.String()
+		s = strings.TrimLeft(s, " \t\n")
+	}
+	return params, nil
+}
+
+// formatDSN renders parameters as a key=value connection string with every value quoted
+func formatDSN(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.ReplaceAll(params[key], `\`, `\\`)
+		value = strings.ReplaceAll(value, `'`, `\'`)
+		parts = append(parts, key+"='"+value+"'")
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeDSN summarizes the connection target for logs, without credentials
+func describeDSN(params map[string]string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s db=%s sslmode=%s",
+		params["host"], params["port"], params["user"], params["dbname"], params["sslmode"])
+}
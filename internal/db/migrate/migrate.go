@@ -0,0 +1,305 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql sqlite/*.sql
+var migrationFiles embed.FS
+
+// dialectSQLite selects the SQLite migrations (in sqlite/) instead of the PostgreSQL ones
+const dialectSQLite = "sqlite"
+
+// advisoryLockKey identifies the migration lock so concurrent instances don't race
+// (any constant works as long as every instance uses the same one)
+const advisoryLockKey int64 = 7263842001
+
+// Migration is a numbered schema change with its up and down SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied
+type Status struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+// Load reads the embedded migrations of a dialect, ordered by version
+// Files are named <version>_<name>.up.sql / <version>_<name>.down.sql
+func Load(dialect string) ([]Migration, error) {
+	dir := "migrations"
+	if dialect == dialectSQLite {
+		dir = "sqlite"
+	}
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name: %s", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", fileName, err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations against a database
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    string // postgres or sqlite
+	Migrations []Migration
+}
+
+// New creates a Migrator with the embedded migrations of a dialect
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration up to and including target (0 = latest)
+// Returns the versions that were applied
+func (m *Migrator) Up(target int) ([]int, error) {
+	var applied []int
+	err := m.withLock(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := apply(conn, migration.Version, migration.Name, migration.Up, true); err != nil {
+				return err
+			}
+			log.Printf("[MIGRATE] Applied migration %04d_%s", migration.Version, migration.Name)
+			applied = append(applied, migration.Version)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, newest first
+// Returns the versions that were reverted
+func (m *Migrator) Down(steps int) ([]int, error) {
+	var reverted []int
+	err := m.withLock(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d (%s) has no down file", migration.Version, migration.Name)
+			}
+			if err := apply(conn, migration.Version, migration.Name, migration.Down, false); err != nil {
+				return err
+			}
+			log.Printf("[MIGRATE] Reverted migration %04d_%s", migration.Version, migration.Name)
+			reverted = append(reverted, migration.Version)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(context.Background(), `SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return fmt.Errorf("failed to query schema_migrations: %w", err)
+		}
+		defer rows.Close()
+
+		appliedAt := map[int]string{}
+		for rows.Next() {
+			var version int
+			var at sql.NullTime
+			if err := rows.Scan(&version, &at); err != nil {
+				return fmt.Errorf("failed to scan schema_migrations: %w", err)
+			}
+			appliedAt[version] = ""
+			if at.Valid {
+				appliedAt[version] = at.Time.UTC().Format("2006-01-02T15:04:05Z07:00")
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			at, ok := appliedAt[migration.Version]
+			statuses = append(statuses, Status{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: at,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock
+// The lock is session-level, so it must be taken and released on the same connection
+// SQLite has no advisory locks; its database file lock serializes writers instead
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if m.Dialect == dialectSQLite {
+		if _, err := conn.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, advisoryLockKey); err != nil {
+			log.Printf("[MIGRATE WARNING] Failed to release migration lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]struct{}, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int]struct{}{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		done[version] = struct{}{}
+	}
+	return done, rows.Err()
+}
+
+// apply runs one migration script and records it in schema_migrations in a single transaction
+func apply(conn *sql.Conn, version int, name string, script string, up bool) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The script has no parameters, so it runs through the simple query protocol
+	// which accepts several statements at once
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", version, name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, version, name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", version, name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", version, name, err)
+	}
+	return nil
+}
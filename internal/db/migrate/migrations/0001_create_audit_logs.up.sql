@@ -0,0 +1,33 @@
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id SERIAL PRIMARY KEY,
+	user_id VARCHAR(255),
+	severity VARCHAR(20) DEFAULT 'NONE' NOT NULL,
+	endpoint_path VARCHAR(500) NOT NULL,
+	session_id VARCHAR(255),
+	action VARCHAR(255),
+	action_date TIMESTAMP WITH TIME ZONE,
+	count INTEGER,
+	http_method VARCHAR(20) NOT NULL,
+	status_code INTEGER NOT NULL,
+	response_time_seconds NUMERIC(10, 3) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	ip_address INET,
+	user_agent TEXT,
+	chat_history_id INTEGER,
+	insights_id INTEGER,
+	tokens_used INTEGER,
+	query_raw JSONB,
+	body_raw JSONB,
+	response_body JSONB
+);
+
+-- Deployments created before response_body existed never received it from CREATE TABLE IF NOT EXISTS
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS response_body JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_severity ON audit_logs(severity);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_body_raw ON audit_logs USING GIN (body_raw);
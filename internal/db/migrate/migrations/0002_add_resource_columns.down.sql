@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_audit_logs_resource;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS impersonated_by;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_type;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS outcome;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS resource_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS resource_type;
@@ -0,0 +1,8 @@
+-- Actor/target resource model
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS resource_type VARCHAR(100);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS resource_id VARCHAR(255);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS outcome VARCHAR(20);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_type VARCHAR(20);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS impersonated_by VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS change_diff;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS after_snapshot;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS before_snapshot;
@@ -0,0 +1,4 @@
+-- Before/after change capture for mutating requests
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS before_snapshot JSONB;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS after_snapshot JSONB;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS change_diff JSONB;
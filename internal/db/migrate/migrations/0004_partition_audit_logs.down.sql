@@ -0,0 +1,18 @@
+-- Convert the partitioned audit_logs back into a plain table.
+-- audit_logs_legacy (if still present) is left untouched.
+ALTER TABLE audit_logs RENAME TO audit_logs_partitioned;
+
+CREATE TABLE audit_logs (LIKE audit_logs_partitioned INCLUDING DEFAULTS);
+ALTER SEQUENCE audit_logs_id_seq OWNED BY audit_logs.id;
+
+INSERT INTO audit_logs SELECT * FROM audit_logs_partitioned;
+
+DROP TABLE audit_logs_partitioned;
+
+ALTER TABLE audit_logs ADD PRIMARY KEY (id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_severity ON audit_logs(severity);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_body_raw ON audit_logs USING GIN (body_raw);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, created_at);
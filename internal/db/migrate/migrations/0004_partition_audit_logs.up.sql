@@ -0,0 +1,112 @@
+-- Convert audit_logs into a table range-partitioned by month on created_at.
+-- Existing rows are copied into the new partitions. The old table is kept as
+-- audit_logs_legacy so operators can verify the copy before dropping it.
+-- Future partitions are created ahead of time by the partition manager.
+DO $$
+DECLARE
+	month_start DATE;
+	last_month DATE;
+BEGIN
+	-- Nothing to do when audit_logs is already partitioned
+	IF EXISTS (
+		SELECT 1 FROM pg_class
+		WHERE oid = to_regclass('audit_logs') AND relkind = 'p'
+	) THEN
+		RETURN;
+	END IF;
+
+	-- Move the old table (and its index names) out of the way
+	ALTER TABLE audit_logs RENAME TO audit_logs_legacy;
+	ALTER INDEX IF EXISTS audit_logs_pkey RENAME TO audit_logs_legacy_pkey;
+	ALTER INDEX IF EXISTS idx_audit_logs_created_at RENAME TO idx_audit_logs_legacy_created_at;
+	ALTER INDEX IF EXISTS idx_audit_logs_user_id RENAME TO idx_audit_logs_legacy_user_id;
+	ALTER INDEX IF EXISTS idx_audit_logs_action RENAME TO idx_audit_logs_legacy_action;
+	ALTER INDEX IF EXISTS idx_audit_logs_severity RENAME TO idx_audit_logs_legacy_severity;
+	ALTER INDEX IF EXISTS idx_audit_logs_body_raw RENAME TO idx_audit_logs_legacy_body_raw;
+	ALTER INDEX IF EXISTS idx_audit_logs_resource RENAME TO idx_audit_logs_legacy_resource;
+
+	-- Keep the id sequence so ids continue where the old table stopped
+	ALTER TABLE audit_logs_legacy ALTER COLUMN id DROP DEFAULT;
+	ALTER SEQUENCE audit_logs_id_seq OWNED BY NONE;
+	ALTER SEQUENCE audit_logs_id_seq AS BIGINT;
+
+	-- The partition key must be part of the primary key and cannot be NULL
+	CREATE TABLE audit_logs (
+		id BIGINT NOT NULL DEFAULT nextval('audit_logs_id_seq'),
+		user_id VARCHAR(255),
+		severity VARCHAR(20) DEFAULT 'NONE' NOT NULL,
+		endpoint_path VARCHAR(500) NOT NULL,
+		session_id VARCHAR(255),
+		action VARCHAR(255),
+		action_date TIMESTAMP WITH TIME ZONE,
+		count INTEGER,
+		http_method VARCHAR(20) NOT NULL,
+		status_code INTEGER NOT NULL,
+		response_time_seconds NUMERIC(10, 3) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		ip_address INET,
+		user_agent TEXT,
+		chat_history_id INTEGER,
+		insights_id INTEGER,
+		tokens_used INTEGER,
+		query_raw JSONB,
+		body_raw JSONB,
+		response_body JSONB,
+		resource_type VARCHAR(100),
+		resource_id VARCHAR(255),
+		outcome VARCHAR(20),
+		actor_type VARCHAR(20),
+		impersonated_by VARCHAR(255),
+		before_snapshot JSONB,
+		after_snapshot JSONB,
+		change_diff JSONB,
+		PRIMARY KEY (id, created_at)
+	) PARTITION BY RANGE (created_at);
+
+	ALTER SEQUENCE audit_logs_id_seq OWNED BY audit_logs.id;
+
+	-- Catches rows outside every monthly partition so inserts never fail
+	CREATE TABLE audit_logs_default PARTITION OF audit_logs DEFAULT;
+
+	-- Monthly partitions from the oldest existing row up to two months ahead
+	SELECT date_trunc('month', COALESCE(MIN(created_at), CURRENT_TIMESTAMP) AT TIME ZONE 'UTC')::date
+	INTO month_start
+	FROM audit_logs_legacy;
+	last_month := (date_trunc('month', CURRENT_TIMESTAMP AT TIME ZONE 'UTC') + INTERVAL '2 months')::date;
+
+	WHILE month_start <= last_month LOOP
+		EXECUTE format(
+			'CREATE TABLE %I PARTITION OF audit_logs FOR VALUES FROM (%L) TO (%L)',
+			'audit_logs_p' || to_char(month_start, 'YYYYMM'),
+			month_start::timestamp AT TIME ZONE 'UTC',
+			(month_start + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC'
+		);
+		month_start := (month_start + INTERVAL '1 month')::date;
+	END LOOP;
+
+	INSERT INTO audit_logs (
+		id, user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
+		response_time_seconds, created_at, ip_address, user_agent,
+		chat_history_id, insights_id, tokens_used,
+		query_raw, body_raw, response_body,
+		resource_type, resource_id, outcome, actor_type, impersonated_by,
+		before_snapshot, after_snapshot, change_diff
+	)
+	SELECT
+		id, user_id, severity, endpoint_path, session_id, action, action_date, count, http_method, status_code,
+		response_time_seconds, COALESCE(created_at, action_date, CURRENT_TIMESTAMP), ip_address, user_agent,
+		chat_history_id, insights_id, tokens_used,
+		query_raw, body_raw, response_body,
+		resource_type, resource_id, outcome, actor_type, impersonated_by,
+		before_snapshot, after_snapshot, change_diff
+	FROM audit_logs_legacy;
+
+	PERFORM setval('audit_logs_id_seq', GREATEST(COALESCE((SELECT MAX(id) FROM audit_logs), 0), 1));
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_severity ON audit_logs(severity);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_body_raw ON audit_logs USING GIN (body_raw);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, created_at);
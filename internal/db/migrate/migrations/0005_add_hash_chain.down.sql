@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_chain_tombstones;
+DROP TABLE IF EXISTS audit_chain_head;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS row_hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS prev_hash;
@@ -0,0 +1,28 @@
+-- Tamper-evident hash chain over audit_logs.
+-- content_hash = sha256(canonical row content), row_hash = sha256(prev_hash || content_hash).
+-- Rows written before this migration have no hashes and are not part of the chain.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS content_hash CHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS row_hash CHAR(64);
+
+-- Single row pointing at the newest chained row, updated under the chain lock
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	last_id BIGINT NOT NULL,
+	last_hash CHAR(64) NOT NULL,
+	last_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Hashes of rows legitimately removed (e.g. by retention) so the chain still verifies
+CREATE TABLE IF NOT EXISTS audit_chain_tombstones (
+	id BIGINT PRIMARY KEY,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	prev_hash CHAR(64) NOT NULL,
+	content_hash CHAR(64) NOT NULL,
+	row_hash CHAR(64) NOT NULL,
+	reason VARCHAR(50) NOT NULL,
+	removed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_chain_tombstones_created_at ON audit_chain_tombstones(created_at);
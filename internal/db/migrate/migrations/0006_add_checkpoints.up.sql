@@ -0,0 +1,18 @@
+-- Signed Merkle roots over the chained rows (live rows and tombstones) of each period.
+-- signature is the base64 ed25519 signature of the checkpoint message, key_id names the signing key.
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+	id BIGSERIAL PRIMARY KEY,
+	period VARCHAR(10) NOT NULL,
+	period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+	period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+	first_id BIGINT,
+	last_id BIGINT,
+	leaf_count BIGINT NOT NULL,
+	merkle_root CHAR(64) NOT NULL,
+	signature TEXT NOT NULL,
+	key_id VARCHAR(32) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (period, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_period_end ON audit_checkpoints(period_end);
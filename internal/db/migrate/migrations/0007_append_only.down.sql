@@ -0,0 +1,17 @@
+-- The audit_maintenance role is cluster-wide and is left in place
+DO $$
+DECLARE
+	protected TEXT;
+BEGIN
+	FOREACH protected IN ARRAY ARRAY['audit_logs', 'audit_chain_tombstones', 'audit_checkpoints', 'audit_meta_events'] LOOP
+		EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', protected || '_append_only', protected);
+		EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', protected || '_no_truncate', protected);
+	END LOOP;
+END
+$$;
+
+DROP FUNCTION IF EXISTS audit_reject_truncate();
+DROP FUNCTION IF EXISTS audit_reject_modification();
+DROP FUNCTION IF EXISTS audit_record_meta_event(TEXT, TEXT, TEXT, BIGINT, JSONB);
+DROP FUNCTION IF EXISTS audit_is_maintenance();
+DROP TABLE IF EXISTS audit_meta_events;
@@ -0,0 +1,115 @@
+-- Append-only enforcement for the audit tables.
+-- UPDATE and DELETE by any role other than members of audit_maintenance are skipped
+-- (the row is left unchanged), raise a WARNING and are recorded in audit_meta_events.
+-- TRUNCATE is rejected with an error. Retention and erasure connect with a login role
+-- that is a member of audit_maintenance (POSTGRES_MAINTENANCE_USER).
+
+CREATE TABLE IF NOT EXISTS audit_meta_events (
+	id BIGSERIAL PRIMARY KEY,
+	event_type VARCHAR(50) NOT NULL,
+	table_name VARCHAR(255),
+	operation VARCHAR(20),
+	row_id BIGINT,
+	db_user VARCHAR(255) NOT NULL DEFAULT current_user,
+	session_user_name VARCHAR(255) NOT NULL DEFAULT session_user,
+	client_addr INET DEFAULT inet_client_addr(),
+	application_name VARCHAR(255) DEFAULT current_setting('application_name', true),
+	details JSONB,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_meta_events_created_at ON audit_meta_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_meta_events_event_type ON audit_meta_events(event_type);
+
+-- The maintenance role is cluster-wide; creating it needs CREATEROLE, otherwise a DBA creates it
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'audit_maintenance') THEN
+		CREATE ROLE audit_maintenance NOLOGIN;
+	END IF;
+EXCEPTION WHEN insufficient_privilege THEN
+	RAISE NOTICE 'audit_maintenance role not created (insufficient privilege) - ask a DBA to run: CREATE ROLE audit_maintenance NOLOGIN';
+END
+$$;
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'audit_maintenance') THEN
+		EXECUTE format('GRANT USAGE ON SCHEMA %I TO audit_maintenance', current_schema());
+		EXECUTE format('GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %I TO audit_maintenance', current_schema());
+		EXECUTE format('GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA %I TO audit_maintenance', current_schema());
+		EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO audit_maintenance', current_schema());
+		EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I GRANT USAGE, SELECT ON SEQUENCES TO audit_maintenance', current_schema());
+	END IF;
+END
+$$;
+
+-- Explicit membership only: superusers pass pg_has_role for every role, which would exempt them
+CREATE OR REPLACE FUNCTION audit_is_maintenance() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+	WITH RECURSIVE memberships(roleid) AS (
+		SELECT oid FROM pg_roles WHERE rolname = current_user
+		UNION
+		SELECT m.roleid FROM pg_auth_members m JOIN memberships ON m.member = memberships.roleid
+	)
+	SELECT EXISTS (
+		SELECT 1 FROM memberships JOIN pg_roles r ON r.oid = memberships.roleid
+		WHERE r.rolname = 'audit_maintenance'
+	)
+$$;
+
+-- Runs as the table owner so any role can record a violation
+CREATE OR REPLACE FUNCTION audit_record_meta_event(p_event_type TEXT, p_table_name TEXT, p_operation TEXT, p_row_id BIGINT, p_details JSONB)
+RETURNS VOID
+LANGUAGE plpgsql SECURITY DEFINER SET search_path FROM CURRENT AS $$
+BEGIN
+	INSERT INTO audit_meta_events (event_type, table_name, operation, row_id, db_user, details)
+	VALUES (p_event_type, p_table_name, p_operation, p_row_id,
+		COALESCE(NULLIF(current_setting('audit.violating_user', true), ''), session_user), p_details);
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit_reject_modification() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END IF;
+
+	-- current_user changes inside the SECURITY DEFINER function, so pass it along
+	PERFORM set_config('audit.violating_user', current_user, true);
+	PERFORM audit_record_meta_event('append_only_violation', TG_TABLE_NAME, TG_OP, OLD.id,
+		CASE WHEN TG_OP = 'UPDATE' THEN jsonb_build_object('attempted', to_jsonb(NEW)) ELSE NULL END);
+	RAISE WARNING '% on %.id=% rejected: audit tables are append-only', TG_OP, TG_TABLE_NAME, OLD.id;
+	RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit_reject_truncate() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		RETURN NULL;
+	END IF;
+	RAISE EXCEPTION 'TRUNCATE on % rejected: audit tables are append-only', TG_TABLE_NAME
+		USING ERRCODE = 'insufficient_privilege';
+END
+$$;
+
+DO $$
+DECLARE
+	protected TEXT;
+BEGIN
+	FOREACH protected IN ARRAY ARRAY['audit_logs', 'audit_chain_tombstones', 'audit_checkpoints', 'audit_meta_events'] LOOP
+		EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', protected || '_append_only', protected);
+		EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE OR DELETE ON %I FOR EACH ROW EXECUTE FUNCTION audit_reject_modification()',
+			protected || '_append_only', protected);
+		EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', protected || '_no_truncate', protected);
+		EXECUTE format('CREATE TRIGGER %I BEFORE TRUNCATE ON %I FOR EACH STATEMENT EXECUTE FUNCTION audit_reject_truncate()',
+			protected || '_no_truncate', protected);
+	END LOOP;
+END
+$$;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS audit_logs_rollup_dirty ON audit_logs;
+DROP FUNCTION IF EXISTS audit_rollup_mark_dirty();
+DROP TABLE IF EXISTS audit_rollup_dirty;
+DROP TABLE IF EXISTS audit_rollup_state;
+DROP TABLE IF EXISTS audit_rollups_hourly;
@@ -0,0 +1,58 @@
+-- Hourly aggregates of audit_logs per action, endpoint, user, method, status code and severity.
+-- Maintained by the rollup job, which recomputes whole hours; reports read them for the
+-- hours between rolled_from and rolled_up_to in audit_rollup_state.
+-- Latency buckets are non-cumulative: le_250ms counts 0.1s < t <= 0.25s.
+CREATE TABLE IF NOT EXISTS audit_rollups_hourly (
+	bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+	action VARCHAR(255),
+	endpoint_path VARCHAR(500) NOT NULL,
+	user_id VARCHAR(255),
+	http_method VARCHAR(20) NOT NULL,
+	status_code INTEGER NOT NULL,
+	severity VARCHAR(20) NOT NULL,
+	request_count BIGINT NOT NULL,
+	response_time_sum NUMERIC NOT NULL,
+	response_time_max NUMERIC(10, 3) NOT NULL,
+	latency_le_100ms BIGINT NOT NULL,
+	latency_le_250ms BIGINT NOT NULL,
+	latency_le_500ms BIGINT NOT NULL,
+	latency_le_1s BIGINT NOT NULL,
+	latency_le_2500ms BIGINT NOT NULL,
+	latency_le_5s BIGINT NOT NULL,
+	latency_le_10s BIGINT NOT NULL,
+	latency_gt_10s BIGINT NOT NULL,
+	tokens_sum BIGINT,
+	tokens_count BIGINT NOT NULL,
+	count_sum BIGINT,
+	count_rows BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_rollups_hourly_bucket ON audit_rollups_hourly(bucket);
+
+-- The hours [rolled_from, rolled_up_to) are fully rolled up
+CREATE TABLE IF NOT EXISTS audit_rollup_state (
+	name VARCHAR(50) PRIMARY KEY,
+	rolled_from TIMESTAMP WITH TIME ZONE NOT NULL,
+	rolled_up_to TIMESTAMP WITH TIME ZONE NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Hours whose raw rows were deleted or changed after they were rolled up (retention, erasure);
+-- the rollup job recomputes and clears them
+CREATE TABLE IF NOT EXISTS audit_rollup_dirty (
+	bucket TIMESTAMP WITH TIME ZONE PRIMARY KEY
+);
+
+CREATE OR REPLACE FUNCTION audit_rollup_mark_dirty() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	INSERT INTO audit_rollup_dirty (bucket)
+	VALUES (date_trunc('hour', OLD.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC')
+	ON CONFLICT (bucket) DO NOTHING;
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS audit_logs_rollup_dirty ON audit_logs;
+CREATE TRIGGER audit_logs_rollup_dirty AFTER UPDATE OR DELETE ON audit_logs
+	FOR EACH ROW EXECUTE FUNCTION audit_rollup_mark_dirty();
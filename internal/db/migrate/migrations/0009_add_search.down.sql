@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_logs_search;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,10 @@
+-- Full-text search over the question (weight A), the answer (B) and every string in the
+-- request body (C). Adding a stored generated column rewrites audit_logs, so on large
+-- tables apply this migration during a maintenance window (AUTO_MIGRATE=false, migrate up).
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('english'::regconfig, COALESCE(body_raw->>'question', '')), 'A') ||
+	setweight(to_tsvector('english'::regconfig, COALESCE(response_body->'data'->>'response', '')), 'B') ||
+	setweight(jsonb_to_tsvector('english'::regconfig, COALESCE(body_raw, '{}'::jsonb), '["string"]'), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_search ON audit_logs USING GIN (search_vector);
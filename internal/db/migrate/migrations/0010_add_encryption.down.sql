@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_audit_logs_search;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE audit_logs ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('english'::regconfig, COALESCE(body_raw->>'question', '')), 'A') ||
+	setweight(to_tsvector('english'::regconfig, COALESCE(response_body->'data'->>'response', '')), 'B') ||
+	setweight(jsonb_to_tsvector('english'::regconfig, COALESCE(body_raw, '{}'::jsonb), '["string"]'), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_search ON audit_logs USING GIN (search_vector);
+
+DROP TABLE IF EXISTS audit_data_keys;
@@ -0,0 +1,25 @@
+-- Data keys for payload encryption. Each key is wrapped (AES-256-GCM) by a master key
+-- from AUDIT_ENCRYPTION_KEY_FILE; encrypted columns reference it by id in their envelope.
+-- Keys are never deleted: retired keys still decrypt the rows written with them.
+CREATE TABLE IF NOT EXISTS audit_data_keys (
+	id BIGSERIAL PRIMARY KEY,
+	master_key_id VARCHAR(64) NOT NULL,
+	wrapped_key TEXT NOT NULL, -- base64(nonce || ciphertext)
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	retired_at TIMESTAMPTZ,
+	rewrapped_at TIMESTAMPTZ
+);
+
+-- Encrypted bodies ({"$enc": ...}) must not put ciphertext into the search index.
+-- Like 0009, re-adding the generated column rewrites audit_logs.
+DROP INDEX IF EXISTS idx_audit_logs_search;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE audit_logs ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('english'::regconfig, COALESCE(body_raw->>'question', '')), 'A') ||
+	setweight(to_tsvector('english'::regconfig, COALESCE(response_body->'data'->>'response', '')), 'B') ||
+	setweight(jsonb_to_tsvector('english'::regconfig,
+		CASE WHEN body_raw ? '$enc' THEN '{}'::jsonb ELSE COALESCE(body_raw, '{}'::jsonb) END,
+		'["string"]'), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_search ON audit_logs USING GIN (search_vector);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS audit_erasure_records;
+DROP TABLE IF EXISTS audit_erasure_jobs;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS redacted_at;
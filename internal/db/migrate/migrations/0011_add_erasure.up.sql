@@ -0,0 +1,59 @@
+-- Erasure of a data subject's audit logs (GDPR right to erasure).
+-- Pseudonymized rows keep their chain hashes but not the content they were computed from:
+-- redacted_at marks them so verification only checks their links.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS redacted_at TIMESTAMP WITH TIME ZONE;
+
+-- One row per erasure request. user_id is only kept until the job ends; afterwards the
+-- subject is identified by subject_hash (HMAC-SHA256 of the user_id with AUDIT_ERASURE_KEY)
+CREATE TABLE IF NOT EXISTS audit_erasure_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	user_id VARCHAR(255),
+	subject_hash CHAR(64) NOT NULL,
+	mode VARCHAR(20) NOT NULL, -- delete or pseudonymize
+	status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed, failed
+	requested_by VARCHAR(255),
+	reason TEXT,
+	total_rows BIGINT NOT NULL DEFAULT 0,
+	processed_rows BIGINT NOT NULL DEFAULT 0,
+	first_id BIGINT,
+	last_id BIGINT,
+	oldest_row TIMESTAMP WITH TIME ZONE,
+	newest_row TIMESTAMP WITH TIME ZONE,
+	error TEXT,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMP WITH TIME ZONE,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_erasure_jobs_status ON audit_erasure_jobs(status, id);
+CREATE INDEX IF NOT EXISTS idx_audit_erasure_jobs_subject_hash ON audit_erasure_jobs(subject_hash);
+
+-- Compliance record of every completed erasure; record_sha256 covers the canonical JSON
+CREATE TABLE IF NOT EXISTS audit_erasure_records (
+	id BIGSERIAL PRIMARY KEY,
+	job_id BIGINT NOT NULL UNIQUE,
+	subject_hash CHAR(64) NOT NULL,
+	record JSONB NOT NULL,
+	record_sha256 CHAR(64) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_erasure_records_subject_hash ON audit_erasure_records(subject_hash);
+
+-- Compliance records are append-only like the other audit tables (see 0007_append_only)
+DROP TRIGGER IF EXISTS audit_erasure_records_append_only ON audit_erasure_records;
+CREATE TRIGGER audit_erasure_records_append_only BEFORE UPDATE OR DELETE ON audit_erasure_records
+	FOR EACH ROW EXECUTE FUNCTION audit_reject_modification();
+DROP TRIGGER IF EXISTS audit_erasure_records_no_truncate ON audit_erasure_records;
+CREATE TRIGGER audit_erasure_records_no_truncate BEFORE TRUNCATE ON audit_erasure_records
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_reject_truncate();
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'audit_maintenance') THEN
+		EXECUTE 'GRANT SELECT, INSERT, UPDATE, DELETE ON audit_erasure_jobs, audit_erasure_records TO audit_maintenance';
+		EXECUTE 'GRANT USAGE, SELECT ON SEQUENCE audit_erasure_jobs_id_seq, audit_erasure_records_id_seq TO audit_maintenance';
+	END IF;
+END
+$$;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_legal_hold_events;
+DROP TABLE IF EXISTS audit_legal_holds;
+DROP INDEX IF EXISTS idx_audit_logs_tenant_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,59 @@
+-- Tenant of each row, so tenants can be placed under legal hold. Rows written before the
+-- column existed stay NULL, which the chain hash omits, so their hashes do not change
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_id ON audit_logs(tenant_id, created_at);
+
+-- Legal holds freeze the rows matching all of their criteria; retention, erasure and
+-- partition removal skip held rows until the hold is released. NULL criteria match any row,
+-- and held_from/held_until bound created_at (inclusive/exclusive)
+CREATE TABLE IF NOT EXISTS audit_legal_holds (
+	id BIGSERIAL PRIMARY KEY,
+	user_id VARCHAR(255),
+	session_id VARCHAR(255),
+	tenant_id VARCHAR(255),
+	action VARCHAR(255),
+	held_from TIMESTAMP WITH TIME ZONE,
+	held_until TIMESTAMP WITH TIME ZONE,
+	reason TEXT NOT NULL,
+	owner VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	released_at TIMESTAMP WITH TIME ZONE,
+	released_by VARCHAR(255),
+	release_reason TEXT,
+	CONSTRAINT audit_legal_holds_criteria CHECK (
+		COALESCE(user_id, session_id, tenant_id, action) IS NOT NULL
+		OR held_from IS NOT NULL OR held_until IS NOT NULL
+	)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_legal_holds_active ON audit_legal_holds(id) WHERE released_at IS NULL;
+
+-- Every change to a hold, with the hold as it was afterwards
+CREATE TABLE IF NOT EXISTS audit_legal_hold_events (
+	id BIGSERIAL PRIMARY KEY,
+	hold_id BIGINT NOT NULL,
+	event VARCHAR(20) NOT NULL, -- created or released
+	actor VARCHAR(255) NOT NULL,
+	reason TEXT,
+	hold JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_legal_hold_events_hold_id ON audit_legal_hold_events(hold_id, id);
+
+-- The hold history is append-only like the other audit tables (see 0007_append_only)
+DROP TRIGGER IF EXISTS audit_legal_hold_events_append_only ON audit_legal_hold_events;
+CREATE TRIGGER audit_legal_hold_events_append_only BEFORE UPDATE OR DELETE ON audit_legal_hold_events
+	FOR EACH ROW EXECUTE FUNCTION audit_reject_modification();
+DROP TRIGGER IF EXISTS audit_legal_hold_events_no_truncate ON audit_legal_hold_events;
+CREATE TRIGGER audit_legal_hold_events_no_truncate BEFORE TRUNCATE ON audit_legal_hold_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_reject_truncate();
+
+-- Retention and erasure run on the maintenance connection and only need to read holds
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'audit_maintenance') THEN
+		EXECUTE 'GRANT SELECT ON audit_legal_holds TO audit_maintenance';
+	END IF;
+END
+$$;
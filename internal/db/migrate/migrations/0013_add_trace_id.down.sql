@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_audit_logs_chat_history_id;
+DROP INDEX IF EXISTS idx_audit_logs_session_id;
+DROP INDEX IF EXISTS idx_audit_logs_trace_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS trace_id;
@@ -0,0 +1,9 @@
+-- Trace of each row (the trace id a producer propagates across services), so the entries
+-- of one distributed request can be found together. Rows written before the column existed
+-- stay NULL, which the chain hash omits, so their hashes do not change
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS trace_id VARCHAR(255);
+
+-- Related entries of a single record are looked up by session, chat and trace
+CREATE INDEX IF NOT EXISTS idx_audit_logs_trace_id ON audit_logs(trace_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_session_id ON audit_logs(session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_chat_history_id ON audit_logs(chat_history_id, created_at);
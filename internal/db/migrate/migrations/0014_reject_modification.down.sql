@@ -0,0 +1,22 @@
+-- Restores the 0007 behaviour of skipping the row with a WARNING. dblink is left installed,
+-- since other code may use it
+CREATE OR REPLACE FUNCTION audit_reject_modification() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END IF;
+
+	-- current_user changes inside the SECURITY DEFINER function, so pass it along
+	PERFORM set_config('audit.violating_user', current_user, true);
+	PERFORM audit_record_meta_event('append_only_violation', TG_TABLE_NAME, TG_OP, OLD.id,
+		CASE WHEN TG_OP = 'UPDATE' THEN jsonb_build_object('attempted', to_jsonb(NEW)) ELSE NULL END);
+	RAISE WARNING '% on %.id=% rejected: audit tables are append-only', TG_OP, TG_TABLE_NAME, OLD.id;
+	RETURN NULL;
+END
+$$;
+
+DROP FUNCTION IF EXISTS audit_record_violation(TEXT, TEXT, TEXT, BIGINT, JSONB);
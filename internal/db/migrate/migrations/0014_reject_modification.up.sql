@@ -0,0 +1,59 @@
+-- UPDATE and DELETE on the append-only tables now fail with an error instead of skipping the
+-- row. A skipped statement still reported success (with 0 rows), so a job running without
+-- the maintenance role looked as if it had worked.
+-- The violation is recorded over dblink, in a transaction of its own, so the record survives
+-- the rollback of the rejected statement. Without dblink, or when it cannot connect, the
+-- statement is still rejected and logged by the server, but audit_meta_events misses it.
+
+DO $$
+BEGIN
+	CREATE EXTENSION IF NOT EXISTS dblink;
+EXCEPTION WHEN insufficient_privilege OR undefined_file OR feature_not_supported THEN
+	RAISE NOTICE 'dblink not installed (%) - append-only violations are rejected but not recorded; ask a DBA to run: CREATE EXTENSION dblink', SQLERRM;
+END
+$$;
+
+-- Connects with the conninfo in the audit.meta_event_conninfo setting (set with ALTER DATABASE),
+-- or to the current database as the function owner, which dblink only allows for superusers.
+-- Never fails: the caller rejects the statement either way
+CREATE OR REPLACE FUNCTION audit_record_violation(p_db_user TEXT, p_table_name TEXT, p_operation TEXT, p_row_id BIGINT, p_details JSONB)
+RETURNS BOOLEAN
+LANGUAGE plpgsql SECURITY DEFINER SET search_path FROM CURRENT AS $$
+DECLARE
+	conninfo TEXT := COALESCE(NULLIF(current_setting('audit.meta_event_conninfo', true), ''),
+		'dbname=''' || replace(replace(current_database(), '\', '\\'), '''', '\''') || '''');
+BEGIN
+	IF to_regproc('dblink_exec') IS NULL THEN
+		RETURN false;
+	END IF;
+	-- session_user, the client address and application name are those of the violating session
+	PERFORM dblink_exec(conninfo, format(
+		'INSERT INTO audit_meta_events (event_type, table_name, operation, row_id, db_user, session_user_name, client_addr, application_name, details)
+		VALUES (%L, %L, %L, %L, %L, %L, %L, %L, %L)',
+		'append_only_violation', p_table_name, p_operation, p_row_id, p_db_user, session_user,
+		inet_client_addr(), current_setting('application_name', true), p_details));
+	RETURN true;
+EXCEPTION WHEN OTHERS THEN
+	RAISE WARNING 'append-only violation on %.id=% not recorded: %', p_table_name, p_row_id, SQLERRM;
+	RETURN false;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit_reject_modification() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END IF;
+
+	-- current_user changes inside the SECURITY DEFINER function, so pass it along
+	PERFORM audit_record_violation(current_user, TG_TABLE_NAME, TG_OP, OLD.id,
+		CASE WHEN TG_OP = 'UPDATE' THEN jsonb_build_object('attempted', to_jsonb(NEW)) ELSE NULL END);
+	RAISE EXCEPTION '% on %.id=% rejected: audit tables are append-only', TG_OP, TG_TABLE_NAME, OLD.id
+		USING ERRCODE = 'insufficient_privilege',
+			HINT = 'Only members of audit_maintenance (POSTGRES_MAINTENANCE_USER) may update or delete audit rows';
+END
+$$;
@@ -0,0 +1,32 @@
+-- Range tombstones: a detached or dropped partition leaves one link standing for all of its
+-- rows, from the prev_hash of its first chained row to the row_hash of its last one, instead
+-- of a tombstone per row. Verification checks the link and skips the ids it covers
+CREATE TABLE IF NOT EXISTS audit_chain_ranges (
+	id BIGINT PRIMARY KEY, -- First id covered
+	last_id BIGINT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL, -- Of the first id
+	last_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	prev_hash CHAR(64) NOT NULL,
+	row_hash CHAR(64) NOT NULL,
+	reason VARCHAR(50) NOT NULL,
+	removed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT audit_chain_ranges_ids CHECK (last_id >= id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_chain_ranges_created_at ON audit_chain_ranges(created_at);
+
+-- Range tombstones are append-only like the per-row ones (see 0007_append_only)
+DROP TRIGGER IF EXISTS audit_chain_ranges_append_only ON audit_chain_ranges;
+CREATE TRIGGER audit_chain_ranges_append_only BEFORE UPDATE OR DELETE ON audit_chain_ranges
+	FOR EACH ROW EXECUTE FUNCTION audit_reject_modification();
+DROP TRIGGER IF EXISTS audit_chain_ranges_no_truncate ON audit_chain_ranges;
+CREATE TRIGGER audit_chain_ranges_no_truncate BEFORE TRUNCATE ON audit_chain_ranges
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_reject_truncate();
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'audit_maintenance') THEN
+		EXECUTE 'GRANT SELECT, INSERT ON audit_chain_ranges TO audit_maintenance';
+	END IF;
+END
+$$;
@@ -0,0 +1,22 @@
+-- Restores the 0014 trigger and the default EXECUTE for PUBLIC
+CREATE OR REPLACE FUNCTION audit_reject_modification() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END IF;
+
+	-- current_user changes inside the SECURITY DEFINER function, so pass it along
+	PERFORM audit_record_violation(current_user, TG_TABLE_NAME, TG_OP, OLD.id,
+		CASE WHEN TG_OP = 'UPDATE' THEN jsonb_build_object('attempted', to_jsonb(NEW)) ELSE NULL END);
+	RAISE EXCEPTION '% on %.id=% rejected: audit tables are append-only', TG_OP, TG_TABLE_NAME, OLD.id
+		USING ERRCODE = 'insufficient_privilege',
+			HINT = 'Only members of audit_maintenance (POSTGRES_MAINTENANCE_USER) may update or delete audit rows';
+END
+$$;
+
+GRANT EXECUTE ON FUNCTION audit_record_meta_event(TEXT, TEXT, TEXT, BIGINT, JSONB) TO PUBLIC;
+GRANT EXECUTE ON FUNCTION audit_record_violation(TEXT, TEXT, TEXT, BIGINT, JSONB) TO PUBLIC;
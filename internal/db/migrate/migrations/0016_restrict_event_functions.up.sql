@@ -0,0 +1,35 @@
+-- audit_record_meta_event and audit_record_violation run as their owner (SECURITY DEFINER), and
+-- functions are executable by PUBLIC by default, so any role could write forged events into
+-- audit_meta_events. Only the table owner keeps EXECUTE.
+-- The append-only trigger calls audit_record_violation as the violating role. Roles without
+-- UPDATE or DELETE on the audit tables are refused before the trigger runs, so the owner is the
+-- only role on that path unless a DBA grants those privileges; such a role also needs
+-- GRANT EXECUTE ON FUNCTION audit_record_violation(TEXT, TEXT, TEXT, BIGINT, JSONB) for its
+-- violations to be recorded. Without it the statement is still rejected.
+
+REVOKE EXECUTE ON FUNCTION audit_record_meta_event(TEXT, TEXT, TEXT, BIGINT, JSONB) FROM PUBLIC;
+REVOKE EXECUTE ON FUNCTION audit_record_violation(TEXT, TEXT, TEXT, BIGINT, JSONB) FROM PUBLIC;
+
+CREATE OR REPLACE FUNCTION audit_reject_modification() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF audit_is_maintenance() THEN
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END IF;
+
+	-- current_user changes inside the SECURITY DEFINER function, so pass it along
+	BEGIN
+		PERFORM audit_record_violation(current_user, TG_TABLE_NAME, TG_OP, OLD.id,
+			CASE WHEN TG_OP = 'UPDATE' THEN jsonb_build_object('attempted', to_jsonb(NEW)) ELSE NULL END);
+	EXCEPTION WHEN insufficient_privilege THEN
+		RAISE WARNING 'append-only violation on %.id=% not recorded: % may not execute audit_record_violation',
+			TG_TABLE_NAME, OLD.id, current_user;
+	END;
+	RAISE EXCEPTION '% on %.id=% rejected: audit tables are append-only', TG_OP, TG_TABLE_NAME, OLD.id
+		USING ERRCODE = 'insufficient_privilege',
+			HINT = 'Only members of audit_maintenance (POSTGRES_MAINTENANCE_USER) may update or delete audit rows';
+END
+$$;
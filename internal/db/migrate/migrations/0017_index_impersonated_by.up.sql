@@ -0,0 +1,2 @@
+-- Erasure also finds the rows where the erased user acted as the impersonator
+CREATE INDEX IF NOT EXISTS idx_audit_logs_impersonated_by ON audit_logs(impersonated_by) WHERE impersonated_by IS NOT NULL;
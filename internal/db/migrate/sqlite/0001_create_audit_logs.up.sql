@@ -0,0 +1,65 @@
+-- SQLite schema for local development and edge deployments: audit_logs and its hash chain
+-- as created by the PostgreSQL migrations up to 0005_add_hash_chain.
+-- JSONB columns are TEXT checked with json_valid(), INET columns are TEXT holding the
+-- normalized address, and timestamps are TIMESTAMP text in UTC. Partitioning, checkpoints,
+-- roles and append-only triggers are PostgreSQL-only.
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT,
+	severity TEXT NOT NULL DEFAULT 'NONE',
+	endpoint_path TEXT NOT NULL,
+	session_id TEXT,
+	action TEXT,
+	action_date TIMESTAMP,
+	count INTEGER,
+	http_method TEXT NOT NULL,
+	status_code INTEGER NOT NULL,
+	response_time_seconds NUMERIC NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ip_address TEXT,
+	user_agent TEXT,
+	chat_history_id INTEGER,
+	insights_id INTEGER,
+	tokens_used INTEGER,
+	query_raw TEXT CHECK (query_raw IS NULL OR json_valid(query_raw)),
+	body_raw TEXT CHECK (body_raw IS NULL OR json_valid(body_raw)),
+	response_body TEXT CHECK (response_body IS NULL OR json_valid(response_body)),
+	resource_type TEXT,
+	resource_id TEXT,
+	outcome TEXT,
+	actor_type TEXT,
+	impersonated_by TEXT,
+	before_snapshot TEXT CHECK (before_snapshot IS NULL OR json_valid(before_snapshot)),
+	after_snapshot TEXT CHECK (after_snapshot IS NULL OR json_valid(after_snapshot)),
+	change_diff TEXT CHECK (change_diff IS NULL OR json_valid(change_diff)),
+	prev_hash TEXT,
+	content_hash TEXT,
+	row_hash TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_endpoint_path ON audit_logs(endpoint_path);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_session_id ON audit_logs(session_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, created_at);
+
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	last_id INTEGER NOT NULL,
+	last_hash TEXT NOT NULL,
+	last_created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS audit_chain_tombstones (
+	id INTEGER PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	prev_hash TEXT NOT NULL,
+	content_hash TEXT NOT NULL,
+	row_hash TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	removed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_chain_tombstones_created_at ON audit_chain_tombstones(created_at);
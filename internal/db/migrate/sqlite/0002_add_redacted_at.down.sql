@@ -0,0 +1 @@
+ALTER TABLE audit_logs DROP COLUMN redacted_at;
@@ -0,0 +1,3 @@
+-- redacted_at as added by the PostgreSQL migration 0011_add_erasure; erasure itself is
+-- PostgreSQL-only, but chain verification reads the column
+ALTER TABLE audit_logs ADD COLUMN redacted_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- tenant_id as added by the PostgreSQL migration 0012_add_legal_holds; legal holds
+-- themselves are PostgreSQL-only
+ALTER TABLE audit_logs ADD COLUMN tenant_id TEXT;
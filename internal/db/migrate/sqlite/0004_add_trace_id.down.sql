@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_logs_chat_history_id;
+DROP INDEX IF EXISTS idx_audit_logs_trace_id;
+ALTER TABLE audit_logs DROP COLUMN trace_id;
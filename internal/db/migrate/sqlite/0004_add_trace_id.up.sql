@@ -0,0 +1,4 @@
+-- trace_id as added by the PostgreSQL migration 0013_add_trace_id
+ALTER TABLE audit_logs ADD COLUMN trace_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_trace_id ON audit_logs(trace_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_chat_history_id ON audit_logs(chat_history_id, created_at);
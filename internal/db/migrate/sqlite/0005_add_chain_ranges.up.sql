@@ -0,0 +1,12 @@
+-- audit_chain_ranges as added by the PostgreSQL migration 0015_add_chain_ranges
+-- SQLite has no partitions, so it stays empty; the chain verification reads it on both
+CREATE TABLE IF NOT EXISTS audit_chain_ranges (
+	id INTEGER PRIMARY KEY,
+	last_id INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_created_at TIMESTAMP NOT NULL,
+	prev_hash TEXT NOT NULL,
+	row_hash TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	removed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Queryer runs read-only queries; satisfied by *sql.DB and *ReadPool
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// replicaCheckTimeout bounds a single replica health check
+const replicaCheckTimeout = 3 * time.Second
+
+// ReadPool sends read-only queries to healthy read replicas, round-robin, and falls back
+// to a read pool on the primary when no replica is configured, healthy or caught up
+// The primary read pool is separate from the write pool, so heavy reads never take
+// the connections that ingestion needs
+type ReadPool struct {
+	Primary       *sql.DB
+	MaxLag        time.Duration
+	CheckInterval time.Duration
+	replicas      []*replica
+	next          atomic.Uint64
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+type replica struct {
+	name    string // "replica 1", ...; DSNs hold passwords so they are never logged
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// OpenReadPool connects the primary read pool and every replica in cfg.ReadReplicas
+// Replicas are checked once before it returns; Start keeps checking them
+func OpenReadPool(cfg Config) (*ReadPool, error) {
+	params, err := cfg.postgresParams("", "", DB_APPLICATION_NAME+"-Read")
+	if err != nil {
+		return nil, err
+	}
+	primary, err := sql.Open("postgres", formatDSN(params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect read pool: %w", err)
+	}
+	configurePool(primary, cfg.ReadPoolSize, cfg)
+
+	pool := &ReadPool{
+		Primary:       primary,
+		MaxLag:        cfg.ReplicaMaxLag,
+		CheckInterval: cfg.ReplicaCheckInterval,
+	}
+	for i, dsn := range cfg.ReadReplicas {
+		conn, err := sql.Open("postgres", dsn)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to connect read replica %d: %w", i+1, err)
+		}
+		configurePool(conn, cfg.ReadPoolSize, cfg)
+		pool.replicas = append(pool.replicas, &replica{name: fmt.Sprintf("replica %d", i+1), db: conn})
+	}
+	if len(pool.replicas) > 0 {
+		log.Printf("[DB] Reads go to %d replica(s) lagging at most %s, falling back to the primary", len(pool.replicas), pool.MaxLag)
+	}
+	pool.checkReplicas()
+	return pool, nil
+}
+
+// Start checks replica health and lag periodically in the background
+func (p *ReadPool) Start() {
+	if len(p.replicas) == 0 {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.checkReplicas()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background health checks
+func (p *ReadPool) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+// Close closes the primary read pool and every replica pool
+func (p *ReadPool) Close() error {
+	var errs []error
+	if err := p.Primary.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, r := range p.replicas {
+		if err := r.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueryContext runs a read-only query on a healthy replica, or on the primary
+// A replica that fails with a connection error is marked unhealthy and the query is
+// retried on the primary; SQL errors are returned as they are
+func (p *ReadPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r := p.pick()
+	if r == nil {
+		return p.Primary.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err == nil || ctx.Err() != nil || !isConnectionError(err) {
+		return rows, err
+	}
+	if r.healthy.Swap(false) {
+		log.Printf("[DB] Read %s failed, reads fall back to the primary: %v", r.name, err)
+	}
+	return p.Primary.QueryContext(ctx, query, args...)
+}
+
+// pick returns the next healthy replica, or nil when there is none
+func (p *ReadPool) pick() *replica {
+	n := uint64(len(p.replicas))
+	if n == 0 {
+		return nil
+	}
+	start := p.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := p.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// checkReplicas measures each replica's replay lag and updates its health
+func (p *ReadPool) checkReplicas() {
+	for _, r := range p.replicas {
+		lag, err := p.replicaLag(r)
+		healthy := err == nil && lag <= p.MaxLag
+
+		switch wasHealthy := r.healthy.Swap(healthy); {
+		case healthy && !wasHealthy:
+			log.Printf("[DB] Read %s is available (lag %s)", r.name, lag)
+		case !healthy && wasHealthy && err != nil:
+			log.Printf("[DB] Read %s is unavailable, reads fall back to the primary: %v", r.name, err)
+		case !healthy && wasHealthy:
+			log.Printf("[DB] Read %s is lagging %s (max %s), reads fall back to the primary", r.name, lag, p.MaxLag)
+		}
+	}
+}
+
+// replicaLag returns how far a replica's replayed data is behind the primary
+// A replica that has replayed everything the primary had written when the check began
+// is caught up; otherwise the lag is the age of its last replayed commit
+func (p *ReadPool) replicaLag(r *replica) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+	defer cancel()
+
+	// When the primary cannot be reached the replay timestamp alone decides
+	var primaryLSN sql.NullString
+	if err := p.Primary.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&primaryLSN); err != nil {
+		primaryLSN = sql.NullString{}
+	}
+
+	var seconds float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN $1::pg_lsn IS NOT NULL AND pg_last_wal_replay_lsn() >= $1::pg_lsn THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`, primaryLSN).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// isConnectionError reports whether err means the server could not be reached or
+// dropped the connection, as opposed to an error in the query itself
+func isConnectionError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return true
+	}
+	switch {
+	case pqErr.Code == "57014": // query_canceled, e.g. statement_timeout
+		return false
+	case pqErr.Code == "40001": // A hot standby cancelled the query to replay conflicting changes
+		return true
+	}
+	// Connection exceptions and operator intervention (shutdown, recovery in progress)
+	return pqErr.Code.Class() == "08" || pqErr.Code.Class() == "57"
+}
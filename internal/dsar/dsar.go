@@ -0,0 +1,567 @@
+package dsar
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/checkpoint"
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/spf13/viper"
+)
+
+// messageVersion prefixes the signed manifest message
+// v2 added the masked value count and the excluded ranges; v1 manifests still verify
+const (
+	messageVersion   = "sparksai-audit-export/v2"
+	messageVersionV1 = "sparksai-audit-export/v1"
+)
+
+// Export formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Files written to every export archive, in this order
+const (
+	SummaryFile  = "summary.json"
+	ManifestFile = "manifest.json"
+)
+
+// Columns is the column order of CSV exports; JSON exports hold the same keys
+var Columns = []string{
+	"id", "created_at", "user_id", "session_id", "severity", "action", "action_date", "count",
+	"endpoint_path", "http_method", "status_code", "outcome", "response_time_seconds",
+	"ip_address", "user_agent", "chat_history_id", "insights_id", "tokens_used",
+	"resource_type", "resource_id", "actor_type", "impersonated_by", "tenant_id", "trace_id",
+	"query_raw", "body_raw", "response_body", "before_snapshot", "after_snapshot", "change_diff",
+	"redacted_at", "prev_hash", "content_hash", "row_hash",
+}
+
+// ErrNoKey is returned when no signing key is configured
+var ErrNoKey = errors.New("AUDIT_EXPORT_KEY_FILE (or AUDIT_CHECKPOINT_KEY_FILE) is not set")
+
+// Exporter writes everything held about one user as a signed zip archive
+type Exporter struct {
+	DB        database.Queryer
+	Datastore auditlog.AuditLogDatastore // Used to write the export entry
+	Crypto    *encryption.Keyring        // Decrypts encrypted payload columns; nil leaves them masked
+	Key       ed25519.PrivateKey         // Nil when no key is configured
+	KeyErr    error                      // Set when the signing key could not be loaded
+}
+
+// Config controls subject exports
+type Config struct {
+	KeyFile string // PKCS #8 PEM ed25519 private key
+}
+
+// Manifest lists the files of an export with their hashes, signed with the export key
+type Manifest struct {
+	Version     string `json:"version"`
+	UserID      string `json:"user_id"`
+	GeneratedAt string `json:"generated_at"`
+	Format      string `json:"format"`
+	RowCount    int64  `json:"row_count"`
+	Masked      int64  `json:"masked"`             // Encrypted values that could not be decrypted and were masked
+	Excluded    []Gap  `json:"excluded,omitempty"` // Rows no longer in audit_logs, which the export could not search
+	Files       []File `json:"files"`
+	KeyID       string `json:"key_id"`
+	Signature   string `json:"signature"` // base64 ed25519 signature of Message()
+}
+
+// Gap is a range of rows removed from audit_logs before the export: purged or archived
+// by retention, or in a detached partition. The rows may or may not belong to the user
+type Gap struct {
+	Reason  string `json:"reason"` // retention or partition_detach
+	FirstID int64  `json:"first_id"`
+	LastID  int64  `json:"last_id"`
+	From    string `json:"from"` // created_at of the first row
+	To      string `json:"to"`   // created_at of the last row
+}
+
+// File is one file of an export
+type File struct {
+	Name   string `json:"name"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Summary describes the user's activity across the exported entries
+type Summary struct {
+	UserID     string           `json:"user_id"`
+	RowCount   int64            `json:"row_count"`
+	FirstSeen  *string          `json:"first_seen,omitempty"`
+	LastSeen   *string          `json:"last_seen,omitempty"`
+	Sessions   int              `json:"sessions"` // Distinct session ids
+	TokensUsed int64            `json:"tokens_used"`
+	ByAction   map[string]int64 `json:"by_action"`
+	ByEndpoint map[string]int64 `json:"by_endpoint"`
+	ByMonth    map[string]int64 `json:"by_month"` // YYYY-MM
+	ByOutcome  map[string]int64 `json:"by_outcome"`
+}
+
+// LoadConfig reads the export settings from viper
+func LoadConfig() Config {
+	keyFile := viper.GetString("AUDIT_EXPORT_KEY_FILE")
+	if keyFile == "" {
+		keyFile = viper.GetString("AUDIT_CHECKPOINT_KEY_FILE") // default: sign with the checkpoint key
+	}
+	return Config{KeyFile: keyFile}
+}
+
+// NewExporter loads the signing key; exports are refused when it cannot be loaded
+func NewExporter(db database.Queryer, datastore auditlog.AuditLogDatastore, crypto *encryption.Keyring, cfg Config) *Exporter {
+	exporter := &Exporter{DB: db, Datastore: datastore, Crypto: crypto, KeyErr: ErrNoKey}
+	if cfg.KeyFile != "" {
+		exporter.Key, exporter.KeyErr = checkpoint.LoadPrivateKey(cfg.KeyFile)
+	}
+	return exporter
+}
+
+// EntriesFile is the name of the entries file for a format
+func EntriesFile(format string) string {
+	return "entries." + format
+}
+
+// Message is the exact byte string that is signed
+func (m Manifest) Message() []byte {
+	var message strings.Builder
+	fmt.Fprintf(&message, "%s\n%s\n%s\n%s\n%d\n", m.Version, m.UserID, m.GeneratedAt, m.Format, m.RowCount)
+	if m.Version != messageVersionV1 {
+		fmt.Fprintf(&message, "masked %d\n", m.Masked)
+		for _, gap := range m.Excluded {
+			fmt.Fprintf(&message, "excluded %s %d %d %s %s\n", gap.Reason, gap.FirstID, gap.LastID, gap.From, gap.To)
+		}
+	}
+	for _, file := range m.Files {
+		fmt.Fprintf(&message, "%s %d %s\n", file.Name, file.Bytes, file.SHA256)
+	}
+	return []byte(message.String())
+}
+
+// Export writes the zip archive of every audit log of userID to w: the entries, a summary
+// of activity, and a signed manifest of both. JSON payloads are written as JSON, not as
+// escaped strings. Exports are only open to admins, so encrypted payloads are always
+// decrypted; values that cannot be (no keyring or master key) are masked and counted in
+// the manifest, which also lists the ranges of rows removed from audit_logs beforehand.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, userID string, format string) (*Manifest, error) {
+	if e.Key == nil {
+		return nil, e.KeyErr
+	}
+	if format != FormatJSON && format != FormatCSV {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	started := time.Now()
+	manifest := &Manifest{
+		Version:     messageVersion,
+		UserID:      userID,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Format:      format,
+	}
+	archive := zip.NewWriter(w)
+
+	entries, err := newHashedEntry(archive, EntriesFile(format))
+	if err != nil {
+		return nil, err
+	}
+	summary, masked, err := e.writeEntries(ctx, entries, userID, format)
+	if err != nil {
+		return nil, err
+	}
+	manifest.RowCount = summary.RowCount
+	manifest.Masked = masked
+	if manifest.Excluded, err = e.excluded(ctx); err != nil {
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, entries.file())
+
+	summaryFile, err := newHashedEntry(archive, SummaryFile)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(summaryFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		return nil, fmt.Errorf("failed to write summary: %w", err)
+	}
+	manifest.Files = append(manifest.Files, summaryFile.file())
+
+	manifest.KeyID = checkpoint.KeyID(e.Key.Public().(ed25519.PublicKey))
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(e.Key, manifest.Message()))
+	manifestFile, err := archive.Create(ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	encoder = json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	e.recordExport(manifest, time.Since(started))
+	return manifest, nil
+}
+
+// recordExport writes the export to audit_logs so access to a user's data is itself audited
+func (e *Exporter) recordExport(manifest *Manifest, duration time.Duration) {
+	action := "subject-export"
+	actorType := auditlog.ActorTypeSystem
+	resourceType := "user"
+	count := int(manifest.RowCount)
+	summary, _ := json.Marshal(map[string]interface{}{
+		"format":    manifest.Format,
+		"row_count": manifest.RowCount,
+		"masked":    manifest.Masked,
+		"excluded":  manifest.Excluded,
+		"files":     manifest.Files,
+		"key_id":    manifest.KeyID,
+	})
+	body := string(summary)
+
+	entry := auditlog.AuditLog{
+		Severity:            "NONE",
+		EndpointPath:        "/internal/subject-export",
+		Action:              &action,
+		Count:               &count,
+		HTTPMethod:          "GET",
+		StatusCode:          200,
+		ResponseTimeSeconds: duration.Seconds(),
+		ActorType:           &actorType,
+		ResourceType:        &resourceType,
+		ResourceID:          &manifest.UserID,
+		Outcome:             auditlog.OutcomeSuccess,
+		BodyRaw:             &body,
+	}
+	if err := e.Datastore.BatchInsertAuditLogs(context.Background(), []auditlog.AuditLog{entry}); err != nil {
+		log.Printf("[EXPORT ERROR] Failed to record subject export: %v", err)
+	}
+}
+
+// writeEntries streams the user's rows, oldest first, and tallies the summary
+// Returns the number of encrypted values that were masked
+func (e *Exporter) writeEntries(ctx context.Context, w io.Writer, userID string, format string) (*Summary, int64, error) {
+	// search_vector is derived from the other columns, so exports leave it out
+	rows, err := e.DB.QueryContext(ctx, `
+		SELECT (to_jsonb(a) - 'search_vector')::text
+		FROM audit_logs a
+		WHERE a.user_id = $1
+		ORDER BY a.id ASC`, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	tally := newTally(userID)
+	var masked int64
+	var csvWriter *csv.Writer
+	if format == FormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(Columns); err != nil {
+			return nil, 0, err
+		}
+	} else if _, err := io.WriteString(w, "["); err != nil {
+		return nil, 0, err
+	}
+
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		entry := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(row), &entry); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode audit log: %w", err)
+		}
+		masked += e.decrypt(ctx, entry)
+		tally.add(entry)
+
+		if csvWriter != nil {
+			if err := csvWriter.Write(csvRecord(entry)); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		separator := ",\n"
+		if tally.summary.RowCount == 1 {
+			separator = "\n"
+		}
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := io.WriteString(w, separator+string(encoded)); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit logs: %w", err)
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return nil, 0, err
+		}
+	} else if _, err := io.WriteString(w, "\n]\n"); err != nil {
+		return nil, 0, err
+	}
+	return tally.finish(), masked, nil
+}
+
+// decrypt replaces encrypted payload columns with their plaintext JSON
+// Values that cannot be decrypted are masked as for readers without a token; returns how many
+func (e *Exporter) decrypt(ctx context.Context, entry map[string]json.RawMessage) int64 {
+	var masked int64
+	for _, column := range encryption.EncryptableColumns {
+		value, ok := entry[column]
+		if !ok || !encryption.IsEnvelope(string(value)) {
+			continue
+		}
+		plaintext, err := e.Crypto.Decrypt(ctx, column, string(value))
+		if err != nil {
+			log.Printf("[EXPORT ERROR] Masked %s of audit log %s: %v", column, entry["id"], err)
+			stored := string(value)
+			hidden, _ := e.Crypto.Reveal(context.Background(), column, &stored) // Not a reader, so masked
+			entry[column] = json.RawMessage(*hidden)
+			masked++
+			continue
+		}
+		entry[column] = json.RawMessage(plaintext)
+	}
+	return masked
+}
+
+// excluded lists the ranges of rows removed from audit_logs, whose owners are unknown:
+// retention purges and archives (one range, from their tombstones) and detached partitions
+func (e *Exporter) excluded(ctx context.Context) ([]Gap, error) {
+	rows, err := e.DB.QueryContext(ctx, `
+		SELECT reason, MIN(id), MAX(id), MIN(created_at), MAX(created_at)
+		FROM audit_chain_tombstones WHERE reason = 'retention'
+		GROUP BY reason
+		UNION ALL
+		SELECT reason, id, last_id, created_at, last_created_at
+		FROM audit_chain_ranges
+		ORDER BY 2 ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query removed rows: %w", err)
+	}
+	defer rows.Close()
+
+	var gaps []Gap
+	for rows.Next() {
+		var gap Gap
+		var from, to time.Time
+		if err := rows.Scan(&gap.Reason, &gap.FirstID, &gap.LastID, &from, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan removed rows: %w", err)
+		}
+		gap.From = from.UTC().Format(time.RFC3339)
+		gap.To = to.UTC().Format(time.RFC3339)
+		gaps = append(gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating removed rows: %w", err)
+	}
+	return gaps, nil
+}
+
+// csvRecord renders an entry in Columns order: strings as is, other JSON values
+// (objects, numbers, booleans) as compact JSON, and null as an empty field
+func csvRecord(entry map[string]json.RawMessage) []string {
+	record := make([]string, len(Columns))
+	for i, column := range Columns {
+		value := entry[column]
+		if len(value) == 0 || string(value) == "null" {
+			continue
+		}
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			record[i] = text
+			continue
+		}
+		record[i] = string(value)
+	}
+	return record
+}
+
+// tally accumulates the summary while entries are streamed
+type tally struct {
+	summary  Summary
+	sessions map[string]bool
+}
+
+func newTally(userID string) *tally {
+	return &tally{
+		summary: Summary{
+			UserID:     userID,
+			ByAction:   map[string]int64{},
+			ByEndpoint: map[string]int64{},
+			ByMonth:    map[string]int64{},
+			ByOutcome:  map[string]int64{},
+		},
+		sessions: map[string]bool{},
+	}
+}
+
+func (t *tally) add(entry map[string]json.RawMessage) {
+	var fields struct {
+		CreatedAt    time.Time `json:"created_at"`
+		Action       *string   `json:"action"`
+		EndpointPath string    `json:"endpoint_path"`
+		Outcome      *string   `json:"outcome"`
+		SessionID    *string   `json:"session_id"`
+		TokensUsed   *int64    `json:"tokens_used"`
+	}
+	for key, target := range map[string]interface{}{
+		"created_at": &fields.CreatedAt, "action": &fields.Action, "endpoint_path": &fields.EndpointPath,
+		"outcome": &fields.Outcome, "session_id": &fields.SessionID, "tokens_used": &fields.TokensUsed,
+	} {
+		if value, ok := entry[key]; ok {
+			json.Unmarshal(value, target)
+		}
+	}
+
+	s := &t.summary
+	s.RowCount++
+	createdAt := fields.CreatedAt.UTC().Format(time.RFC3339)
+	if s.FirstSeen == nil {
+		s.FirstSeen = &createdAt
+	}
+	s.LastSeen = &createdAt
+	s.ByMonth[fields.CreatedAt.UTC().Format("2006-01")]++
+	s.ByEndpoint[fields.EndpointPath]++
+	if fields.Action != nil {
+		s.ByAction[*fields.Action]++
+	}
+	if fields.Outcome != nil {
+		s.ByOutcome[*fields.Outcome]++
+	}
+	if fields.SessionID != nil {
+		t.sessions[*fields.SessionID] = true
+	}
+	if fields.TokensUsed != nil {
+		s.TokensUsed += *fields.TokensUsed
+	}
+}
+
+func (t *tally) finish() *Summary {
+	t.summary.Sessions = len(t.sessions)
+	return &t.summary
+}
+
+// hashedEntry is a zip entry that records its size and SHA-256 as it is written
+type hashedEntry struct {
+	name  string
+	w     io.Writer
+	hash  hash.Hash
+	bytes int64
+}
+
+func newHashedEntry(archive *zip.Writer, name string) (*hashedEntry, error) {
+	w, err := archive.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return &hashedEntry{name: name, w: w, hash: sha256.New()}, nil
+}
+
+func (h *hashedEntry) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.hash.Write(p[:n])
+	h.bytes += int64(n)
+	return n, err
+}
+
+func (h *hashedEntry) file() File {
+	return File{Name: h.name, Bytes: h.bytes, SHA256: hex.EncodeToString(h.hash.Sum(nil))}
+}
+
+// Verify checks an export archive offline: the manifest signature against publicKey
+// and every listed file against its size and SHA-256
+func Verify(path string, publicKey ed25519.PublicKey) (*Manifest, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer archive.Close()
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	manifestFile, ok := files[ManifestFile]
+	if !ok {
+		return nil, errors.New("export has no manifest (incomplete download?)")
+	}
+	var manifest Manifest
+	if err := readJSON(manifestFile, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if manifest.KeyID != checkpoint.KeyID(publicKey) {
+		return nil, fmt.Errorf("export was signed by key %s, not %s", manifest.KeyID, checkpoint.KeyID(publicKey))
+	}
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, manifest.Message(), signature) {
+		return nil, errors.New("invalid manifest signature")
+	}
+
+	for _, expected := range manifest.Files {
+		file, ok := files[expected.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is missing", expected.Name)
+		}
+		actual, err := hashZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if actual != expected {
+			return nil, fmt.Errorf("%s does not match the manifest (sha256 %s, %d bytes)", expected.Name, actual.SHA256, actual.Bytes)
+		}
+	}
+	return &manifest, nil
+}
+
+func readJSON(file *zip.File, target interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return json.NewDecoder(reader).Decode(target)
+}
+
+func hashZipFile(file *zip.File) (File, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	bytes, err := io.Copy(hasher, reader)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	return File{Name: file.Name, Bytes: bytes, SHA256: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
@@ -0,0 +1,252 @@
+package dsar
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/motiso/sparksai-audit-service/internal/checkpoint"
+)
+
+func TestManifestMessage(t *testing.T) {
+	manifest := Manifest{
+		Version:     messageVersion,
+		UserID:      "u1",
+		GeneratedAt: "2026-03-01T10:00:00Z",
+		Format:      FormatJSON,
+		RowCount:    2,
+		Masked:      1,
+		Excluded: []Gap{
+			{Reason: "retention", FirstID: 1, LastID: 40, From: "2025-01-01T00:00:00Z", To: "2025-06-30T23:59:59Z"},
+			{Reason: "partition_detach", FirstID: 41, LastID: 90, From: "2025-07-01T00:00:00Z", To: "2025-07-31T23:59:59Z"},
+		},
+		Files: []File{{Name: "entries.json", Bytes: 10, SHA256: "ab"}, {Name: SummaryFile, Bytes: 5, SHA256: "cd"}},
+	}
+	v1 := manifest
+	v1.Version = messageVersionV1
+
+	tests := []struct {
+		name     string
+		manifest Manifest
+		want     string
+	}{
+		{
+			name:     "v2 signs the masked count and excluded ranges",
+			manifest: manifest,
+			want: "sparksai-audit-export/v2\nu1\n2026-03-01T10:00:00Z\njson\n2\n" +
+				"masked 1\n" +
+				"excluded retention 1 40 2025-01-01T00:00:00Z 2025-06-30T23:59:59Z\n" +
+				"excluded partition_detach 41 90 2025-07-01T00:00:00Z 2025-07-31T23:59:59Z\n" +
+				"entries.json 10 ab\nsummary.json 5 cd\n",
+		},
+		{
+			name:     "v1 keeps its original form",
+			manifest: v1,
+			want:     "sparksai-audit-export/v1\nu1\n2026-03-01T10:00:00Z\njson\n2\nentries.json 10 ab\nsummary.json 5 cd\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.manifest.Message()); got != tt.want {
+				t.Errorf("Message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testArchive describes an export archive to write; the mutate hooks run before
+// signing, after signing and before the files are zipped
+type testArchive struct {
+	version    string
+	beforeSign func(m *Manifest)
+	afterSign  func(m *Manifest)
+	files      func(files map[string]string)
+	key        ed25519.PrivateKey
+}
+
+// write builds the archive as Export does and returns its path
+func (a testArchive) write(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	files := map[string]string{
+		EntriesFile(FormatJSON): "[\n{\"id\":7,\"user_id\":\"u1\"}\n]\n",
+		SummaryFile:             "{\n  \"user_id\": \"u1\",\n  \"row_count\": 1\n}\n",
+	}
+	manifest := Manifest{
+		Version:     messageVersion,
+		UserID:      "u1",
+		GeneratedAt: "2026-03-01T10:00:00Z",
+		Format:      FormatJSON,
+		RowCount:    1,
+		Masked:      1,
+		Excluded:    []Gap{{Reason: "retention", FirstID: 1, LastID: 6, From: "2025-01-01T00:00:00Z", To: "2025-06-30T00:00:00Z"}},
+	}
+	if a.version != "" {
+		manifest.Version = a.version
+	}
+	for _, name := range []string{EntriesFile(FormatJSON), SummaryFile} {
+		sum := sha256.Sum256([]byte(files[name]))
+		manifest.Files = append(manifest.Files, File{Name: name, Bytes: int64(len(files[name])), SHA256: hex.EncodeToString(sum[:])})
+	}
+	if a.beforeSign != nil {
+		a.beforeSign(&manifest)
+	}
+	if a.key != nil {
+		key = a.key
+	}
+	manifest.KeyID = checkpoint.KeyID(key.Public().(ed25519.PublicKey))
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest.Message()))
+	if a.afterSign != nil {
+		a.afterSign(&manifest)
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode manifest: %v", err)
+	}
+	files[ManifestFile] = string(encoded)
+	if a.files != nil {
+		a.files(files)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.zip")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer out.Close()
+	archive := zip.NewWriter(out)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	otherKey := ed25519.NewKeyFromSeed([]byte(strings.Repeat("k", ed25519.SeedSize)))
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name    string
+		archive testArchive
+		wantErr string
+	}{
+		{name: "valid export"},
+		{
+			name:    "export signed before masked counts and excluded ranges",
+			archive: testArchive{version: messageVersionV1},
+		},
+		{
+			name:    "files not listed in the manifest are ignored",
+			archive: testArchive{files: func(f map[string]string) { f["notes.txt"] = "added later" }},
+		},
+		{
+			name:    "masked count lowered",
+			archive: testArchive{afterSign: func(m *Manifest) { m.Masked = 0 }},
+			wantErr: "invalid manifest signature",
+		},
+		{
+			name:    "excluded range dropped",
+			archive: testArchive{afterSign: func(m *Manifest) { m.Excluded = nil }},
+			wantErr: "invalid manifest signature",
+		},
+		{
+			name:    "downgraded to v1 to hide the masked count",
+			archive: testArchive{afterSign: func(m *Manifest) { m.Version = messageVersionV1 }},
+			wantErr: "invalid manifest signature",
+		},
+		{
+			name:    "row count changed",
+			archive: testArchive{afterSign: func(m *Manifest) { m.RowCount = 2 }},
+			wantErr: "invalid manifest signature",
+		},
+		{
+			name: "entries edited",
+			archive: testArchive{files: func(f map[string]string) {
+				f[EntriesFile(FormatJSON)] = strings.Replace(f[EntriesFile(FormatJSON)], `"id":7`, `"id":8`, 1)
+			}},
+			wantErr: "entries.json does not match the manifest",
+		},
+		{
+			name:    "summary missing",
+			archive: testArchive{files: func(f map[string]string) { delete(f, SummaryFile) }},
+			wantErr: "summary.json is missing",
+		},
+		{
+			name:    "manifest missing",
+			archive: testArchive{files: func(f map[string]string) { delete(f, ManifestFile) }},
+			wantErr: "export has no manifest",
+		},
+		{
+			name:    "manifest is not JSON",
+			archive: testArchive{files: func(f map[string]string) { f[ManifestFile] = "{" }},
+			wantErr: "invalid manifest",
+		},
+		{
+			name:    "signed by another key",
+			archive: testArchive{key: otherKey},
+			wantErr: "export was signed by key",
+		},
+		{
+			name: "signed by another key under the expected key id",
+			archive: testArchive{
+				key:       otherKey,
+				afterSign: func(m *Manifest) { m.KeyID = checkpoint.KeyID(publicKey) },
+			},
+			wantErr: "invalid manifest signature",
+		},
+		{
+			name:    "signature is not base64",
+			archive: testArchive{afterSign: func(m *Manifest) { m.Signature = "!" }},
+			wantErr: "invalid signature encoding",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := Verify(tt.archive.write(t, key), publicKey)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Verify() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error: %v", err)
+			}
+			if manifest.UserID != "u1" || len(manifest.Files) != 2 {
+				t.Errorf("Verify() = %+v, want the manifest of u1", manifest)
+			}
+		})
+	}
+}
+
+func TestVerifyNotAnArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.zip")
+	if err := os.WriteFile(path, []byte("partial download"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := Verify(path, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)); err == nil || !strings.Contains(err.Error(), "failed to open export") {
+		t.Errorf("Verify() error = %v, want it to contain %q", err, "failed to open export")
+	}
+}
@@ -0,0 +1,50 @@
+package dsar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ExportHandler handles GET /api/admin/exports/users/{user_id}
+// Query parameters: format (optional, json or csv, default: json)
+// Streams a zip of the user's entries, summary.json and a signed manifest.json; payloads
+// encrypted at rest are decrypted, since the route is only open to admins
+func (e *Exporter) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID := strings.TrimSpace(mux.Vars(r)["user_id"])
+	if userID == "" {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatCSV {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	if e.Key == nil {
+		http.Error(w, e.KeyErr.Error(), http.StatusConflict)
+		return
+	}
+
+	filename := fmt.Sprintf("subject_export_%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	// Once streaming has started the status can no longer change; a failed export is
+	// left without its manifest, which "export verify" reports
+	if _, err := e.Export(r.Context(), w, userID, format); err != nil {
+		if errors.Is(err, ErrNoKey) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("error occurred during subject Export: %v", err)
+	}
+}
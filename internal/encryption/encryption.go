@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvelopeKey marks an encrypted JSONB value: {"$enc": "v1", "key": ..., "nonce": ..., "data": ...}
+const EnvelopeKey = "$enc"
+
+// envelopeVersion is written to EnvelopeKey by Encrypt
+const envelopeVersion = "v1"
+
+// ReaderTokenHeader carries the token that lets a request read decrypted payloads
+const ReaderTokenHeader = "X-Audit-Reader-Token"
+
+// EncryptableColumns are the audit_logs payload columns that can be encrypted
+var EncryptableColumns = []string{"query_raw", "body_raw", "response_body"}
+
+// masked replaces an encrypted value in responses to requests without a reader token
+const masked = `{"encrypted":true}`
+
+// Config controls payload encryption
+type Config struct {
+	Enabled      bool
+	KeyFile      string        // Master keys, one "<id> <base64 key>" per line; the last one is active
+	Columns      []string      // Payload columns encrypted on insert
+	ReaderTokens []string      // Tokens accepted in X-Audit-Reader-Token
+	KeyLifetime  time.Duration // Age after which the rotation job replaces the active data key
+}
+
+// LoadConfig reads the encryption settings from viper
+func LoadConfig() Config {
+	columns := splitList(viper.GetString("AUDIT_ENCRYPTION_COLUMNS"))
+	if len(columns) == 0 {
+		columns = []string{"body_raw", "response_body"} // default
+	}
+
+	lifetimeDays := viper.GetInt("AUDIT_ENCRYPTION_KEY_LIFETIME_DAYS")
+	if lifetimeDays <= 0 {
+		lifetimeDays = 30 // default
+	}
+
+	return Config{
+		Enabled:      viper.GetBool("AUDIT_ENCRYPTION_ENABLED"),
+		KeyFile:      viper.GetString("AUDIT_ENCRYPTION_KEY_FILE"),
+		Columns:      columns,
+		ReaderTokens: splitList(viper.GetString("AUDIT_ENCRYPTION_READER_TOKENS")),
+		KeyLifetime:  time.Duration(lifetimeDays) * 24 * time.Hour,
+	}
+}
+
+// splitList splits a ";"-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envelope is the stored form of an encrypted value
+type envelope struct {
+	Version string `json:"$enc"`
+	KeyID   int64  `json:"key"`   // audit_data_keys id
+	Nonce   []byte `json:"nonce"` // base64 in JSON
+	Data    []byte `json:"data"`  // AES-256-GCM ciphertext and tag, base64 in JSON
+}
+
+// IsEnvelope reports whether a stored JSONB value is encrypted
+func IsEnvelope(value string) bool {
+	if !strings.Contains(value, EnvelopeKey) {
+		return false
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &probe); err != nil {
+		return false
+	}
+	_, ok := probe[EnvelopeKey]
+	return ok
+}
+
+// seal encrypts plaintext with AES-256-GCM; additionalData is authenticated but not stored
+func seal(key, plaintext, additionalData []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts what seal produced
+func open(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readerKey is the context key set for requests carrying a valid reader token
+type readerKey struct{}
+
+// WithReader marks ctx as allowed to read decrypted payloads
+func WithReader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readerKey{}, true)
+}
+
+// IsReader reports whether ctx may read decrypted payloads
+func IsReader(ctx context.Context) bool {
+	reader, _ := ctx.Value(readerKey{}).(bool)
+	return reader
+}
+
+// Middleware marks requests with a valid X-Audit-Reader-Token as readers
+func (k *Keyring) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get(ReaderTokenHeader); token != "" && k.validToken(token) {
+			r = r.WithContext(WithReader(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validToken compares token against every configured reader token in constant time
+func (k *Keyring) validToken(token string) bool {
+	valid := false
+	for _, candidate := range k.ReaderTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// validateColumns rejects columns that are not payload columns
+func validateColumns(columns []string) error {
+	for _, column := range columns {
+		known := false
+		for _, encryptable := range EncryptableColumns {
+			if column == encryptable {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("AUDIT_ENCRYPTION_COLUMNS: %q cannot be encrypted (expected %s)", column, strings.Join(EncryptableColumns, ", "))
+		}
+	}
+	return nil
+}
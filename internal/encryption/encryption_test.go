@@ -0,0 +1,123 @@
+package encryption
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	nonce, ciphertext, err := seal(key, []byte(`{"question":"q"}`), []byte("body_raw"))
+	if err != nil {
+		t.Fatalf("seal() error: %v", err)
+	}
+	tampered := append([]byte{}, ciphertext...)
+	tampered[0] ^= 0xff
+
+	tests := []struct {
+		name           string
+		key            []byte
+		nonce          []byte
+		ciphertext     []byte
+		additionalData string
+		wantErr        bool
+	}{
+		{"same key and column", key, nonce, ciphertext, "body_raw", false},
+		{"other key", bytes.Repeat([]byte{2}, 32), nonce, ciphertext, "body_raw", true},
+		{"other column", key, nonce, ciphertext, "response_body", true},
+		{"tampered ciphertext", key, nonce, tampered, "body_raw", true},
+		{"truncated tag", key, nonce, ciphertext[:len(ciphertext)-1], "body_raw", true},
+		{"short nonce", key, nonce[:8], ciphertext, "body_raw", true},
+		{"key of the wrong size", key[:20], nonce, ciphertext, "body_raw", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := open(tt.key, tt.nonce, tt.ciphertext, []byte(tt.additionalData))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("open() = %q, want an error", plaintext)
+				}
+				return
+			}
+			if err != nil || string(plaintext) != `{"question":"q"}` {
+				t.Errorf("open() = %q, %v, want the original plaintext", plaintext, err)
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	first, _, err := seal(key, []byte("x"), nil)
+	if err != nil {
+		t.Fatalf("seal() error: %v", err)
+	}
+	second, _, err := seal(key, []byte("x"), nil)
+	if err != nil {
+		t.Fatalf("seal() error: %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("seal() reused a nonce")
+	}
+}
+
+func TestIsEnvelope(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{`{"$enc":"v1","key":1,"nonce":"AAAA","data":"AAAA"}`, true},
+		{`{"$enc": "v2"}`, true},
+		{`{"question":"what is $enc?"}`, false},
+		{`{"nested":{"$enc":"v1"}}`, false},
+		{`["$enc"]`, false},
+		{`"$enc"`, false},
+		{`{"$enc":`, false},
+		{`{"encrypted":true}`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := IsEnvelope(tt.value); got != tt.want {
+			t.Errorf("IsEnvelope(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidToken(t *testing.T) {
+	k := &Keyring{ReaderTokens: []string{"first-token", "second-token"}}
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"first-token", true},
+		{"second-token", true},
+		{"first-toke", false},
+		{"first-token ", false},
+		{"FIRST-TOKEN", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := k.validToken(tt.token); got != tt.want {
+			t.Errorf("validToken(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	tests := []struct {
+		columns []string
+		wantErr string
+	}{
+		{nil, ""},
+		{[]string{"query_raw", "body_raw", "response_body"}, ""},
+		{[]string{"body_raw", "user_id"}, `"user_id" cannot be encrypted`},
+		{[]string{"BODY_RAW"}, `"BODY_RAW" cannot be encrypted`},
+	}
+	for _, tt := range tests {
+		err := validateColumns(tt.columns)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateColumns(%v) error = %v, want %q", tt.columns, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKey wraps data keys; it never leaves the keyfile
+type MasterKey struct {
+	ID  string
+	Key []byte // 32 bytes, AES-256
+}
+
+// LoadMasterKeys reads a keyfile of "<id> <base64 key>" lines ("#" starts a comment)
+// The last key is the active one; earlier keys are kept to unwrap data keys until
+// the rotation job has re-wrapped them with the active key
+func LoadMasterKeys(path string) ([]MasterKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+
+	var keys []MasterKey
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<id> <base64 key>\"", path, lineNumber)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s:%d: key must be 32 bytes, base64 encoded", path, lineNumber)
+		}
+		if len(fields[0]) > 64 {
+			return nil, fmt.Errorf("%s:%d: key id is longer than 64 characters", path, lineNumber)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("%s:%d: duplicate key id %q", path, lineNumber, fields[0])
+		}
+		seen[fields[0]] = true
+		keys = append(keys, MasterKey{ID: fields[0], Key: key})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("master key file has no keys")
+	}
+	return keys, nil
+}
+
+// GenerateMasterKey appends a new random master key to path (creating it if needed),
+// making it the active key, and returns its id
+func GenerateMasterKey(path string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	line := fmt.Sprintf("%s %s\n", id, base64.StdEncoding.EncodeToString(key))
+	if existing, err := os.ReadFile(path); err == nil && len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		line = "\n" + line
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open master key file: %w", err)
+	}
+	if _, err := file.WriteString(line); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write master key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write master key: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,84 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMasterKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	tests := []struct {
+		name    string
+		content string
+		wantIDs []string
+		wantErr string
+	}{
+		{"keys with comments and blank lines", "# master keys\n\nk1 " + key + "\n  k2   " + key + "  \n", []string{"k1", "k2"}, ""},
+		{"empty file", "# nothing yet\n", nil, "has no keys"},
+		{"missing key", "k1\n", nil, `:1: expected "<id> <base64 key>"`},
+		{"extra field", "k1 " + key + " extra\n", nil, `:1: expected`},
+		{"short key", "k1 " + base64.StdEncoding.EncodeToString([]byte("short")) + "\n", nil, ":1: key must be 32 bytes"},
+		{"not base64", "k1 !!!\n", nil, ":1: key must be 32 bytes"},
+		{"long id", strings.Repeat("k", 65) + " " + key + "\n", nil, "longer than 64 characters"},
+		{"duplicate id", "k1 " + key + "\n# again\nk1 " + key + "\n", nil, `:3: duplicate key id "k1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "master.keys")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write keyfile: %v", err)
+			}
+			keys, err := LoadMasterKeys(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadMasterKeys() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMasterKeys() error: %v", err)
+			}
+			var ids []string
+			for _, k := range keys {
+				ids = append(ids, k.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("LoadMasterKeys() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGenerateMasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.keys")
+	// A file without a trailing newline must not merge the new key into its last line
+	if err := os.WriteFile(path, []byte("# keys"), 0o600); err != nil {
+		t.Fatalf("failed to write keyfile: %v", err)
+	}
+	first, err := GenerateMasterKey(path)
+	if err != nil {
+		t.Fatalf("GenerateMasterKey() error: %v", err)
+	}
+	second, err := GenerateMasterKey(path)
+	if err != nil {
+		t.Fatalf("GenerateMasterKey() error: %v", err)
+	}
+	keys, err := LoadMasterKeys(path)
+	if err != nil {
+		t.Fatalf("LoadMasterKeys() error: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != first || keys[1].ID != second {
+		t.Errorf("keyfile holds %+v, want %s then %s", keys, first, second)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat keyfile: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("keyfile mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,422 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// lockKey serializes data key rotation across instances
+const lockKey int64 = 7263842004
+
+// rotationCheckInterval is how often the background job checks the active data key
+const rotationCheckInterval = time.Hour
+
+// activeKeyTTL is how long the cached active data key is used before it is read again,
+// so keys rotated by another instance or by "encryption rotate" are picked up
+const activeKeyTTL = time.Minute
+
+// Keyring encrypts payload columns with data keys stored in audit_data_keys, each
+// wrapped by a master key from the keyfile, and decrypts them for readers
+type Keyring struct {
+	DB           *sql.DB
+	Enabled      bool            // Encrypt new rows; stored envelopes are decrypted either way
+	Columns      map[string]bool // Payload columns encrypted on insert
+	ReaderTokens []string
+	KeyLifetime  time.Duration
+	masterKeys   map[string][]byte
+	activeMaster string // Empty when no keyfile is configured
+
+	mu           sync.Mutex
+	dataKeys     map[int64][]byte // Unwrapped data keys by id
+	activeKey    int64            // Data key new values are encrypted with; 0 until first used
+	activeLoaded time.Time        // When activeKey was last read from audit_data_keys
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Rotation is the outcome of a Rotate call
+type Rotation struct {
+	Created   int64 // Id of the new active data key, 0 when the previous one was kept
+	Rewrapped int   // Data keys re-wrapped with the active master key
+}
+
+// NewKeyring loads the master keys; a keyfile is required when encryption is enabled
+func NewKeyring(db *sql.DB, cfg Config) (*Keyring, error) {
+	if err := validateColumns(cfg.Columns); err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{
+		DB:           db,
+		Enabled:      cfg.Enabled,
+		Columns:      map[string]bool{},
+		ReaderTokens: cfg.ReaderTokens,
+		KeyLifetime:  cfg.KeyLifetime,
+		masterKeys:   map[string][]byte{},
+		dataKeys:     map[int64][]byte{},
+	}
+	for _, column := range cfg.Columns {
+		k.Columns[column] = true
+	}
+
+	if cfg.KeyFile != "" {
+		keys, err := LoadMasterKeys(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			k.masterKeys[key.ID] = key.Key
+		}
+		k.activeMaster = keys[len(keys)-1].ID
+	} else if cfg.Enabled {
+		return nil, errors.New("AUDIT_ENCRYPTION_ENABLED requires AUDIT_ENCRYPTION_KEY_FILE")
+	}
+	if cfg.Enabled && len(cfg.ReaderTokens) == 0 {
+		log.Printf("[ENCRYPTION] AUDIT_ENCRYPTION_READER_TOKENS is empty - encrypted payloads are masked for every request")
+	}
+	return k, nil
+}
+
+// Encrypt replaces a JSONB value with its envelope when column is encrypted
+// The column name is authenticated, so an envelope cannot be moved to another column
+func (k *Keyring) Encrypt(ctx context.Context, column string, value *string) (*string, error) {
+	if k == nil || !k.Enabled || !k.Columns[column] || value == nil {
+		return value, nil
+	}
+
+	keyID, key, err := k.currentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce, data, err := seal(key, []byte(*value), []byte(column))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", column, err)
+	}
+	encoded, err := json.Marshal(envelope{Version: envelopeVersion, KeyID: keyID, Nonce: nonce, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	sealed := string(encoded)
+	return &sealed, nil
+}
+
+// Reveal returns a stored value as readers see it: decrypted for requests marked by
+// Middleware, masked for the others. Values that are not encrypted are returned as is.
+func (k *Keyring) Reveal(ctx context.Context, column string, value *string) (*string, error) {
+	if value == nil || !IsEnvelope(*value) {
+		return value, nil
+	}
+	if !IsReader(ctx) {
+		hidden := masked
+		return &hidden, nil
+	}
+	plaintext, err := k.Decrypt(ctx, column, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &plaintext, nil
+}
+
+// Decrypt opens an envelope stored in column
+func (k *Keyring) Decrypt(ctx context.Context, column string, value string) (string, error) {
+	if k == nil {
+		return "", errors.New("payload encryption is not available")
+	}
+	var env envelope
+	if err := json.Unmarshal([]byte(value), &env); err != nil {
+		return "", fmt.Errorf("invalid %s envelope: %w", column, err)
+	}
+	if env.Version != envelopeVersion {
+		return "", fmt.Errorf("unsupported %s envelope version %q", column, env.Version)
+	}
+	key, err := k.dataKey(ctx, env.KeyID)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(key, env.Nonce, env.Data, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s with data key %d: %w", column, env.KeyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// Start runs Rotate now and then periodically in the background when encryption is enabled
+func (k *Keyring) Start() {
+	if !k.Enabled {
+		log.Printf("[ENCRYPTION] Payload encryption disabled")
+		return
+	}
+	log.Printf("[ENCRYPTION] Encrypting %d column(s) with master key %s", len(k.Columns), k.activeMaster)
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+
+	go func() {
+		defer close(k.done)
+		ticker := time.NewTicker(rotationCheckInterval)
+		defer ticker.Stop()
+		for {
+			if rotation, err := k.Rotate(context.Background(), false); err != nil {
+				log.Printf("[ENCRYPTION ERROR] Failed to rotate keys: %v", err)
+			} else {
+				logRotation(rotation)
+			}
+			select {
+			case <-ticker.C:
+			case <-k.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background job, waiting for a rotation in progress
+func (k *Keyring) Stop() {
+	if k.stop == nil {
+		return
+	}
+	close(k.stop)
+	<-k.done
+	k.stop = nil
+}
+
+func logRotation(rotation Rotation) {
+	if rotation.Created != 0 {
+		log.Printf("[ENCRYPTION] Rotated to data key %d", rotation.Created)
+	}
+	if rotation.Rewrapped > 0 {
+		log.Printf("[ENCRYPTION] Re-wrapped %d data key(s) with the active master key", rotation.Rewrapped)
+	}
+}
+
+// Rotate re-wraps data keys still wrapped by an older master key, and replaces the
+// active data key when there is none, it is older than KeyLifetime, or force is set.
+// Retired data keys stay available for decryption; stored rows are never rewritten,
+// since their ciphertext is part of the hash chain.
+func (k *Keyring) Rotate(ctx context.Context, force bool) (Rotation, error) {
+	var rotation Rotation
+	if k.activeMaster == "" {
+		return rotation, errors.New("AUDIT_ENCRYPTION_KEY_FILE is not set")
+	}
+
+	tx, err := k.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rotation, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return rotation, fmt.Errorf("failed to acquire encryption lock: %w", err)
+	}
+
+	rotation.Rewrapped, err = k.rewrap(ctx, tx)
+	if err != nil {
+		return rotation, err
+	}
+
+	var createdAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT created_at FROM audit_data_keys
+		WHERE retired_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1`).Scan(&createdAt)
+	if err != nil && err != sql.ErrNoRows {
+		return rotation, fmt.Errorf("failed to read active data key: %w", err)
+	}
+	if err == sql.ErrNoRows || force || time.Since(createdAt) >= k.KeyLifetime {
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			return rotation, err
+		}
+		wrapped, err := k.wrap(dataKey)
+		if err != nil {
+			return rotation, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE audit_data_keys SET retired_at = CURRENT_TIMESTAMP WHERE retired_at IS NULL`); err != nil {
+			return rotation, fmt.Errorf("failed to retire data key: %w", err)
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO audit_data_keys (master_key_id, wrapped_key)
+			VALUES ($1, $2)
+			RETURNING id`, k.activeMaster, wrapped).Scan(&rotation.Created)
+		if err != nil {
+			return rotation, fmt.Errorf("failed to store data key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rotation, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+	return rotation, k.loadActiveKey(ctx)
+}
+
+// rewrap re-encrypts every data key wrapped by another master key with the active one
+func (k *Keyring) rewrap(ctx context.Context, tx *sql.Tx) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, master_key_id, wrapped_key FROM audit_data_keys
+		WHERE master_key_id != $1
+		ORDER BY id
+		FOR UPDATE`, k.activeMaster)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query data keys: %w", err)
+	}
+	type storedKey struct {
+		id       int64
+		masterID string
+		wrapped  string
+	}
+	var stale []storedKey
+	for rows.Next() {
+		var stored storedKey
+		if err := rows.Scan(&stored.id, &stored.masterID, &stored.wrapped); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan data key: %w", err)
+		}
+		stale = append(stale, stored)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating data keys: %w", err)
+	}
+
+	for _, stored := range stale {
+		dataKey, err := k.unwrap(stored.masterID, stored.wrapped)
+		if err != nil {
+			return 0, fmt.Errorf("data key %d: %w", stored.id, err)
+		}
+		wrapped, err := k.wrap(dataKey)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE audit_data_keys
+			SET master_key_id = $1, wrapped_key = $2, rewrapped_at = CURRENT_TIMESTAMP
+			WHERE id = $3`, k.activeMaster, wrapped, stored.id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-wrap data key %d: %w", stored.id, err)
+		}
+	}
+	return len(stale), nil
+}
+
+// currentKey returns the data key new values are encrypted with, creating one if needed
+// The cached key is read again once it is older than activeKeyTTL
+func (k *Keyring) currentKey(ctx context.Context) (int64, []byte, error) {
+	k.mu.Lock()
+	id, key := k.activeKey, k.dataKeys[k.activeKey]
+	fresh := time.Since(k.activeLoaded) < activeKeyTTL
+	k.mu.Unlock()
+	if id != 0 && fresh {
+		return id, key, nil
+	}
+
+	if err := k.loadActiveKey(ctx); err != nil {
+		return 0, nil, err
+	}
+	k.mu.Lock()
+	id, key = k.activeKey, k.dataKeys[k.activeKey]
+	k.mu.Unlock()
+	if id != 0 {
+		return id, key, nil
+	}
+
+	// First encrypted insert: create the first data key
+	if _, err := k.Rotate(ctx, false); err != nil {
+		return 0, nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.activeKey, k.dataKeys[k.activeKey], nil
+}
+
+// loadActiveKey caches the newest unretired data key, or clears the cache when there is none
+func (k *Keyring) loadActiveKey(ctx context.Context) error {
+	var id int64
+	var masterID, wrapped string
+	err := k.DB.QueryRowContext(ctx, `
+		SELECT id, master_key_id, wrapped_key FROM audit_data_keys
+		WHERE retired_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1`).Scan(&id, &masterID, &wrapped)
+	if err == sql.ErrNoRows {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		k.activeKey = 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read active data key: %w", err)
+	}
+	key, err := k.unwrap(masterID, wrapped)
+	if err != nil {
+		return fmt.Errorf("data key %d: %w", id, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.dataKeys[id] = key
+	k.activeKey = id
+	k.activeLoaded = time.Now()
+	return nil
+}
+
+// dataKey returns an unwrapped data key by id, loading it on first use
+func (k *Keyring) dataKey(ctx context.Context, id int64) ([]byte, error) {
+	k.mu.Lock()
+	key, ok := k.dataKeys[id]
+	k.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var masterID, wrapped string
+	err := k.DB.QueryRowContext(ctx, `SELECT master_key_id, wrapped_key FROM audit_data_keys WHERE id = $1`, id).Scan(&masterID, &wrapped)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("data key %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data key %d: %w", id, err)
+	}
+	key, err = k.unwrap(masterID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("data key %d: %w", id, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.dataKeys[id] = key
+	return key, nil
+}
+
+// wrap encrypts a data key with the active master key as base64(nonce || ciphertext)
+func (k *Keyring) wrap(dataKey []byte) (string, error) {
+	nonce, ciphertext, err := seal(k.masterKeys[k.activeMaster], dataKey, []byte(k.activeMaster))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(append(nonce, ciphertext...)), nil
+}
+
+// unwrap decrypts a data key wrapped by the master key masterID
+func (k *Keyring) unwrap(masterID string, wrapped string) ([]byte, error) {
+	masterKey, ok := k.masterKeys[masterID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not in the key file", masterID)
+	}
+	raw, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(raw) < 12 {
+		return nil, errors.New("invalid wrapped key")
+	}
+	dataKey, err := open(masterKey, raw[:12], raw[12:], []byte(masterID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap with master key %q: %w", masterID, err)
+	}
+	return dataKey, nil
+}
@@ -0,0 +1,218 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// testKeyring returns a keyring with master keys "old" and "new" (active) whose
+// audit_data_keys table lives in an in-memory SQLite database
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "master.keys")
+	keyfile := "old " + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)) + "\n" +
+		"new " + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)) + "\n"
+	if err := os.WriteFile(path, []byte(keyfile), 0o600); err != nil {
+		t.Fatalf("failed to write keyfile: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE audit_data_keys (id INTEGER PRIMARY KEY, master_key_id TEXT, wrapped_key TEXT)`); err != nil {
+		t.Fatalf("failed to create audit_data_keys: %v", err)
+	}
+
+	k, err := NewKeyring(db, Config{Enabled: true, KeyFile: path, Columns: []string{"body_raw", "response_body"}})
+	if err != nil {
+		t.Fatalf("NewKeyring() error: %v", err)
+	}
+	return k
+}
+
+// storeDataKey wraps dataKey with the master key masterID and inserts it as data key id
+func storeDataKey(t *testing.T, k *Keyring, id int64, masterID string, dataKey []byte) {
+	t.Helper()
+	active := k.activeMaster
+	k.activeMaster = masterID
+	wrapped, err := k.wrap(dataKey)
+	k.activeMaster = active
+	if err != nil {
+		t.Fatalf("wrap() error: %v", err)
+	}
+	if _, err := k.DB.Exec(`INSERT INTO audit_data_keys (id, master_key_id, wrapped_key) VALUES ($1, $2, $3)`, id, masterID, wrapped); err != nil {
+		t.Fatalf("failed to store data key: %v", err)
+	}
+}
+
+func TestWrapUnwrap(t *testing.T) {
+	k := testKeyring(t)
+	dataKey := bytes.Repeat([]byte{9}, 32)
+	wrapped, err := k.wrap(dataKey)
+	if err != nil {
+		t.Fatalf("wrap() error: %v", err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(wrapped)
+	raw[len(raw)-1] ^= 0xff
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name     string
+		masterID string
+		wrapped  string
+		wantErr  string
+	}{
+		{"active master key", "new", wrapped, ""},
+		{"other master key", "old", wrapped, `failed to unwrap with master key "old"`},
+		{"master key not in the keyfile", "gone", wrapped, `master key "gone" is not in the key file`},
+		{"tampered wrapped key", "new", tampered, "failed to unwrap"},
+		{"not base64", "new", "!!", "invalid wrapped key"},
+		{"shorter than a nonce", "new", base64.StdEncoding.EncodeToString([]byte("short")), "invalid wrapped key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.unwrap(tt.masterID, tt.wrapped)
+			if tt.wantErr == "" {
+				if err != nil || !bytes.Equal(got, dataKey) {
+					t.Errorf("unwrap() = %x, %v, want %x", got, err, dataKey)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("unwrap() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	k := testKeyring(t)
+	storeDataKey(t, k, 1, "old", bytes.Repeat([]byte{3}, 32))
+	storeDataKey(t, k, 2, "new", bytes.Repeat([]byte{4}, 32))
+	k.activeKey, k.dataKeys[2], k.activeLoaded = 2, bytes.Repeat([]byte{4}, 32), time.Now()
+
+	value := `{"question":"q"}`
+	sealed, err := k.Encrypt(ctx, "body_raw", &value)
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	if !IsEnvelope(*sealed) || strings.Contains(*sealed, "question") {
+		t.Fatalf("Encrypt() = %s, want an envelope", *sealed)
+	}
+
+	// Data key 1 was wrapped by the previous master key and is not cached yet
+	k.activeKey, k.dataKeys[1] = 1, bytes.Repeat([]byte{3}, 32)
+	older, err := k.Encrypt(ctx, "body_raw", &value)
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	delete(k.dataKeys, 1)
+
+	tests := []struct {
+		name    string
+		column  string
+		value   string
+		wantErr string
+	}{
+		{"active data key", "body_raw", *sealed, ""},
+		{"data key wrapped by the previous master key", "body_raw", *older, ""},
+		{"moved to another column", "response_body", *sealed, "failed to decrypt response_body with data key 2"},
+		{"unknown data key", "body_raw", strings.Replace(*sealed, `"key":2`, `"key":7`, 1), "data key 7 not found"},
+		{"unsupported version", "body_raw", strings.Replace(*sealed, `"v1"`, `"v9"`, 1), `unsupported body_raw envelope version "v9"`},
+		{"not an envelope", "body_raw", `{"$enc":`, "invalid body_raw envelope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt(ctx, tt.column, tt.value)
+			if tt.wantErr == "" {
+				if err != nil || got != value {
+					t.Errorf("Decrypt() = %q, %v, want %q", got, err, value)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptSkipsUnencryptedColumns(t *testing.T) {
+	ctx := context.Background()
+	value := `{"board_id":"1"}`
+	tests := []struct {
+		name   string
+		k      *Keyring
+		column string
+		value  *string
+	}{
+		{"nil keyring", nil, "body_raw", &value},
+		{"encryption disabled", &Keyring{Columns: map[string]bool{"body_raw": true}}, "body_raw", &value},
+		{"column not configured", &Keyring{Enabled: true, Columns: map[string]bool{"body_raw": true}}, "query_raw", &value},
+		{"NULL value", &Keyring{Enabled: true, Columns: map[string]bool{"body_raw": true}}, "body_raw", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.k.Encrypt(ctx, tt.column, tt.value)
+			if err != nil || got != tt.value {
+				t.Errorf("Encrypt() = %v, %v, want the value unchanged", got, err)
+			}
+		})
+	}
+}
+
+func TestReveal(t *testing.T) {
+	k := testKeyring(t)
+	k.activeKey, k.dataKeys[1], k.activeLoaded = 1, bytes.Repeat([]byte{3}, 32), time.Now()
+	value := `{"question":"q"}`
+	sealed, err := k.Encrypt(context.Background(), "body_raw", &value)
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		value  *string
+		want   *string
+		masked bool
+	}{
+		{"reader sees the plaintext", WithReader(context.Background()), sealed, &value, false},
+		{"others see the mask", context.Background(), sealed, nil, true},
+		{"plain values are returned as is", context.Background(), &value, &value, false},
+		{"NULL stays NULL", WithReader(context.Background()), nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Reveal(tt.ctx, "body_raw", tt.value)
+			if err != nil {
+				t.Fatalf("Reveal() error: %v", err)
+			}
+			switch {
+			case tt.masked:
+				if got == nil || *got != masked {
+					t.Errorf("Reveal() = %v, want %s", got, masked)
+				}
+			case tt.want == nil:
+				if got != nil {
+					t.Errorf("Reveal() = %s, want NULL", *got)
+				}
+			case got == nil || *got != *tt.want:
+				t.Errorf("Reveal() = %v, want %s", got, *tt.want)
+			}
+		})
+	}
+}
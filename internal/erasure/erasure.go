@@ -0,0 +1,658 @@
+package erasure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog/chain"
+	"github.com/motiso/sparksai-audit-service/internal/legalhold"
+	"github.com/spf13/viper"
+)
+
+// Erasure modes
+const (
+	ModeDelete       = "delete"       // Delete the rows, leaving chain tombstones
+	ModePseudonymize = "pseudonymize" // Replace user_id with a keyed hash and null the personal data
+)
+
+// Job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// pollInterval is how often the worker looks for jobs it was not woken for
+// (submitted to another instance, or left behind by an instance that stopped)
+const pollInterval = 30 * time.Second
+
+// staleAfter is how long a running job may go without progress before another
+// instance takes it over
+const staleAfter = 5 * time.Minute
+
+// ErasedColumns are the personal data columns nulled by pseudonymization
+var ErasedColumns = []string{
+	"ip_address", "user_agent", "query_raw", "body_raw", "response_body",
+	"before_snapshot", "after_snapshot", "change_diff",
+}
+
+// ReferenceColumns name a user without the row being theirs: the impersonator, and the
+// resource of rows about the user (resource_type 'user'), such as subject export entries.
+// Both modes replace them with the pseudonym and leave the rest of the row alone
+var ReferenceColumns = []string{"impersonated_by", "resource_id"}
+
+// subjectRows matches the rows that name the user ($1) as actor or in a reference column
+const subjectRows = `(user_id = $1 OR impersonated_by = $1 OR (resource_type = 'user' AND resource_id = $1))`
+
+// pseudonymizeReferences replaces the user ($1) with the pseudonym ($3) in the reference columns
+const pseudonymizeReferences = `impersonated_by = CASE WHEN impersonated_by = $1 THEN $3 ELSE impersonated_by END,
+	resource_id = CASE WHEN resource_type = 'user' AND resource_id = $1 THEN $3 ELSE resource_id END`
+
+// ErrNoKey is returned when AUDIT_ERASURE_KEY is not set
+var ErrNoKey = errors.New("AUDIT_ERASURE_KEY is not set")
+
+// Manager runs erasure jobs against audit_logs
+type Manager struct {
+	DB         *sql.DB                    // Maintenance pool, exempt from the append-only triggers
+	Datastore  auditlog.AuditLogDatastore // Used to write the erasure summary entry
+	Key        []byte                     // HMAC key for subject hashes and pseudonyms
+	BatchSize  int
+	BatchPause time.Duration
+	wake       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// Config controls erasure jobs
+type Config struct {
+	Key        string
+	BatchSize  int
+	BatchPause time.Duration
+}
+
+// Request asks for the erasure of one user's audit logs
+type Request struct {
+	UserID      string `json:"user_id"`
+	Mode        string `json:"mode"`
+	RequestedBy string `json:"requested_by,omitempty"`
+	Reason      string `json:"reason,omitempty"` // e.g. the ticket of the data subject request
+}
+
+// Job is an erasure request and its progress; the user_id is not exposed
+type Job struct {
+	ID            int64   `json:"id"`
+	SubjectHash   string  `json:"subject_hash"`
+	Mode          string  `json:"mode"`
+	Status        string  `json:"status"`
+	RequestedBy   *string `json:"requested_by,omitempty"`
+	Reason        *string `json:"reason,omitempty"`
+	TotalRows     int64   `json:"total_rows"` // Rows to erase when the job was submitted, excluding held rows
+	ProcessedRows int64   `json:"processed_rows"`
+	Progress      float64 `json:"progress"` // Percent of TotalRows processed
+	Error         *string `json:"error,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+	StartedAt     *string `json:"started_at,omitempty"`
+	CompletedAt   *string `json:"completed_at,omitempty"`
+}
+
+// Record is the compliance record kept for every completed erasure
+type Record struct {
+	JobID        int64    `json:"job_id"`
+	SubjectHash  string   `json:"subject_hash"`
+	Mode         string   `json:"mode"`
+	Pseudonym    string   `json:"pseudonym,omitempty"`     // Written to pseudonymized rows and reference columns
+	ErasedFields []string `json:"erased_fields,omitempty"` // Fields rewritten in rows kept; deleted rows are gone
+	RequestedBy  *string  `json:"requested_by,omitempty"`
+	Reason       *string  `json:"reason,omitempty"`
+	AffectedRows int64    `json:"affected_rows"`
+	HeldRows     int64    `json:"held_rows"` // Rows naming the user left in place under legal hold
+	FirstID      *int64   `json:"first_id,omitempty"`
+	LastID       *int64   `json:"last_id,omitempty"`
+	OldestRow    *string  `json:"oldest_row,omitempty"`
+	NewestRow    *string  `json:"newest_row,omitempty"`
+	RequestedAt  string   `json:"requested_at"`
+	StartedAt    string   `json:"started_at"`
+	CompletedAt  string   `json:"completed_at"`
+}
+
+// StoredRecord is a compliance record as kept in audit_erasure_records
+type StoredRecord struct {
+	Record       json.RawMessage `json:"record"`
+	RecordSHA256 string          `json:"record_sha256"` // SHA-256 of the canonical JSON of record
+	CreatedAt    string          `json:"created_at"`
+}
+
+// LoadConfig reads the erasure settings from viper
+func LoadConfig() Config {
+	batchSize := viper.GetInt("AUDIT_ERASURE_BATCH_SIZE")
+	if batchSize <= 0 {
+		batchSize = 1000 // default
+	}
+
+	batchPauseMs := viper.GetInt("AUDIT_ERASURE_BATCH_PAUSE_MS")
+	if batchPauseMs < 0 {
+		batchPauseMs = 0
+	}
+
+	return Config{
+		Key:        viper.GetString("AUDIT_ERASURE_KEY"),
+		BatchSize:  batchSize,
+		BatchPause: time.Duration(batchPauseMs) * time.Millisecond,
+	}
+}
+
+// NewManager creates the erasure manager; db should be the maintenance pool
+func NewManager(db *sql.DB, datastore auditlog.AuditLogDatastore, cfg Config) *Manager {
+	var key []byte
+	if cfg.Key != "" {
+		key = []byte(cfg.Key)
+	}
+	return &Manager{
+		DB:         db,
+		Datastore:  datastore,
+		Key:        key,
+		BatchSize:  cfg.BatchSize,
+		BatchPause: cfg.BatchPause,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// SubjectHash is the keyed hash that identifies a user in jobs and records
+func (m *Manager) SubjectHash(userID string) string {
+	mac := hmac.New(sha256.New, m.Key)
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Pseudonym is the user_id written to pseudonymized rows; the same user always gets
+// the same pseudonym, so reports keep counting them as one user
+func (m *Manager) Pseudonym(userID string) string {
+	return "pseudonym-" + m.SubjectHash(userID)[:32]
+}
+
+// Submit records an erasure job and wakes the worker
+func (m *Manager) Submit(ctx context.Context, req Request) (*Job, error) {
+	if m.Key == nil {
+		return nil, ErrNoKey
+	}
+
+	var totalRows int64
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM audit_logs WHERE `+subjectRows+` AND NOT `+legalhold.Held("audit_logs"), req.UserID).Scan(&totalRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rows to erase: %w", err)
+	}
+
+	row := m.DB.QueryRowContext(ctx, `
+		INSERT INTO audit_erasure_jobs (user_id, subject_hash, mode, requested_by, reason, total_rows)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+		RETURNING `+jobColumns,
+		req.UserID, m.SubjectHash(req.UserID), req.Mode, req.RequestedBy, req.Reason, totalRows)
+	job, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create erasure job: %w", err)
+	}
+	log.Printf("[ERASURE] Job %d submitted: %s %d row(s) of subject %s", job.ID, job.Mode, totalRows, job.SubjectHash)
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// GetJob returns a job and, once it completed, its compliance record
+func (m *Manager) GetJob(ctx context.Context, id int64) (*Job, *StoredRecord, error) {
+	job, err := scanJob(m.DB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM audit_erasure_jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read erasure job: %w", err)
+	}
+
+	var stored StoredRecord
+	var record string
+	var createdAt time.Time
+	err = m.DB.QueryRowContext(ctx, `
+		SELECT record::text, record_sha256, created_at FROM audit_erasure_records WHERE job_id = $1`, id).Scan(&record, &stored.RecordSHA256, &createdAt)
+	if err == sql.ErrNoRows {
+		return job, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read erasure record: %w", err)
+	}
+	stored.Record = json.RawMessage(record)
+	stored.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return job, &stored, nil
+}
+
+// ListJobs returns the newest jobs, optionally only those of one user
+func (m *Manager) ListJobs(ctx context.Context, userID string, limit int) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM audit_erasure_jobs`
+	args := []interface{}{limit}
+	if userID != "" {
+		query += ` WHERE subject_hash = $2`
+		args = append(args, m.SubjectHash(userID))
+	}
+	query += ` ORDER BY id DESC LIMIT $1`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query erasure jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan erasure job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating erasure jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Start runs the worker that processes submitted jobs
+func (m *Manager) Start() {
+	if m.Key == nil {
+		log.Printf("[ERASURE] %v - erasure requests are rejected", ErrNoKey)
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			m.runPending()
+			select {
+			case <-ticker.C:
+			case <-m.wake:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the worker; a job in progress goes back to pending after its current batch
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+// stopping reports whether Stop was called
+func (m *Manager) stopping() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// runPending runs claimable jobs one after another until none is left
+func (m *Manager) runPending() {
+	for !m.stopping() {
+		job, userID, err := m.claim()
+		if err != nil {
+			log.Printf("[ERASURE ERROR] Failed to claim a job: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		m.run(job, userID)
+	}
+}
+
+// claim marks the oldest pending (or stale running) job as running
+func (m *Manager) claim() (*claimedJob, string, error) {
+	var job claimedJob
+	var userID sql.NullString
+	err := m.DB.QueryRow(`
+		UPDATE audit_erasure_jobs
+		SET status = $1, started_at = COALESCE(started_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM audit_erasure_jobs
+			WHERE status = $2 OR (status = $1 AND updated_at < $3)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, subject_hash, mode, requested_by, reason, created_at, started_at`,
+		StatusRunning, StatusPending, time.Now().Add(-staleAfter),
+	).Scan(&job.ID, &userID, &job.SubjectHash, &job.Mode, &job.RequestedBy, &job.Reason, &job.CreatedAt, &job.StartedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &job, userID.String, nil
+}
+
+// claimedJob is what the worker needs of a running job
+type claimedJob struct {
+	ID          int64
+	SubjectHash string
+	Mode        string
+	RequestedBy sql.NullString
+	Reason      sql.NullString
+	CreatedAt   time.Time
+	StartedAt   time.Time
+}
+
+// run erases the job's rows in batches, then writes the compliance record
+func (m *Manager) run(job *claimedJob, userID string) {
+	log.Printf("[ERASURE] Job %d started (%s)", job.ID, job.Mode)
+	for {
+		if m.stopping() {
+			// Let another instance (or the next start) resume it
+			if _, err := m.DB.Exec(`UPDATE audit_erasure_jobs SET status = $1 WHERE id = $2`, StatusPending, job.ID); err != nil {
+				log.Printf("[ERASURE ERROR] Failed to release job %d: %v", job.ID, err)
+			}
+			return
+		}
+
+		erased, err := m.eraseBatch(job, userID)
+		if err != nil {
+			log.Printf("[ERASURE ERROR] Job %d failed: %v", job.ID, err)
+			m.fail(job.ID, err)
+			return
+		}
+		if erased < int64(m.BatchSize) {
+			break
+		}
+		if m.BatchPause > 0 {
+			time.Sleep(m.BatchPause)
+		}
+	}
+
+	record, err := m.complete(job, userID)
+	if err != nil {
+		log.Printf("[ERASURE ERROR] Job %d failed: %v", job.ID, err)
+		m.fail(job.ID, err)
+		return
+	}
+	log.Printf("[ERASURE] Job %d completed: %s applied to %d row(s)", job.ID, job.Mode, record.AffectedRows)
+	m.recordErasure(job, record)
+}
+
+// eraseBatch deletes or pseudonymizes up to BatchSize rows of the user, pseudonymizes the
+// reference columns of the other rows naming the user, and records the progress in the
+// same transaction
+func (m *Manager) eraseBatch(job *claimedJob, userID string) (int64, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Rows under legal hold are left in place. The batch is locked, so every selected row
+	// must be affected; fewer means something suppressed the change
+	// Rows of the user (owned) are deleted or pseudonymized; rows that only name the user
+	// keep everything but the reference columns
+	query := `
+		WITH batch AS (
+			SELECT id, created_at, user_id = $1 AS owned FROM audit_logs
+			WHERE ` + subjectRows + ` AND NOT ` + legalhold.Held("audit_logs") + `
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE
+		),
+		referenced AS (
+			UPDATE audit_logs
+			SET ` + pseudonymizeReferences + `, redacted_at = CURRENT_TIMESTAMP
+			WHERE (id, created_at) IN (SELECT id, created_at FROM batch WHERE NOT owned)
+			RETURNING id, created_at
+		),`
+	args := []interface{}{userID, m.BatchSize, m.Pseudonym(userID)}
+	if job.Mode == ModeDelete {
+		query += `
+			own_rows AS (
+				DELETE FROM audit_logs
+				WHERE (id, created_at) IN (SELECT id, created_at FROM batch WHERE owned)
+				RETURNING id, created_at, prev_hash, content_hash, row_hash
+			),
+			tombstones AS (
+				INSERT INTO audit_chain_tombstones (id, created_at, prev_hash, content_hash, row_hash, reason)
+				SELECT id, created_at, prev_hash, content_hash, row_hash, 'erasure'
+				FROM own_rows
+				WHERE row_hash IS NOT NULL
+				ON CONFLICT (id) DO NOTHING
+			),`
+	} else {
+		query += `
+			own_rows AS (
+				UPDATE audit_logs
+				SET user_id = $3, ip_address = NULL, user_agent = NULL,
+					query_raw = NULL, body_raw = NULL, response_body = NULL,
+					before_snapshot = NULL, after_snapshot = NULL, change_diff = NULL,
+					` + pseudonymizeReferences + `, redacted_at = CURRENT_TIMESTAMP
+				WHERE (id, created_at) IN (SELECT id, created_at FROM batch WHERE owned)
+				RETURNING id, created_at
+			),`
+	}
+	query += `
+		affected AS (
+			SELECT id, created_at FROM own_rows
+			UNION ALL
+			SELECT id, created_at FROM referenced
+		)
+		SELECT (SELECT COUNT(*) FROM batch), COUNT(*), MIN(id), MAX(id), MIN(created_at), MAX(created_at) FROM affected`
+
+	var selected, erased int64
+	var firstID, lastID sql.NullInt64
+	var oldest, newest sql.NullTime
+	if err := tx.QueryRow(query, args...).Scan(&selected, &erased, &firstID, &lastID, &oldest, &newest); err != nil {
+		return 0, fmt.Errorf("failed to erase batch: %w", err)
+	}
+	if erased != selected {
+		return 0, fmt.Errorf("erased %d of %d selected row(s)", erased, selected)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE audit_erasure_jobs
+		SET processed_rows = processed_rows + $1,
+			first_id = LEAST(first_id, $2), last_id = GREATEST(last_id, $3),
+			oldest_row = LEAST(oldest_row, $4), newest_row = GREATEST(newest_row, $5),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6`, erased, firstID, lastID, oldest, newest, job.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record progress: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit erasure batch: %w", err)
+	}
+	return erased, nil
+}
+
+// complete writes the compliance record and closes the job, forgetting the user_id
+// It fails when rows of the user outside a legal hold are left, so a job is never
+// reported complete for a partial erasure
+func (m *Manager) complete(job *claimedJob, userID string) (*Record, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	record := &Record{
+		JobID:       job.ID,
+		SubjectHash: job.SubjectHash,
+		Mode:        job.Mode,
+		RequestedBy: nullStringToPtr(job.RequestedBy),
+		Reason:      nullStringToPtr(job.Reason),
+		RequestedAt: job.CreatedAt.UTC().Format(time.RFC3339),
+		StartedAt:   job.StartedAt.UTC().Format(time.RFC3339),
+	}
+	record.Pseudonym = m.Pseudonym(userID)
+	if job.Mode == ModePseudonymize {
+		record.ErasedFields = append([]string{"user_id"}, ErasedColumns...)
+	}
+	record.ErasedFields = append(record.ErasedFields, ReferenceColumns...)
+
+	// Every row naming the user outside a legal hold must be gone (or pseudonymized) by now
+	var remaining int64
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM audit_logs WHERE `+subjectRows+` AND NOT `+legalhold.Held("audit_logs"), userID).Scan(&remaining)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rows left to erase: %w", err)
+	}
+	if remaining > 0 {
+		return nil, fmt.Errorf("%d row(s) of the subject were not erased", remaining)
+	}
+
+	var firstID, lastID sql.NullInt64
+	var oldest, newest sql.NullTime
+	var completedAt time.Time
+	err = tx.QueryRow(`
+		UPDATE audit_erasure_jobs
+		SET status = $1, user_id = NULL, completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING processed_rows, first_id, last_id, oldest_row, newest_row, completed_at`,
+		StatusCompleted, job.ID).Scan(&record.AffectedRows, &firstID, &lastID, &oldest, &newest, &completedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete job: %w", err)
+	}
+	if firstID.Valid {
+		record.FirstID = &firstID.Int64
+		record.LastID = &lastID.Int64
+		record.OldestRow = formatTime(oldest)
+		record.NewestRow = formatTime(newest)
+	}
+	record.CompletedAt = completedAt.UTC().Format(time.RFC3339)
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM audit_logs WHERE `+subjectRows+` AND `+legalhold.Held("audit_logs"), userID).Scan(&record.HeldRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count held rows: %w", err)
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	canonical := chain.CanonicalJSON(string(encoded))
+	digest := sha256.Sum256([]byte(canonical))
+	_, err = tx.Exec(`
+		INSERT INTO audit_erasure_records (job_id, subject_hash, record, record_sha256)
+		VALUES ($1, $2, $3, $4)`, job.ID, job.SubjectHash, canonical, hex.EncodeToString(digest[:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store erasure record: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure record: %w", err)
+	}
+	return record, nil
+}
+
+// fail marks a job as failed and forgets its user_id; the rows erased so far stay erased
+func (m *Manager) fail(id int64, cause error) {
+	_, err := m.DB.Exec(`
+		UPDATE audit_erasure_jobs
+		SET status = $1, user_id = NULL, error = $2, completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`, StatusFailed, cause.Error(), id)
+	if err != nil {
+		log.Printf("[ERASURE ERROR] Failed to mark job %d as failed: %v", id, err)
+	}
+}
+
+// recordErasure writes the erasure summary to audit_logs so erasures are themselves audited
+func (m *Manager) recordErasure(job *claimedJob, record *Record) {
+	action := "erasure"
+	actorType := auditlog.ActorTypeSystem
+	count := int(record.AffectedRows)
+	summary, _ := json.Marshal(record)
+	body := string(summary)
+
+	entry := auditlog.AuditLog{
+		Severity:            "NONE",
+		EndpointPath:        "/internal/erasure",
+		Action:              &action,
+		Count:               &count,
+		HTTPMethod:          "DELETE",
+		StatusCode:          200,
+		ResponseTimeSeconds: time.Since(job.StartedAt).Seconds(),
+		ActorType:           &actorType,
+		Outcome:             auditlog.OutcomeSuccess,
+		BodyRaw:             &body,
+	}
+	if err := m.Datastore.BatchInsertAuditLogs(context.Background(), []auditlog.AuditLog{entry}); err != nil {
+		log.Printf("[ERASURE ERROR] Failed to record erasure summary: %v", err)
+	}
+}
+
+// jobColumns is the column list read by scanJob
+const jobColumns = `id, subject_hash, mode, status, requested_by, reason, total_rows, processed_rows,
+	error, created_at, started_at, completed_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var requestedBy, reason, jobErr sql.NullString
+	var createdAt time.Time
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.SubjectHash, &job.Mode, &job.Status, &requestedBy, &reason,
+		&job.TotalRows, &job.ProcessedRows, &jobErr, &createdAt, &startedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.RequestedBy = nullStringToPtr(requestedBy)
+	job.Reason = nullStringToPtr(reason)
+	job.Error = nullStringToPtr(jobErr)
+	job.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	job.StartedAt = formatTime(startedAt)
+	job.CompletedAt = formatTime(completedAt)
+
+	switch {
+	case job.Status == StatusCompleted:
+		job.Progress = 100
+	case job.TotalRows > 0:
+		job.Progress = min(100, float64(job.ProcessedRows)/float64(job.TotalRows)*100)
+	}
+	return &job, nil
+}
+
+func nullStringToPtr(ns sql.NullString) *string {
+	if ns.Valid {
+		return &ns.String
+	}
+	return nil
+}
+
+func formatTime(nt sql.NullTime) *string {
+	if nt.Valid {
+		formatted := nt.Time.UTC().Format(time.RFC3339)
+		return &formatted
+	}
+	return nil
+}
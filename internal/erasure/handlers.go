@@ -0,0 +1,103 @@
+package erasure
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// SubmitHandler handles POST /api/admin/erasures
+// Body: {"user_id": "...", "mode": "delete" | "pseudonymize", "requested_by": "...", "reason": "..."}
+// The job runs in the background; poll GET /api/admin/erasures/{id} for its progress
+func (m *Manager) SubmitHandler(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID == "" {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Mode != ModeDelete && req.Mode != ModePseudonymize {
+		http.Error(w, "mode must be delete or pseudonymize", http.StatusBadRequest)
+		return
+	}
+
+	job, err := m.Submit(r.Context(), req)
+	if errors.Is(err, ErrNoKey) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("error occurred during erasure Submit: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetJobHandler handles GET /api/admin/erasures/{id}
+// Returns the job with its progress and, once completed, its compliance record
+func (m *Manager) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	job, record, err := m.GetJob(r.Context(), id)
+	if err != nil {
+		log.Printf("error occurred during erasure GetJob: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "Erasure job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":    job,
+		"record": record,
+	})
+}
+
+// ListJobsHandler handles GET /api/admin/erasures
+// Query parameters: user_id (optional, matched through its subject hash), limit (optional, default: 100, max: 500)
+func (m *Manager) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, 500)
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID != "" && m.Key == nil {
+		http.Error(w, ErrNoKey.Error(), http.StatusConflict)
+		return
+	}
+
+	jobs, err := m.ListJobs(r.Context(), userID, limit)
+	if err != nil {
+		log.Printf("error occurred during erasure ListJobs: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
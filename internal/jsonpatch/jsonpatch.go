@@ -0,0 +1,217 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation
+// Only add, remove and replace are produced by Diff
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON omits the value member for remove operations, which RFC 6902 does not define
+func (op Operation) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	type operation Operation
+	return json.Marshal(operation(op))
+}
+
+// Change is a human readable, field-level view of an operation
+type Change struct {
+	Path     string      `json:"path"`
+	Field    string      `json:"field"`
+	Type     string      `json:"type"` // added, removed or changed
+	OldValue interface{} `json:"old_value,omitempty"`
+	NewValue interface{} `json:"new_value,omitempty"`
+}
+
+// DiffJSON computes the JSON Patch that turns before into after
+// A missing (empty) snapshot is treated as an empty object, so a DELETE with only a
+// before snapshot yields one remove per field and a create with only after yields adds
+func DiffJSON(before, after []byte) ([]Operation, error) {
+	beforeDoc, err := decode(before)
+	if err != nil {
+		return nil, err
+	}
+	afterDoc, err := decode(after)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(beforeDoc, afterDoc), nil
+}
+
+// Diff computes the JSON Patch that turns before into after
+// Both values must be the generic form produced by encoding/json (maps, slices, scalars)
+func Diff(before, after interface{}) []Operation {
+	ops := []Operation{}
+	return diffValue("", before, after, ops)
+}
+
+// Changes renders patch operations as field-level changes, resolving old values from before
+func Changes(before []byte, ops []Operation) ([]Change, error) {
+	beforeDoc, err := decode(before)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0, len(ops))
+	for _, op := range ops {
+		change := Change{
+			Path:  op.Path,
+			Field: fieldName(op.Path),
+		}
+		switch op.Op {
+		case "add":
+			change.Type = "added"
+			change.NewValue = op.Value
+		case "remove":
+			change.Type = "removed"
+			change.OldValue, _ = Get(beforeDoc, op.Path)
+		default:
+			change.Type = "changed"
+			change.OldValue, _ = Get(beforeDoc, op.Path)
+			change.NewValue = op.Value
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Get resolves an RFC 6901 JSON Pointer against a decoded document
+func Get(doc interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	current := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = unescape(token)
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func decode(data []byte) (interface{}, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return map[string]interface{}{}, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func diffValue(path string, before, after interface{}, ops []Operation) []Operation {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if beforeIsMap && afterIsMap {
+		return diffObject(path, beforeMap, afterMap, ops)
+	}
+
+	beforeSlice, beforeIsSlice := before.([]interface{})
+	afterSlice, afterIsSlice := after.([]interface{})
+	if beforeIsSlice && afterIsSlice {
+		return diffArray(path, beforeSlice, afterSlice, ops)
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		ops = append(ops, Operation{Op: "replace", Path: path, Value: after})
+	}
+	return ops
+}
+
+func diffObject(path string, before, after map[string]interface{}, ops []Operation) []Operation {
+	// Walk keys in sorted order so the stored patch is deterministic
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "/" + escape(key)
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+		switch {
+		case inBefore && !inAfter:
+			ops = append(ops, Operation{Op: "remove", Path: childPath})
+		case !inBefore && inAfter:
+			ops = append(ops, Operation{Op: "add", Path: childPath, Value: afterValue})
+		default:
+			ops = diffValue(childPath, beforeValue, afterValue, ops)
+		}
+	}
+	return ops
+}
+
+func diffArray(path string, before, after []interface{}, ops []Operation) []Operation {
+	common := len(before)
+	if len(after) < common {
+		common = len(after)
+	}
+	for i := 0; i < common; i++ {
+		ops = diffValue(path+"/"+strconv.Itoa(i), before[i], after[i], ops)
+	}
+	// Remove from the end so earlier indexes stay valid while the patch is applied
+	for i := len(before) - 1; i >= common; i-- {
+		ops = append(ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	for i := common; i < len(after); i++ {
+		ops = append(ops, Operation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: after[i]})
+	}
+	return ops
+}
+
+// escape encodes a key as an RFC 6901 reference token
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func unescape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// fieldName turns a JSON Pointer into a dotted field name for display
+// Example: /assignee/name -> assignee.name
+func fieldName(pointer string) string {
+	if pointer == "" {
+		return "(root)"
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i := range tokens {
+		tokens[i] = unescape(tokens[i])
+	}
+	return strings.Join(tokens, ".")
+}
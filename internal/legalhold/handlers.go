@@ -0,0 +1,145 @@
+package legalhold
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateHandler handles POST /api/admin/legal-holds
+// Body: {"user_id", "session_id", "tenant_id", "action", "from", "until" (RFC 3339), "reason", "owner"}
+// At least one criterion is required; rows matching all of them are held
+func (m *Manager) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Owner = strings.TrimSpace(req.Owner)
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hold, err := m.Create(r.Context(), req)
+	if err != nil {
+		log.Printf("error occurred during legal hold Create: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+// ReleaseHandler handles POST /api/admin/legal-holds/{id}/release
+// Body: {"released_by": "...", "reason": "..."}
+func (m *Manager) ReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	var release Release
+	if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	release.ReleasedBy = strings.TrimSpace(release.ReleasedBy)
+	release.Reason = strings.TrimSpace(release.Reason)
+	if release.ReleasedBy == "" || release.Reason == "" {
+		http.Error(w, "released_by and reason are required", http.StatusBadRequest)
+		return
+	}
+
+	hold, err := m.Release(r.Context(), id, release)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Legal hold not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrAlreadyReleased) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("error occurred during legal hold Release: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+// GetHandler handles GET /api/admin/legal-holds/{id}
+// Returns the hold, the number of rows it matches and its history
+func (m *Manager) GetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	hold, events, err := m.Get(r.Context(), id)
+	if err != nil {
+		log.Printf("error occurred during legal hold Get: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if hold == nil {
+		http.Error(w, "Legal hold not found", http.StatusNotFound)
+		return
+	}
+	matchedRows, err := m.MatchedRows(r.Context(), hold)
+	if err != nil {
+		log.Printf("error occurred during legal hold MatchedRows: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hold":         hold,
+		"matched_rows": matchedRows,
+		"history":      events,
+	})
+}
+
+// ListHandler handles GET /api/admin/legal-holds
+// Query parameters: status (optional, active, released or all, default: active), limit (optional, default: 100, max: 500)
+func (m *Manager) ListHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, 500)
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = StatusActive
+	}
+	if status != StatusActive && status != StatusReleased && status != StatusAll {
+		http.Error(w, "status must be active, released or all", http.StatusBadRequest)
+		return
+	}
+
+	holds, err := m.List(r.Context(), status, limit)
+	if err != nil {
+		log.Printf("error occurred during legal hold List: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
@@ -0,0 +1,385 @@
+package legalhold
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+)
+
+// Hold history events
+const (
+	EventCreated  = "created"
+	EventReleased = "released"
+)
+
+// Hold statuses accepted by List
+const (
+	StatusActive   = "active"
+	StatusReleased = "released"
+	StatusAll      = "all"
+)
+
+var (
+	ErrNotFound        = errors.New("legal hold not found")
+	ErrAlreadyReleased = errors.New("legal hold is already released")
+)
+
+// Manager creates, lists and releases legal holds
+type Manager struct {
+	DB        *sql.DB
+	Datastore auditlog.AuditLogDatastore // Used to write the hold entries
+}
+
+func NewManager(db *sql.DB, datastore auditlog.AuditLogDatastore) *Manager {
+	return &Manager{DB: db, Datastore: datastore}
+}
+
+// Request asks for a new hold; rows matching every criterion that is set are held
+type Request struct {
+	UserID    *string    `json:"user_id,omitempty"`
+	SessionID *string    `json:"session_id,omitempty"`
+	TenantID  *string    `json:"tenant_id,omitempty"`
+	Action    *string    `json:"action,omitempty"`
+	From      *time.Time `json:"from,omitempty"`  // created_at >= from
+	Until     *time.Time `json:"until,omitempty"` // created_at < until
+	Reason    string     `json:"reason"`
+	Owner     string     `json:"owner"`
+}
+
+// Release ends a hold
+type Release struct {
+	ReleasedBy string `json:"released_by"`
+	Reason     string `json:"reason"`
+}
+
+// Hold is a legal hold and its criteria
+type Hold struct {
+	ID            int64   `json:"id"`
+	UserID        *string `json:"user_id,omitempty"`
+	SessionID     *string `json:"session_id,omitempty"`
+	TenantID      *string `json:"tenant_id,omitempty"`
+	Action        *string `json:"action,omitempty"`
+	From          *string `json:"from,omitempty"`
+	Until         *string `json:"until,omitempty"`
+	Reason        string  `json:"reason"`
+	Owner         string  `json:"owner"`
+	Active        bool    `json:"active"`
+	CreatedAt     string  `json:"created_at"`
+	ReleasedAt    *string `json:"released_at,omitempty"`
+	ReleasedBy    *string `json:"released_by,omitempty"`
+	ReleaseReason *string `json:"release_reason,omitempty"`
+}
+
+// Event is one entry of a hold's history, with the hold as it was afterwards
+type Event struct {
+	ID        int64           `json:"id"`
+	HoldID    int64           `json:"hold_id"`
+	Event     string          `json:"event"`
+	Actor     string          `json:"actor"`
+	Reason    *string         `json:"reason,omitempty"`
+	Hold      json.RawMessage `json:"hold"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// Held is a boolean SQL expression that is true for the audit_logs row referenced by
+// table (a table name or alias) while an active legal hold covers it
+// Retention, erasure and partition removal leave rows for which it is true in place
+func Held(table string) string {
+	return `EXISTS (
+		SELECT 1 FROM audit_legal_holds h
+		WHERE h.released_at IS NULL AND ` + covers(table) + `
+	)`
+}
+
+// Covering is a query counting the active holds that cover at least one row of table
+// (a quoted table of audit_logs rows, such as a partition). It starts from the holds, so
+// its cost follows the number of holds rather than the number of rows
+func Covering(table string) string {
+	return `SELECT COUNT(*) FROM audit_legal_holds h
+		WHERE h.released_at IS NULL AND EXISTS (
+			SELECT 1 FROM ` + table + ` a WHERE ` + covers("a") + `
+		)`
+}
+
+// covers is true when the hold aliased h covers the row referenced by table
+// NULL criteria match every row
+func covers(table string) string {
+	return `(h.user_id IS NULL OR h.user_id = ` + table + `.user_id)
+			AND (h.session_id IS NULL OR h.session_id = ` + table + `.session_id)
+			AND (h.tenant_id IS NULL OR h.tenant_id = ` + table + `.tenant_id)
+			AND (h.action IS NULL OR h.action = ` + table + `.action)
+			AND (h.held_from IS NULL OR ` + table + `.created_at >= h.held_from)
+			AND (h.held_until IS NULL OR ` + table + `.created_at < h.held_until)`
+}
+
+// Validate checks that a request names at least one criterion, a reason and an owner
+func (r Request) Validate() error {
+	if r.UserID == nil && r.SessionID == nil && r.TenantID == nil && r.Action == nil && r.From == nil && r.Until == nil {
+		return errors.New("at least one of user_id, session_id, tenant_id, action, from or until is required")
+	}
+	for name, value := range map[string]*string{"user_id": r.UserID, "session_id": r.SessionID, "tenant_id": r.TenantID, "action": r.Action} {
+		if value != nil && *value == "" {
+			return fmt.Errorf("%s must not be empty", name)
+		}
+	}
+	if r.From != nil && r.Until != nil && !r.From.Before(*r.Until) {
+		return errors.New("from must be before until")
+	}
+	if r.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if r.Owner == "" {
+		return errors.New("owner is required")
+	}
+	return nil
+}
+
+// holdColumns is the column list read by scanHold
+const holdColumns = `id, user_id, session_id, tenant_id, action, held_from, held_until, reason, owner,
+	created_at, released_at, released_by, release_reason`
+
+// Create stores a hold and its "created" event in one transaction
+func (m *Manager) Create(ctx context.Context, req Request) (*Hold, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hold, err := scanHold(tx.QueryRowContext(ctx, `
+		INSERT INTO audit_legal_holds (user_id, session_id, tenant_id, action, held_from, held_until, reason, owner)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+holdColumns,
+		req.UserID, req.SessionID, req.TenantID, req.Action, req.From, req.Until, req.Reason, req.Owner))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create legal hold: %w", err)
+	}
+	if err := addEvent(ctx, tx, hold, EventCreated, req.Owner, req.Reason); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit legal hold: %w", err)
+	}
+
+	log.Printf("[LEGAL HOLD] Hold %d created by %s", hold.ID, hold.Owner)
+	m.recordHold(hold, EventCreated, req.Owner)
+	return hold, nil
+}
+
+// Release ends an active hold; the rows it covered become subject to retention and
+// erasure again unless another hold covers them
+func (m *Manager) Release(ctx context.Context, id int64, release Release) (*Hold, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hold, err := scanHold(tx.QueryRowContext(ctx, `
+		UPDATE audit_legal_holds
+		SET released_at = CURRENT_TIMESTAMP, released_by = $2, release_reason = $3
+		WHERE id = $1 AND released_at IS NULL
+		RETURNING `+holdColumns, id, release.ReleasedBy, release.Reason))
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM audit_legal_holds WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to read legal hold: %w", err)
+		}
+		if exists {
+			return nil, ErrAlreadyReleased
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to release legal hold: %w", err)
+	}
+	if err := addEvent(ctx, tx, hold, EventReleased, release.ReleasedBy, release.Reason); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit legal hold release: %w", err)
+	}
+
+	log.Printf("[LEGAL HOLD] Hold %d released by %s", hold.ID, release.ReleasedBy)
+	m.recordHold(hold, EventReleased, release.ReleasedBy)
+	return hold, nil
+}
+
+// Get returns a hold with its history, oldest event first
+func (m *Manager) Get(ctx context.Context, id int64) (*Hold, []Event, error) {
+	hold, err := scanHold(m.DB.QueryRowContext(ctx, `SELECT `+holdColumns+` FROM audit_legal_holds WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read legal hold: %w", err)
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `
+		SELECT id, hold_id, event, actor, reason, hold::text, created_at
+		FROM audit_legal_hold_events
+		WHERE hold_id = $1
+		ORDER BY id ASC`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query legal hold history: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var reason sql.NullString
+		var snapshot string
+		var createdAt time.Time
+		if err := rows.Scan(&event.ID, &event.HoldID, &event.Event, &event.Actor, &reason, &snapshot, &createdAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan legal hold event: %w", err)
+		}
+		if reason.Valid {
+			event.Reason = &reason.String
+		}
+		event.Hold = json.RawMessage(snapshot)
+		event.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating legal hold history: %w", err)
+	}
+	return hold, events, nil
+}
+
+// MatchedRows counts the audit_logs rows matching the criteria of a hold, which are
+// held while it is active
+func (m *Manager) MatchedRows(ctx context.Context, hold *Hold) (int64, error) {
+	var count int64
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM audit_logs a, audit_legal_holds h
+		WHERE h.id = $1 AND `+covers("a"), hold.ID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count held rows: %w", err)
+	}
+	return count, nil
+}
+
+// List returns the newest holds with the given status (active, released or all)
+func (m *Manager) List(ctx context.Context, status string, limit int) ([]Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM audit_legal_holds`
+	switch status {
+	case StatusActive:
+		query += ` WHERE released_at IS NULL`
+	case StatusReleased:
+		query += ` WHERE released_at IS NOT NULL`
+	}
+	query += ` ORDER BY id DESC LIMIT $1`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query legal holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := []Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan legal hold: %w", err)
+		}
+		holds = append(holds, *hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating legal holds: %w", err)
+	}
+	return holds, nil
+}
+
+// addEvent appends an event with a snapshot of the hold to its history
+func addEvent(ctx context.Context, tx *sql.Tx, hold *Hold, event string, actor string, reason string) error {
+	snapshot, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_legal_hold_events (hold_id, event, actor, reason, hold)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)`, hold.ID, event, actor, reason, string(snapshot))
+	if err != nil {
+		return fmt.Errorf("failed to record legal hold history: %w", err)
+	}
+	return nil
+}
+
+// recordHold writes the hold change to audit_logs so holds are themselves audited
+func (m *Manager) recordHold(hold *Hold, event string, actor string) {
+	action := "legal-hold-" + event
+	actorType := auditlog.ActorTypeUser
+	resourceType := "legal_hold"
+	resourceID := fmt.Sprint(hold.ID)
+	snapshot, _ := json.Marshal(hold)
+	body := string(snapshot)
+
+	entry := auditlog.AuditLog{
+		Severity:     "NONE",
+		EndpointPath: "/internal/legal-holds",
+		UserID:       &actor,
+		Action:       &action,
+		HTTPMethod:   "POST",
+		StatusCode:   200,
+		ActorType:    &actorType,
+		ResourceType: &resourceType,
+		ResourceID:   &resourceID,
+		Outcome:      auditlog.OutcomeSuccess,
+		BodyRaw:      &body,
+	}
+	if err := m.Datastore.BatchInsertAuditLogs(context.Background(), []auditlog.AuditLog{entry}); err != nil {
+		log.Printf("[LEGAL HOLD ERROR] Failed to record legal hold %s: %v", event, err)
+	}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHold(row rowScanner) (*Hold, error) {
+	var hold Hold
+	var userID, sessionID, tenantID, action, releasedBy, releaseReason sql.NullString
+	var from, until, releasedAt sql.NullTime
+	var createdAt time.Time
+	err := row.Scan(&hold.ID, &userID, &sessionID, &tenantID, &action, &from, &until, &hold.Reason, &hold.Owner,
+		&createdAt, &releasedAt, &releasedBy, &releaseReason)
+	if err != nil {
+		return nil, err
+	}
+	hold.UserID = nullStringToPtr(userID)
+	hold.SessionID = nullStringToPtr(sessionID)
+	hold.TenantID = nullStringToPtr(tenantID)
+	hold.Action = nullStringToPtr(action)
+	hold.From = formatTime(from)
+	hold.Until = formatTime(until)
+	hold.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	hold.ReleasedAt = formatTime(releasedAt)
+	hold.ReleasedBy = nullStringToPtr(releasedBy)
+	hold.ReleaseReason = nullStringToPtr(releaseReason)
+	hold.Active = !releasedAt.Valid
+	return &hold, nil
+}
+
+func nullStringToPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func formatTime(value sql.NullTime) *string {
+	if !value.Valid {
+		return nil
+	}
+	formatted := value.Time.UTC().Format(time.RFC3339)
+	return &formatted
+}
@@ -0,0 +1,36 @@
+package metaevent
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ListEventsHandler handles GET /api/admin/meta-events
+// Query parameters: event_type (optional), limit (optional, default: 100, max: 500)
+// Returns the newest meta-audit events, e.g. rejected UPDATE/DELETE attempts on audit tables
+func (s *Store) ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsedLimit
+		if limit > 500 {
+			limit = 500
+		}
+	}
+
+	events, err := s.List(r.URL.Query().Get("event_type"), limit)
+	if err != nil {
+		log.Printf("error occurred during ListMetaEvents: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,99 @@
+package metaevent
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Event types recorded in audit_meta_events
+const (
+	EventAppendOnlyViolation = "append_only_violation" // Written by the append-only triggers
+)
+
+// Store reads and writes audit_meta_events, the audit trail of the audit tables themselves
+type Store struct {
+	DB *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Event is one meta-audit event
+type Event struct {
+	ID              int64           `json:"id"`
+	EventType       string          `json:"event_type"`
+	TableName       *string         `json:"table_name,omitempty"`
+	Operation       *string         `json:"operation,omitempty"`
+	RowID           *int64          `json:"row_id,omitempty"`
+	DBUser          string          `json:"db_user"`
+	SessionUser     string          `json:"session_user"`
+	ClientAddr      *string         `json:"client_addr,omitempty"`
+	ApplicationName *string         `json:"application_name,omitempty"`
+	Details         json.RawMessage `json:"details,omitempty"`
+	CreatedAt       string          `json:"created_at"`
+}
+
+// List returns the newest meta-audit events, optionally of one type
+func (s *Store) List(eventType string, limit int) ([]Event, error) {
+	query := `
+		SELECT id, event_type, table_name, operation, row_id, db_user, session_user_name,
+			client_addr, application_name, details::text, created_at
+		FROM audit_meta_events
+		WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+	if eventType != "" {
+		query += " AND event_type = $" + strconv.Itoa(argIndex)
+		args = append(args, eventType)
+		argIndex++
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(argIndex)
+	args = append(args, limit)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meta events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var tableName, operation, clientAddr, applicationName, details sql.NullString
+		var rowID sql.NullInt64
+		var createdAt time.Time
+		err := rows.Scan(&event.ID, &event.EventType, &tableName, &operation, &rowID, &event.DBUser, &event.SessionUser,
+			&clientAddr, &applicationName, &details, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan meta event: %w", err)
+		}
+		if tableName.Valid {
+			event.TableName = &tableName.String
+		}
+		if operation.Valid {
+			event.Operation = &operation.String
+		}
+		if rowID.Valid {
+			event.RowID = &rowID.Int64
+		}
+		if clientAddr.Valid {
+			event.ClientAddr = &clientAddr.String
+		}
+		if applicationName.Valid {
+			event.ApplicationName = &applicationName.String
+		}
+		if details.Valid {
+			event.Details = json.RawMessage(details.String)
+		}
+		event.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating meta events: %w", err)
+	}
+	return events, nil
+}
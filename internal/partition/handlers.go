@@ -0,0 +1,91 @@
+package partition
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ListPartitionsHandler handles GET /api/admin/partitions
+// Returns every audit_logs partition with its range and estimated row count
+func (m *Manager) ListPartitionsHandler(w http.ResponseWriter, r *http.Request) {
+	partitions, err := m.List()
+	if err != nil {
+		log.Printf("error occurred during ListPartitions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"interval":   m.Interval,
+		"premake":    m.Premake,
+		"partitions": partitions,
+	})
+}
+
+// EnsurePartitionsHandler handles POST /api/admin/partitions/ensure
+// Creates any missing future partitions immediately instead of waiting for the background job
+// Periods whose rows already sit in the default partition are listed as blocked
+func (m *Manager) EnsurePartitionsHandler(w http.ResponseWriter, r *http.Request) {
+	created, blocked, err := m.EnsurePartitions()
+	if err != nil {
+		log.Printf("error occurred during EnsurePartitions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if created == nil {
+		created = []string{}
+	}
+	if blocked == nil {
+		blocked = []Blocked{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"created": created,
+		"blocked": blocked,
+	})
+}
+
+// RemovePartitionHandler handles DELETE /api/admin/partitions/{name}
+// Query parameters: mode (optional) - "detach" (default) keeps the data as a standalone table, "drop" deletes it
+func (m *Manager) RemovePartitionHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "detach"
+	}
+
+	var err error
+	switch mode {
+	case "detach":
+		err = m.Detach(name)
+	case "drop":
+		err = m.Drop(name)
+	default:
+		http.Error(w, "mode must be detach or drop", http.StatusBadRequest)
+		return
+	}
+
+	var notRemovable *ErrNotRemovable
+	if errors.As(err, &notRemovable) {
+		http.Error(w, notRemovable.Reason, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("error occurred during RemovePartition: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"partition": name,
+		"mode":      mode,
+	})
+}
@@ -0,0 +1,390 @@
+package partition
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/motiso/sparksai-audit-service/internal/legalhold"
+	"github.com/spf13/viper"
+)
+
+// parentTable is the range-partitioned table managed here
+const parentTable = "audit_logs"
+
+// Supported partition intervals
+const (
+	IntervalMonth = "month"
+	IntervalDay   = "day"
+)
+
+// Manager keeps future audit_logs partitions created ahead of time and
+// detaches/drops old ones on request
+type Manager struct {
+	DB            *sql.DB
+	MaintenanceDB *sql.DB       // Connection of the maintenance role, used to detach and drop; nil disables removal
+	Interval      string        // month or day
+	Premake       int           // Number of future partitions to keep created
+	CheckInterval time.Duration // How often EnsurePartitions runs in the background
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// Config controls the partition interval and how far ahead partitions are created
+type Config struct {
+	Interval      string        // month or day
+	Premake       int           // Number of future partitions to keep created
+	CheckInterval time.Duration // How often EnsurePartitions runs in the background
+}
+
+// Partition describes one child partition of audit_logs
+type Partition struct {
+	Name          string `json:"name"`
+	From          string `json:"from,omitempty"`
+	To            string `json:"to,omitempty"`
+	IsDefault     bool   `json:"is_default"`
+	EstimatedRows int64  `json:"estimated_rows"`
+
+	from time.Time
+	to   time.Time
+}
+
+// Blocked is a period EnsurePartitions could not create a partition for, because the
+// default partition already holds rows in its range
+type Blocked struct {
+	Name        string `json:"name"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	DefaultRows int64  `json:"default_rows"` // Rows of the range in the default partition
+}
+
+// LoadConfig reads the partition settings from viper
+func LoadConfig() Config {
+	interval := strings.ToLower(viper.GetString("AUDIT_PARTITION_INTERVAL"))
+	if interval != IntervalDay {
+		interval = IntervalMonth // default
+	}
+
+	premake := viper.GetInt("AUDIT_PARTITION_PREMAKE")
+	if premake <= 0 {
+		premake = 3 // default
+	}
+
+	checkIntervalMinutes := viper.GetInt("AUDIT_PARTITION_CHECK_INTERVAL")
+	if checkIntervalMinutes <= 0 {
+		checkIntervalMinutes = 60 // default
+	}
+
+	return Config{
+		Interval:      interval,
+		Premake:       premake,
+		CheckInterval: time.Duration(checkIntervalMinutes) * time.Minute,
+	}
+}
+
+func NewManager(db *sql.DB, maintenanceDB *sql.DB, cfg Config) *Manager {
+	return &Manager{
+		DB:            db,
+		MaintenanceDB: maintenanceDB,
+		Interval:      cfg.Interval,
+		Premake:       cfg.Premake,
+		CheckInterval: cfg.CheckInterval,
+	}
+}
+
+// Start runs EnsurePartitions now and then periodically in the background
+func (m *Manager) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.CheckInterval)
+		defer ticker.Stop()
+		for {
+			created, blocked, err := m.EnsurePartitions()
+			if err != nil {
+				log.Printf("[PARTITION ERROR] Failed to ensure partitions: %v", err)
+			}
+			if len(created) > 0 {
+				log.Printf("[PARTITION] Created partitions: %s", strings.Join(created, ", "))
+			}
+			for _, b := range blocked {
+				log.Printf("[PARTITION WARNING] Cannot create %s: the default partition holds %d row(s) from %s to %s; move them into a new partition to unblock it",
+					b.Name, b.DefaultRows, b.From, b.To)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background job, waiting for a run in progress
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+// EnsurePartitions creates the current partition and the next Premake ones
+// Periods already covered by an existing partition (of any interval) are skipped.
+// PostgreSQL refuses to create a partition while the default partition holds rows in its
+// range, so those periods are returned as blocked and the others are still created
+// Returns the names of the partitions created
+func (m *Manager) EnsurePartitions() ([]string, []Blocked, error) {
+	existing, err := m.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	defaultName := ""
+	for _, p := range existing {
+		if p.IsDefault {
+			defaultName = p.Name
+		}
+	}
+
+	var created []string
+	var blocked []Blocked
+	start := m.periodStart(time.Now().UTC())
+	for i := 0; i <= m.Premake; i++ {
+		end := m.nextPeriod(start)
+		if !overlaps(existing, start, end) {
+			name := m.partitionName(start)
+			if defaultName != "" {
+				var defaultRows int64
+				query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE created_at >= $1 AND created_at < $2`, pq.QuoteIdentifier(defaultName))
+				if err := m.DB.QueryRow(query, start, end).Scan(&defaultRows); err != nil {
+					return created, blocked, fmt.Errorf("failed to check %s for rows of partition %s: %w", defaultName, name, err)
+				}
+				if defaultRows > 0 {
+					blocked = append(blocked, Blocked{
+						Name:        name,
+						From:        start.Format(time.RFC3339),
+						To:          end.Format(time.RFC3339),
+						DefaultRows: defaultRows,
+					})
+					start = end
+					continue
+				}
+			}
+			query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)`,
+				pq.QuoteIdentifier(name), pq.QuoteIdentifier(parentTable),
+				pq.QuoteLiteral(start.Format(time.RFC3339)), pq.QuoteLiteral(end.Format(time.RFC3339)))
+			if _, err := m.DB.Exec(query); err != nil {
+				return created, blocked, fmt.Errorf("failed to create partition %s: %w", name, err)
+			}
+			created = append(created, name)
+		}
+		start = end
+	}
+	return created, blocked, nil
+}
+
+// List returns the partitions of audit_logs ordered by range start (default partition last)
+func (m *Manager) List() ([]Partition, error) {
+	query := `
+		SELECT
+			c.relname,
+			pg_get_expr(c.relpartbound, c.oid) = 'DEFAULT' as is_default,
+			(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'FROM \(''([^'']+)''\)'))[1]::timestamptz as range_from,
+			(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1]::timestamptz as range_to,
+			GREATEST(c.reltuples, 0)::bigint as estimated_rows
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+		ORDER BY is_default ASC, range_from ASC
+	`
+
+	rows, err := m.DB.Query(query, parentTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	partitions := []Partition{}
+	for rows.Next() {
+		var p Partition
+		var from, to sql.NullTime
+		if err := rows.Scan(&p.Name, &p.IsDefault, &from, &to, &p.EstimatedRows); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		if from.Valid && to.Valid {
+			p.from = from.Time.UTC()
+			p.to = to.Time.UTC()
+			p.From = p.from.Format(time.RFC3339)
+			p.To = p.to.Format(time.RFC3339)
+		}
+		partitions = append(partitions, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating partitions: %w", err)
+	}
+
+	return partitions, nil
+}
+
+// Detach removes a partition from audit_logs, keeping its data as a standalone table
+// The hash chain is bridged by one range tombstone and the rolled-up hours are marked from
+// the partition bounds, so apart from the legal hold check the work does not grow with the
+// partition's row count
+func (m *Manager) Detach(name string) error {
+	p, err := m.removable(name)
+	if err != nil {
+		return err
+	}
+	quoted := pq.QuoteIdentifier(name)
+
+	tx, err := m.MaintenanceDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the partition, then the holds, so no hold can be placed on its rows until the
+	// detach commits
+	if _, err := tx.Exec(`LOCK TABLE ` + quoted + ` IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock partition %s: %w", name, err)
+	}
+	if _, err := tx.Exec(`LOCK TABLE audit_legal_holds IN SHARE MODE`); err != nil {
+		return fmt.Errorf("failed to lock legal holds: %w", err)
+	}
+
+	// Rows under legal hold must stay in audit_logs until the hold is released
+	var holds int64
+	if err := tx.QueryRow(legalhold.Covering(quoted)).Scan(&holds); err != nil {
+		return fmt.Errorf("failed to check partition %s for legal holds: %w", name, err)
+	}
+	if holds > 0 {
+		return &ErrNotRemovable{Reason: fmt.Sprintf("%s has rows under %d legal hold(s)", name, holds)}
+	}
+
+	// One range tombstone stands for the partition in the hash chain: from the prev_hash of
+	// its first link to the row_hash of its last, including rows already removed by retention
+	ranges := fmt.Sprintf(`
+		WITH links AS (
+			SELECT id, created_at, prev_hash, row_hash FROM %s WHERE row_hash IS NOT NULL
+			UNION ALL
+			SELECT id, created_at, prev_hash, row_hash FROM audit_chain_tombstones
+			WHERE created_at >= $1 AND created_at < $2
+		),
+		first_link AS (SELECT * FROM links ORDER BY id ASC LIMIT 1),
+		last_link AS (SELECT * FROM links ORDER BY id DESC LIMIT 1)
+		INSERT INTO audit_chain_ranges (id, last_id, created_at, last_created_at, prev_hash, row_hash, reason)
+		SELECT f.id, l.id, f.created_at, l.created_at, f.prev_hash, l.row_hash, 'partition_detach'
+		FROM first_link f, last_link l`, quoted)
+	if _, err := tx.Exec(ranges, p.from, p.to); err != nil {
+		return fmt.Errorf("failed to record chain range for partition %s: %w", name, err)
+	}
+
+	// Detaching fires no delete triggers, so mark every hour of the partition's range for recomputing
+	_, err = tx.Exec(`
+		INSERT INTO audit_rollup_dirty (bucket)
+		SELECT generate_series($1::timestamptz, $2::timestamptz - interval '1 hour', interval '1 hour')
+		ON CONFLICT (bucket) DO NOTHING`, p.from, p.to)
+	if err != nil {
+		return fmt.Errorf("failed to mark rollups of partition %s for recomputing: %w", name, err)
+	}
+
+	query := fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, pq.QuoteIdentifier(parentTable), quoted)
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to detach partition %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to detach partition %s: %w", name, err)
+	}
+	log.Printf("[PARTITION] Detached partition %s", name)
+	return nil
+}
+
+// Drop detaches a partition and drops its table, without a row-by-row DELETE
+func (m *Manager) Drop(name string) error {
+	if err := m.Detach(name); err != nil {
+		return err
+	}
+	if _, err := m.MaintenanceDB.Exec(fmt.Sprintf(`DROP TABLE %s`, pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	log.Printf("[PARTITION] Dropped partition %s", name)
+	return nil
+}
+
+// ErrNotRemovable is returned when a partition cannot be detached or dropped
+type ErrNotRemovable struct {
+	Reason string
+}
+
+func (e *ErrNotRemovable) Error() string {
+	return e.Reason
+}
+
+// removable checks that name is a range partition of audit_logs that lies entirely in the past
+// Legal holds are checked by Detach, inside its transaction
+func (m *Manager) removable(name string) (*Partition, error) {
+	if m.MaintenanceDB == nil {
+		return nil, &ErrNotRemovable{Reason: "partition removal needs the maintenance role"}
+	}
+	partitions, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range partitions {
+		p := &partitions[i]
+		if p.Name != name {
+			continue
+		}
+		if p.IsDefault {
+			return nil, &ErrNotRemovable{Reason: "the default partition cannot be removed"}
+		}
+		if !p.to.Before(m.periodStart(time.Now().UTC())) {
+			return nil, &ErrNotRemovable{Reason: "only partitions that ended before the current period can be removed"}
+		}
+		return p, nil
+	}
+	return nil, &ErrNotRemovable{Reason: fmt.Sprintf("%s is not a partition of %s", name, parentTable)}
+}
+
+// periodStart truncates t to the start of its partition period (UTC)
+func (m *Manager) periodStart(t time.Time) time.Time {
+	if m.Interval == IntervalDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (m *Manager) nextPeriod(start time.Time) time.Time {
+	if m.Interval == IntervalDay {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// partitionName follows the naming used by the partitioning migration
+// Example: audit_logs_p202601 (monthly), audit_logs_p20260115 (daily)
+func (m *Manager) partitionName(start time.Time) string {
+	if m.Interval == IntervalDay {
+		return parentTable + "_p" + start.Format("20060102")
+	}
+	return parentTable + "_p" + start.Format("200601")
+}
+
+func overlaps(partitions []Partition, from, to time.Time) bool {
+	for _, p := range partitions {
+		if p.IsDefault || p.from.IsZero() {
+			continue
+		}
+		if p.from.Before(to) && from.Before(p.to) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package querytimeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+// Config holds the query timeout of every endpoint
+// Endpoint names are route names such as "audit-logs" or "reports/audit-slow-actions";
+// a name without its own timeout falls back to its parent ("reports"), then to Default
+type Config struct {
+	Default   time.Duration
+	Endpoints map[string]time.Duration // Zero disables the timeout of an endpoint
+}
+
+// LoadConfig reads AUDIT_QUERY_TIMEOUT and AUDIT_QUERY_TIMEOUTS from viper
+func LoadConfig() Config {
+	defaultSeconds := viper.GetInt("AUDIT_QUERY_TIMEOUT")
+	if defaultSeconds <= 0 {
+		defaultSeconds = 30 // default
+	}
+
+	// Verification reads the whole chain, so it gets longer than other endpoints by default
+	endpoints := map[string]time.Duration{
+		"verify": 5 * time.Minute,
+	}
+	overrides, err := ParseEndpoints(viper.GetString("AUDIT_QUERY_TIMEOUTS"))
+	if err != nil {
+		log.Printf("[QUERY TIMEOUT ERROR] %v - using the default timeouts", err)
+	}
+	for endpoint, timeout := range overrides {
+		endpoints[endpoint] = timeout
+	}
+
+	return Config{
+		Default:   time.Duration(defaultSeconds) * time.Second,
+		Endpoints: endpoints,
+	}
+}
+
+// ParseEndpoints parses "<endpoint>=<seconds>" pairs separated by ";"
+// Example: "reports=120;reports/audit-user-questions=10;audit-logs=5"
+func ParseEndpoints(value string) (map[string]time.Duration, error) {
+	endpoints := map[string]time.Duration{}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		endpoint, secondsStr, found := strings.Cut(part, "=")
+		endpoint = strings.TrimSpace(endpoint)
+		if !found || endpoint == "" {
+			return nil, fmt.Errorf("invalid query timeout %q: expected <endpoint>=<seconds>", part)
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(secondsStr))
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid query timeout %q: seconds must be a non-negative integer", part)
+		}
+		endpoints[endpoint] = time.Duration(seconds) * time.Second
+	}
+	return endpoints, nil
+}
+
+// For returns the timeout of an endpoint
+func (c Config) For(endpoint string) time.Duration {
+	for name := endpoint; name != ""; {
+		if timeout, ok := c.Endpoints[name]; ok {
+			return timeout
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return c.Default
+}
+
+// Wrap runs h with a request context that expires after the endpoint timeout
+// {var} placeholders in endpoint are filled from the route variables, so
+// "reports/{report_id}" gives every report its own name
+// The request context is also cancelled when the client disconnects, which cancels
+// the database query in progress
+func (c Config) Wrap(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := endpoint
+		for key, value := range mux.Vars(r) {
+			name = strings.ReplaceAll(name, "{"+key+"}", value)
+		}
+
+		timeout := c.For(name)
+		if timeout <= 0 {
+			h(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// Aborted answers a failed query whose request context ended: 504 when the endpoint
+// timeout expired, nothing when the client went away (there is nobody to answer)
+// Returns false when the context is still live, leaving the error to the caller
+func Aborted(w http.ResponseWriter, r *http.Request, operation string) bool {
+	err := r.Context().Err()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[QUERY TIMEOUT] %s timed out (%s %s)", operation, r.Method, r.URL.Path)
+		http.Error(w, "Query timed out", http.StatusGatewayTimeout)
+		return true
+	case errors.Is(err, context.Canceled):
+		log.Printf("[QUERY TIMEOUT] %s cancelled, client disconnected (%s %s)", operation, r.Method, r.URL.Path)
+		return true
+	}
+	return false
+}
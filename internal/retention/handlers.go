@@ -0,0 +1,46 @@
+package retention
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// PreviewHandler handles GET /api/admin/retention/preview
+// Dry run: returns how many rows each rule would purge right now, without deleting anything
+func (e *Engine) PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if e.PolicyErr != nil {
+		http.Error(w, "Invalid retention rules: "+e.PolicyErr.Error(), http.StatusConflict)
+		return
+	}
+
+	preview, err := e.Preview()
+	if err != nil {
+		log.Printf("error occurred during retention Preview: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": e.Enabled,
+		"preview": preview,
+	})
+}
+
+// PurgeHandler handles POST /api/admin/retention/purge
+// Runs one purge immediately (bounded by AUDIT_RETENTION_MAX_BATCHES) and returns its summary
+func (e *Engine) PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if e.PolicyErr != nil {
+		http.Error(w, "Invalid retention rules: "+e.PolicyErr.Error(), http.StatusConflict)
+		return
+	}
+
+	result := e.Purge()
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.Error != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,447 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/motiso/sparksai-audit-service/internal/archive"
+	"github.com/motiso/sparksai-audit-service/internal/auditlog"
+	"github.com/motiso/sparksai-audit-service/internal/legalhold"
+	"github.com/spf13/viper"
+)
+
+// Engine applies the retention policy to audit_logs in bounded batches
+type Engine struct {
+	DB         *sql.DB                    // Maintenance pool, exempt from the append-only triggers
+	Datastore  auditlog.AuditLogDatastore // Used to write the purge summary entry
+	Archiver   *archive.Archiver          // When set, rows are archived before they are deleted
+	Policy     Policy
+	PolicyErr  error // Set when AUDIT_RETENTION_RULES could not be parsed
+	Enabled    bool
+	Interval   time.Duration
+	BatchSize  int
+	MaxBatches int
+	BatchPause time.Duration
+	runMu      sync.Mutex // Only one purge runs at a time
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// Config holds the retention policy and purge pacing
+type Config struct {
+	Enabled    bool
+	Rules      string // AUDIT_RETENTION_RULES syntax, parsed by NewEngine
+	Interval   time.Duration
+	BatchSize  int
+	MaxBatches int
+	BatchPause time.Duration
+	Archive    archive.Config
+}
+
+// RulePreview is the dry-run result of a single rule
+type RulePreview struct {
+	Rule         string `json:"rule"`
+	Period       string `json:"period"`
+	Cutoff       string `json:"cutoff"`
+	MatchingRows int64  `json:"matching_rows"` // Expired rows this rule matches, excluding held rows
+}
+
+// Preview is the dry-run result of the whole policy
+type Preview struct {
+	GeneratedAt string        `json:"generated_at"`
+	ExpiredRows int64         `json:"expired_rows"`
+	HeldRows    int64         `json:"held_rows"` // Expired rows kept under legal hold, not counted in ExpiredRows
+	OldestRow   *string       `json:"oldest_row,omitempty"`
+	NewestRow   *string       `json:"newest_row,omitempty"`
+	Rules       []RulePreview `json:"rules"`
+}
+
+// PurgeResult summarizes one purge run
+type PurgeResult struct {
+	StartedAt   string `json:"started_at"`
+	DeletedRows int64  `json:"deleted_rows"`
+	Batches     int    `json:"batches"`
+	Complete    bool   `json:"complete"` // False when MaxBatches stopped the run early
+	Error       string `json:"error,omitempty"`
+}
+
+// LoadConfig reads the retention settings from viper
+func LoadConfig() Config {
+	intervalMinutes := viper.GetInt("AUDIT_RETENTION_INTERVAL")
+	if intervalMinutes <= 0 {
+		intervalMinutes = 1440 // default: daily
+	}
+
+	batchSize := viper.GetInt("AUDIT_RETENTION_BATCH_SIZE")
+	if batchSize <= 0 {
+		batchSize = 1000 // default
+	}
+
+	maxBatches := viper.GetInt("AUDIT_RETENTION_MAX_BATCHES")
+	if maxBatches <= 0 {
+		maxBatches = 100 // default
+	}
+
+	batchPauseMs := viper.GetInt("AUDIT_RETENTION_BATCH_PAUSE_MS")
+	if batchPauseMs < 0 {
+		batchPauseMs = 0
+	}
+
+	return Config{
+		Enabled:    viper.GetBool("AUDIT_RETENTION_ENABLED"),
+		Rules:      viper.GetString("AUDIT_RETENTION_RULES"),
+		Interval:   time.Duration(intervalMinutes) * time.Minute,
+		BatchSize:  batchSize,
+		MaxBatches: maxBatches,
+		BatchPause: time.Duration(batchPauseMs) * time.Millisecond,
+		Archive:    archive.LoadConfig(),
+	}
+}
+
+// NewEngine parses the policy; retention is disabled when the rules are invalid
+// db should be the maintenance pool, which the append-only triggers exempt
+func NewEngine(db *sql.DB, datastore auditlog.AuditLogDatastore, cfg Config) *Engine {
+	policy, policyErr := ParsePolicy(cfg.Rules)
+	if policyErr != nil {
+		log.Printf("[RETENTION ERROR] %v - retention is disabled", policyErr)
+	}
+
+	return &Engine{
+		DB:         db,
+		Datastore:  datastore,
+		Archiver:   archive.NewArchiver(cfg.Archive),
+		Policy:     policy,
+		PolicyErr:  policyErr,
+		Enabled:    cfg.Enabled && policyErr == nil,
+		Interval:   cfg.Interval,
+		BatchSize:  cfg.BatchSize,
+		MaxBatches: cfg.MaxBatches,
+		BatchPause: cfg.BatchPause,
+	}
+}
+
+// Start runs the purge periodically in the background when retention is enabled
+func (e *Engine) Start() {
+	if !e.Enabled {
+		log.Printf("[RETENTION] Scheduled purge disabled")
+		return
+	}
+	log.Printf("[RETENTION] Scheduled purge every %s with %d rule(s)", e.Interval, len(e.Policy.Rules))
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.Purge()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduled purge, waiting for a run in progress
+func (e *Engine) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+	e.stop = nil
+}
+
+// Preview counts the rows the policy would purge right now, without deleting anything
+func (e *Engine) Preview() (*Preview, error) {
+	now := time.Now().UTC()
+	preview := &Preview{GeneratedAt: now.Format(time.RFC3339), Rules: []RulePreview{}}
+	if len(e.Policy.Rules) == 0 {
+		return preview, nil
+	}
+
+	compiled := e.Policy.compile(now, 1)
+	args := compiled.args
+
+	query := `SELECT COUNT(*) FILTER (WHERE NOT held), MIN(created_at) FILTER (WHERE NOT held),
+		MAX(created_at) FILTER (WHERE NOT held), COUNT(*) FILTER (WHERE held)`
+	for _, match := range compiled.matches {
+		query += ", COUNT(*) FILTER (WHERE NOT held AND " + match + ")"
+	}
+	// Every expired row is older than the latest cutoff, which lets Postgres prune partitions
+	query += `
+		FROM (
+			SELECT audit_logs.*, ` + legalhold.Held("audit_logs") + ` AS held
+			FROM audit_logs
+			WHERE created_at < $` + strconv.Itoa(len(args)+1) + `
+				AND ` + compiled.expired + `
+		) expired`
+	args = append(args, compiled.maxCutoff)
+
+	var oldest, newest sql.NullTime
+	perRule := make([]int64, len(compiled.matches))
+	dest := []interface{}{&preview.ExpiredRows, &oldest, &newest, &preview.HeldRows}
+	for i := range perRule {
+		dest = append(dest, &perRule[i])
+	}
+	if err := e.DB.QueryRow(query, args...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to preview retention: %w", err)
+	}
+
+	if oldest.Valid {
+		formatted := oldest.Time.UTC().Format(time.RFC3339)
+		preview.OldestRow = &formatted
+	}
+	if newest.Valid {
+		formatted := newest.Time.UTC().Format(time.RFC3339)
+		preview.NewestRow = &formatted
+	}
+	for i, rule := range e.Policy.Rules {
+		preview.Rules = append(preview.Rules, RulePreview{
+			Rule:         rule.Name,
+			Period:       rule.Period,
+			Cutoff:       rule.Cutoff(now).Format(time.RFC3339),
+			MatchingRows: perRule[i],
+		})
+	}
+	return preview, nil
+}
+
+// Purge deletes expired rows in batches of BatchSize, up to MaxBatches per run,
+// then writes a summary audit entry describing what was purged
+func (e *Engine) Purge() *PurgeResult {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	started := time.Now().UTC()
+	result := &PurgeResult{StartedAt: started.Format(time.RFC3339), Complete: true}
+	if len(e.Policy.Rules) == 0 {
+		return result
+	}
+
+	compiled := e.Policy.compile(started, 1)
+	args := append(compiled.args, compiled.maxCutoff, e.BatchSize)
+	cutoffParam := "$" + strconv.Itoa(len(compiled.args)+1)
+	limitParam := "$" + strconv.Itoa(len(compiled.args)+2)
+
+	// Rows under legal hold are skipped (and so never archived) until the hold is released
+	selectExpired := `
+		SELECT id, created_at
+		FROM audit_logs
+		WHERE created_at < ` + cutoffParam + `
+			AND ` + compiled.expired + `
+			AND NOT ` + legalhold.Held("audit_logs") + `
+		ORDER BY created_at ASC
+		LIMIT ` + limitParam
+
+	if e.Archiver != nil {
+		if err := e.recoverArchive(); err != nil {
+			result.Error = err.Error()
+			log.Printf("[RETENTION ERROR] Failed to recover the archive: %v", err)
+			e.recordPurge(started, result)
+			return result
+		}
+	}
+
+	for result.Batches < e.MaxBatches {
+		var deleted int64
+		var err error
+		if e.Archiver != nil {
+			deleted, err = e.archiveAndDeleteBatch(selectExpired, args)
+		} else {
+			deleted, err = e.deleteBatch(selectExpired, args)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			log.Printf("[RETENTION ERROR] Purge batch failed: %v", err)
+			break
+		}
+		result.DeletedRows += deleted
+		result.Batches++
+
+		if deleted < int64(e.BatchSize) {
+			break
+		}
+		if result.Batches == e.MaxBatches {
+			result.Complete = false
+			break
+		}
+		if e.BatchPause > 0 {
+			time.Sleep(e.BatchPause)
+		}
+	}
+
+	log.Printf("[RETENTION] Purged %d audit log entries in %d batch(es)", result.DeletedRows, result.Batches)
+	e.recordPurge(started, result)
+	return result
+}
+
+// recordTombstones keeps the chain hashes of the rows returned by the "deleted" CTE,
+// so the audit hash chain still verifies after they are gone
+const recordTombstones = `,
+	tombstones AS (
+		INSERT INTO audit_chain_tombstones (id, created_at, prev_hash, content_hash, row_hash, reason)
+		SELECT id, created_at, prev_hash, content_hash, row_hash, 'retention'
+		FROM deleted
+		WHERE row_hash IS NOT NULL
+		ON CONFLICT (id) DO NOTHING
+	)
+	SELECT COUNT(*) FROM deleted`
+
+// deleteBatch deletes one batch of expired rows
+func (e *Engine) deleteBatch(selectExpired string, args []interface{}) (int64, error) {
+	var deleted int64
+	err := e.DB.QueryRow(`
+		WITH deleted AS (
+			DELETE FROM audit_logs
+			WHERE (id, created_at) IN (`+selectExpired+`)
+			RETURNING id, created_at, prev_hash, content_hash, row_hash
+		)`+recordTombstones, args...).Scan(&deleted)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// archiveAndDeleteBatch locks one batch of expired rows, stages them in the archive
+// and deletes them in the same transaction. Rows are only deleted once the archive
+// files are synced, and the manifests only count them once the delete has committed;
+// otherwise the staged batch is cut off the files again, so a retried batch is never
+// archived twice.
+func (e *Engine) archiveAndDeleteBatch(selectExpired string, args []interface{}) (deleted int64, err error) {
+	tx, err := e.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// search_vector is derived from the other columns, so archives leave it out
+	rows, err := tx.Query(`
+		SELECT a.id, a.created_at, (to_jsonb(a) - 'search_vector')::text
+		FROM audit_logs a
+		WHERE (a.id, a.created_at) IN (`+selectExpired+`)
+		ORDER BY a.created_at ASC, a.id ASC
+		FOR UPDATE`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select rows to archive: %w", err)
+	}
+
+	var records []archive.Record
+	var ids []int64
+	for rows.Next() {
+		var record archive.Record
+		var row string
+		if err := rows.Scan(&record.ID, &record.CreatedAt, &row); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row to archive: %w", err)
+		}
+		record.Row = []byte(row)
+		records = append(records, record)
+		ids = append(ids, record.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows to archive: %w", err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	batch, err := e.Archiver.Stage(records)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive rows: %w", err)
+	}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if abortErr := batch.Abort(); abortErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to discard archived batch: %w", abortErr))
+		}
+	}()
+
+	// created_at bounds let Postgres prune partitions for the delete
+	err = tx.QueryRow(`
+		WITH deleted AS (
+			DELETE FROM audit_logs
+			WHERE id = ANY($1) AND created_at >= $2 AND created_at <= $3
+			RETURNING id, created_at, prev_hash, content_hash, row_hash
+		)`+recordTombstones,
+		pq.Array(ids), records[0].CreatedAt, records[len(records)-1].CreatedAt).Scan(&deleted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete archived rows: %w", err)
+	}
+	// The rows are locked, so anything short of all of them means the delete was blocked
+	if deleted != int64(len(records)) {
+		return 0, fmt.Errorf("deleted %d of %d archived rows", deleted, len(records))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge batch: %w", err)
+	}
+	committed = true
+
+	// The rows are gone; a failed manifest update is left for the next recoverArchive
+	if err := batch.Commit(); err != nil {
+		log.Printf("[RETENTION ERROR] Failed to publish archived batch: %v", err)
+	}
+	return deleted, nil
+}
+
+// recoverArchive settles archive batches left pending by a crash, checking whether
+// their rows were deleted
+func (e *Engine) recoverArchive() error {
+	return e.Archiver.Recover(func(ids []int64) (bool, error) {
+		var remaining bool
+		err := e.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM audit_logs WHERE id = ANY($1))`, pq.Array(ids)).Scan(&remaining)
+		return !remaining, err
+	})
+}
+
+// recordPurge writes the purge summary to audit_logs so purges are themselves audited
+func (e *Engine) recordPurge(started time.Time, result *PurgeResult) {
+	action := "retention-purge"
+	actorType := auditlog.ActorTypeSystem
+	count := int(result.DeletedRows)
+	outcome := auditlog.OutcomeSuccess
+	statusCode := 200
+	if result.Error != "" {
+		outcome = auditlog.OutcomeError
+		statusCode = 500
+	}
+
+	summary, _ := json.Marshal(map[string]interface{}{
+		"rules":    e.Policy.Rules,
+		"result":   result,
+		"archived": e.Archiver != nil,
+	})
+	body := string(summary)
+
+	entry := auditlog.AuditLog{
+		Severity:            "NONE",
+		EndpointPath:        "/internal/retention/purge",
+		Action:              &action,
+		Count:               &count,
+		HTTPMethod:          "DELETE",
+		StatusCode:          statusCode,
+		ResponseTimeSeconds: time.Since(started).Seconds(),
+		ActorType:           &actorType,
+		Outcome:             outcome,
+		BodyRaw:             &body,
+	}
+	if err := e.Datastore.BatchInsertAuditLogs(context.Background(), []auditlog.AuditLog{entry}); err != nil {
+		log.Printf("[RETENTION ERROR] Failed to record purge summary: %v", err)
+	}
+}
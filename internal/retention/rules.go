@@ -0,0 +1,184 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// matchableFields are the audit_logs columns a retention rule can match on
+var matchableFields = map[string]bool{
+	"severity":      true,
+	"action":        true,
+	"user_id":       true,
+	"http_method":   true,
+	"endpoint_path": true,
+	"outcome":       true,
+	"actor_type":    true,
+	"resource_type": true,
+	"tenant_id":     true,
+}
+
+// Rule keeps rows matching all of its criteria for the given period
+// A rule without criteria matches every row (the default rule, written "*")
+type Rule struct {
+	Name     string            `json:"name"`
+	Criteria map[string]string `json:"criteria"`
+	Period   string            `json:"period"`
+
+	years, months, days int
+}
+
+// Policy is a set of retention rules
+// A row is governed by the most specific rules that match it (the most criteria),
+// so "*" only applies to rows no other rule matches. When several equally specific
+// rules match, the row is purged only once all of them say it has expired - the
+// longest retention wins and rule order does not matter. Rows matched by no rule
+// are kept forever.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParsePolicy parses rules in the form "<criteria>:<period>" separated by ";"
+// criteria is "field=value" joined with "&", or "*" for every row
+// period is a number followed by d (days), w (weeks), m (months) or y (years)
+// Example: "severity=NONE:90d;severity=ERROR:1y;action=login:2y"
+func ParsePolicy(spec string) (Policy, error) {
+	var policy Policy
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sep := strings.LastIndex(part, ":")
+		if sep <= 0 {
+			return policy, fmt.Errorf("invalid retention rule %q: expected <criteria>:<period>", part)
+		}
+		rule, err := parseRule(strings.TrimSpace(part[:sep]), strings.TrimSpace(part[sep+1:]))
+		if err != nil {
+			return policy, fmt.Errorf("invalid retention rule %q: %w", part, err)
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+func parseRule(criteriaSpec string, period string) (Rule, error) {
+	rule := Rule{Name: criteriaSpec + ":" + period, Criteria: map[string]string{}, Period: period}
+
+	if criteriaSpec != "*" {
+		for _, criterion := range strings.Split(criteriaSpec, "&") {
+			field, value, found := strings.Cut(criterion, "=")
+			field = strings.TrimSpace(field)
+			if !found || value == "" {
+				return rule, fmt.Errorf("criterion %q must be field=value", criterion)
+			}
+			if !matchableFields[field] {
+				return rule, fmt.Errorf("field %q cannot be used in retention rules", field)
+			}
+			rule.Criteria[field] = strings.TrimSpace(value)
+		}
+	}
+
+	if len(period) < 2 {
+		return rule, fmt.Errorf("invalid period %q", period)
+	}
+	amount, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || amount <= 0 {
+		return rule, fmt.Errorf("invalid period %q", period)
+	}
+	switch period[len(period)-1] {
+	case 'd':
+		rule.days = amount
+	case 'w':
+		rule.days = amount * 7
+	case 'm':
+		rule.months = amount
+	case 'y':
+		rule.years = amount
+	default:
+		return rule, fmt.Errorf("invalid period unit in %q (use d, w, m or y)", period)
+	}
+	return rule, nil
+}
+
+// Cutoff returns the instant before which rows matching the rule have expired
+func (r Rule) Cutoff(now time.Time) time.Time {
+	return now.AddDate(-r.years, -r.months, -r.days)
+}
+
+// matchSQL renders the rule criteria as a NULL-safe boolean expression
+func (r Rule) matchSQL(argIndex int) (string, []interface{}) {
+	if len(r.Criteria) == 0 {
+		return "TRUE", nil
+	}
+
+	// Sort fields so the generated SQL is stable
+	fields := make([]string, 0, len(r.Criteria))
+	for field := range r.Criteria {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var conditions []string
+	var args []interface{}
+	for _, field := range fields {
+		conditions = append(conditions, field+" = $"+strconv.Itoa(argIndex))
+		args = append(args, r.Criteria[field])
+		argIndex++
+	}
+	return "COALESCE(" + strings.Join(conditions, " AND ") + ", FALSE)", args
+}
+
+// compiledPolicy is a policy rendered as SQL for a fixed point in time
+type compiledPolicy struct {
+	matches   []string // Per rule match expression
+	expired   string   // Rows that every matching rule considers expired
+	maxCutoff time.Time
+	args      []interface{}
+}
+
+// compile renders the policy as SQL expressions, numbering parameters from argIndex
+func (p Policy) compile(now time.Time, argIndex int) compiledPolicy {
+	var c compiledPolicy
+	var anyMatch, allExpired []string
+
+	cutoffParams := make([]string, len(p.Rules))
+	for i, rule := range p.Rules {
+		match, args := rule.matchSQL(argIndex)
+		argIndex += len(args)
+		c.args = append(c.args, args...)
+
+		cutoff := rule.Cutoff(now)
+		if cutoff.After(c.maxCutoff) {
+			c.maxCutoff = cutoff
+		}
+		cutoffParams[i] = "$" + strconv.Itoa(argIndex)
+		argIndex++
+		c.args = append(c.args, cutoff)
+
+		c.matches = append(c.matches, match)
+		anyMatch = append(anyMatch, match)
+	}
+
+	// A rule's cutoff applies unless the row is also matched by a more specific rule
+	for i, rule := range p.Rules {
+		terms := []string{"NOT " + c.matches[i]}
+		for j, other := range p.Rules {
+			if len(other.Criteria) > len(rule.Criteria) {
+				terms = append(terms, c.matches[j])
+			}
+		}
+		terms = append(terms, "created_at < "+cutoffParams[i])
+		allExpired = append(allExpired, "("+strings.Join(terms, " OR ")+")")
+	}
+
+	if len(p.Rules) == 0 {
+		c.expired = "FALSE"
+		return c
+	}
+	c.expired = "(" + strings.Join(anyMatch, " OR ") + ") AND " + strings.Join(allExpired, " AND ")
+	return c
+}
@@ -0,0 +1,364 @@
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	database "github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/spf13/viper"
+)
+
+// stateName is the audit_rollup_state row of the hourly rollups
+const stateName = "hourly"
+
+// lockKey serializes rollup runs and backfills across instances
+const lockKey int64 = 7263842003
+
+// settleDelay is how long after an hour ends before it is rolled up,
+// leaving room for buffered inserts and clock skew between instances
+const settleDelay = 2 * time.Minute
+
+// backfillChunk is the range rebuilt per transaction by Backfill
+const backfillChunk = 24 * time.Hour
+
+// Manager keeps audit_rollups_hourly up to date with audit_logs
+type Manager struct {
+	DB       *sql.DB
+	Enabled  bool
+	Interval time.Duration // How often Run is called in the background
+	Lookback int           // Completed hours recomputed by every run, for rows inserted late
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Config controls the rollup job
+type Config struct {
+	Enabled  bool
+	Interval time.Duration
+	Lookback int
+}
+
+// Coverage is the range of whole hours [From, To) that the rollups hold
+type Coverage struct {
+	From time.Time
+	To   time.Time
+}
+
+// LoadConfig reads the rollup settings from viper
+func LoadConfig() Config {
+	// AUDIT_ROLLUP_ENABLED defaults to true; reports fall back to audit_logs when disabled
+	enabled := true
+	if viper.IsSet("AUDIT_ROLLUP_ENABLED") {
+		enabled = viper.GetBool("AUDIT_ROLLUP_ENABLED")
+	}
+
+	intervalSeconds := viper.GetInt("AUDIT_ROLLUP_INTERVAL")
+	if intervalSeconds <= 0 {
+		intervalSeconds = 60 // default
+	}
+
+	lookback := 2 // default
+	if viper.IsSet("AUDIT_ROLLUP_LOOKBACK_HOURS") && viper.GetInt("AUDIT_ROLLUP_LOOKBACK_HOURS") >= 0 {
+		lookback = viper.GetInt("AUDIT_ROLLUP_LOOKBACK_HOURS")
+	}
+
+	return Config{
+		Enabled:  enabled,
+		Interval: time.Duration(intervalSeconds) * time.Second,
+		Lookback: lookback,
+	}
+}
+
+func NewManager(db *sql.DB, cfg Config) *Manager {
+	return &Manager{
+		DB:       db,
+		Enabled:  cfg.Enabled,
+		Interval: cfg.Interval,
+		Lookback: cfg.Lookback,
+	}
+}
+
+// Start runs Run now and then periodically in the background when rollups are enabled
+func (m *Manager) Start() {
+	if !m.Enabled {
+		log.Printf("[ROLLUP] Hourly rollups disabled")
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		for {
+			if hours, err := m.Run(context.Background()); err != nil {
+				log.Printf("[ROLLUP ERROR] Failed to update rollups: %v", err)
+			} else if hours > 0 {
+				log.Printf("[ROLLUP] Recomputed %d hour(s)", hours)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background job, waiting for a run in progress
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+// Run recomputes the hours completed since the last run (plus Lookback hours before it)
+// and the hours marked dirty by deletes, then advances the coverage
+// The first run starts the coverage at the current hour; Backfill extends it back
+// Returns the number of hours recomputed
+func (m *Manager) Run(ctx context.Context) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return 0, fmt.Errorf("failed to acquire rollup lock: %w", err)
+	}
+
+	cutoff := time.Now().UTC().Add(-settleDelay).Truncate(time.Hour)
+	coverage, found, err := readCoverage(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		coverage = Coverage{From: cutoff, To: cutoff}
+	}
+
+	hours := 0
+	start := coverage.To.Add(-time.Duration(m.Lookback) * time.Hour)
+	if start.Before(coverage.From) {
+		start = coverage.From
+	}
+	if start.Before(cutoff) {
+		if err := recompute(ctx, tx, start, cutoff); err != nil {
+			return 0, err
+		}
+		hours += int(cutoff.Sub(start) / time.Hour)
+	}
+
+	// Dirty hours before the coverage are left to backfill, later ones to the next runs
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM audit_rollup_dirty
+		WHERE bucket >= $1 AND bucket < $2
+		RETURNING bucket`, coverage.From, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read dirty hours: %w", err)
+	}
+	var dirty []time.Time
+	for rows.Next() {
+		var bucket time.Time
+		if err := rows.Scan(&bucket); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan dirty hour: %w", err)
+		}
+		// Hours from start on were just recomputed
+		if bucket.Before(start) {
+			dirty = append(dirty, bucket)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating dirty hours: %w", err)
+	}
+	for _, bucket := range dirty {
+		if err := recompute(ctx, tx, bucket, bucket.Add(time.Hour)); err != nil {
+			return 0, err
+		}
+	}
+	hours += len(dirty)
+
+	if cutoff.After(coverage.To) {
+		coverage.To = cutoff
+	}
+	if err := writeCoverage(ctx, tx, coverage); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit rollups: %w", err)
+	}
+	return hours, nil
+}
+
+// Backfill rebuilds the rollups of every completed hour from from (the first audit log
+// when zero) to now, one day per transaction, then makes that the coverage
+// Rollups before from are removed
+func (m *Manager) Backfill(ctx context.Context, from time.Time) (Coverage, error) {
+	if from.IsZero() {
+		var first sql.NullTime
+		if err := m.DB.QueryRowContext(ctx, `SELECT MIN(created_at) FROM audit_logs`).Scan(&first); err != nil {
+			return Coverage{}, fmt.Errorf("failed to read first audit log: %w", err)
+		}
+		if first.Valid {
+			from = first.Time
+		}
+	}
+	cutoff := time.Now().UTC().Add(-settleDelay).Truncate(time.Hour)
+	from = from.UTC().Truncate(time.Hour)
+	if from.IsZero() || from.After(cutoff) {
+		from = cutoff
+	}
+
+	for start := from; start.Before(cutoff); start = start.Add(backfillChunk) {
+		end := start.Add(backfillChunk)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+		err := m.inLockedTx(ctx, func(tx *sql.Tx) error {
+			return recompute(ctx, tx, start, end)
+		})
+		if err != nil {
+			return Coverage{}, err
+		}
+		log.Printf("[ROLLUP] Rebuilt %s - %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	// Run may have advanced the coverage past cutoff meanwhile
+	coverage := Coverage{From: from, To: cutoff}
+	err := m.inLockedTx(ctx, func(tx *sql.Tx) error {
+		current, found, err := readCoverage(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if found && current.To.After(coverage.To) {
+			coverage.To = current.To
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM audit_rollups_hourly WHERE bucket < $1`, from); err != nil {
+			return fmt.Errorf("failed to remove rollups before %s: %w", from.Format(time.RFC3339), err)
+		}
+		// Hours changed during the rebuild stay dirty for the next run
+		if _, err := tx.ExecContext(ctx, `DELETE FROM audit_rollup_dirty WHERE bucket < $1`, from); err != nil {
+			return fmt.Errorf("failed to clear dirty hours: %w", err)
+		}
+		return writeCoverage(ctx, tx, coverage)
+	})
+	if err != nil {
+		return Coverage{}, err
+	}
+	return coverage, nil
+}
+
+// ReadCoverage returns the hours the rollups hold; ok is false before the first run
+// q should be the connection the rollups are then read from, so both come from the same server
+func ReadCoverage(ctx context.Context, q database.Queryer) (Coverage, bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT rolled_from, rolled_up_to FROM audit_rollup_state WHERE name = $1`, stateName)
+	if err != nil {
+		return Coverage{}, false, fmt.Errorf("failed to read rollup coverage: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Coverage{}, false, rows.Err()
+	}
+	var coverage Coverage
+	if err := rows.Scan(&coverage.From, &coverage.To); err != nil {
+		return Coverage{}, false, fmt.Errorf("failed to scan rollup coverage: %w", err)
+	}
+	coverage.From = coverage.From.UTC()
+	coverage.To = coverage.To.UTC()
+	return coverage, true, nil
+}
+
+func (m *Manager) inLockedTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire rollup lock: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+	return nil
+}
+
+func readCoverage(ctx context.Context, tx *sql.Tx) (Coverage, bool, error) {
+	var coverage Coverage
+	err := tx.QueryRowContext(ctx, `
+		SELECT rolled_from, rolled_up_to FROM audit_rollup_state WHERE name = $1
+		FOR UPDATE`, stateName).Scan(&coverage.From, &coverage.To)
+	if err == sql.ErrNoRows {
+		return Coverage{}, false, nil
+	}
+	if err != nil {
+		return Coverage{}, false, fmt.Errorf("failed to read rollup coverage: %w", err)
+	}
+	coverage.From = coverage.From.UTC()
+	coverage.To = coverage.To.UTC()
+	return coverage, true, nil
+}
+
+func writeCoverage(ctx context.Context, tx *sql.Tx, coverage Coverage) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_rollup_state (name, rolled_from, rolled_up_to, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (name) DO UPDATE
+		SET rolled_from = EXCLUDED.rolled_from, rolled_up_to = EXCLUDED.rolled_up_to, updated_at = EXCLUDED.updated_at`,
+		stateName, coverage.From, coverage.To)
+	if err != nil {
+		return fmt.Errorf("failed to store rollup coverage: %w", err)
+	}
+	return nil
+}
+
+// recompute replaces the rollups of the hours in [from, to) with fresh aggregates of audit_logs
+func recompute(ctx context.Context, tx *sql.Tx, from time.Time, to time.Time) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM audit_rollups_hourly WHERE bucket >= $1 AND bucket < $2`, from, to); err != nil {
+		return fmt.Errorf("failed to clear rollups: %w", err)
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_rollups_hourly (
+			bucket, action, endpoint_path, user_id, http_method, status_code, severity,
+			request_count, response_time_sum, response_time_max,
+			latency_le_100ms, latency_le_250ms, latency_le_500ms, latency_le_1s,
+			latency_le_2500ms, latency_le_5s, latency_le_10s, latency_gt_10s,
+			tokens_sum, tokens_count, count_sum, count_rows
+		)
+		SELECT
+			date_trunc('hour', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+			action, endpoint_path, user_id, http_method, status_code, severity,
+			COUNT(*),
+			SUM(response_time_seconds),
+			MAX(response_time_seconds),
+			COUNT(*) FILTER (WHERE response_time_seconds <= 0.1),
+			COUNT(*) FILTER (WHERE response_time_seconds > 0.1 AND response_time_seconds <= 0.25),
+			COUNT(*) FILTER (WHERE response_time_seconds > 0.25 AND response_time_seconds <= 0.5),
+			COUNT(*) FILTER (WHERE response_time_seconds > 0.5 AND response_time_seconds <= 1),
+			COUNT(*) FILTER (WHERE response_time_seconds > 1 AND response_time_seconds <= 2.5),
+			COUNT(*) FILTER (WHERE response_time_seconds > 2.5 AND response_time_seconds <= 5),
+			COUNT(*) FILTER (WHERE response_time_seconds > 5 AND response_time_seconds <= 10),
+			COUNT(*) FILTER (WHERE response_time_seconds > 10),
+			SUM(tokens_used),
+			COUNT(tokens_used),
+			SUM(count),
+			COUNT(count)
+		FROM audit_logs
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY 1, action, endpoint_path, user_id, http_method, status_code, severity`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to compute rollups for %s - %s: %w", from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+	}
+	return nil
+}
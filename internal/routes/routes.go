@@ -1,12 +1,23 @@
 package routes
 
 import (
+	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/motiso/sparksai-audit-service/internal/adminauth"
 	auditlogService "github.com/motiso/sparksai-audit-service/internal/auditlog/service"
-	"github.com/motiso/sparksai-audit-service/internal/db"
+	"github.com/motiso/sparksai-audit-service/internal/checkpoint"
+	"github.com/motiso/sparksai-audit-service/internal/dsar"
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/motiso/sparksai-audit-service/internal/erasure"
+	"github.com/motiso/sparksai-audit-service/internal/legalhold"
+	"github.com/motiso/sparksai-audit-service/internal/metaevent"
+	"github.com/motiso/sparksai-audit-service/internal/partition"
+	"github.com/motiso/sparksai-audit-service/internal/querytimeout"
+	"github.com/motiso/sparksai-audit-service/internal/retention"
 )
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,53 +39,137 @@ func livenessHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Kubernetes readiness probe - checks if the application is ready to serve traffic
-func readinessHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+func readinessHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 
-	// Check database connectivity
-	dbConn := db.Get()
-	if dbConn == nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "not_ready",
-			"reason": "database connection unavailable",
-		})
-		return
-	}
+		// Check database connectivity
+		if dbConn == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "not_ready",
+				"reason": "database connection unavailable",
+			})
+			return
+		}
+
+		// Ping the database
+		if err := dbConn.Ping(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "not_ready",
+				"reason": "database ping failed",
+				"error":  err.Error(),
+			})
+			return
+		}
 
-	// Ping the database
-	if err := dbConn.Ping(); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status": "not_ready",
-			"reason": "database ping failed",
-			"error":  err.Error(),
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "ready",
 		})
-		return
 	}
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "ready",
-	})
+// Services are the handlers the router dispatches to
+// Partitions, Retention, Checkpoints, MetaEvents, Erasure, Exports and LegalHolds are nil on SQLite, which leaves
+// their routes unregistered; so is Encryption, leaving reader tokens unchecked. Retention and Erasure are
+// also nil without a maintenance role, which leaves partition removal unregistered as well
+type Services struct {
+	DB          *sql.DB // Checked by the readiness probe
+	Audit       *auditlogService.AuditService
+	Reports     *auditlogService.ReportService
+	Partitions  *partition.Manager
+	Retention   *retention.Engine
+	Checkpoints *checkpoint.Manager
+	MetaEvents  *metaevent.Store
+	Maintenance bool // A maintenance role is configured, so audit rows can be removed
+	Encryption  *encryption.Keyring
+	Erasure     *erasure.Manager
+	Exports     *dsar.Exporter
+	LegalHolds  *legalhold.Manager
+	Admin       adminauth.Config    // Tokens accepted on /api/admin routes
+	Timeouts    querytimeout.Config // Query timeout of each endpoint
 }
 
-func SetupRoutes(r *mux.Router) {
-	auditSvc := auditlogService.Get()
-	reportSvc := auditlogService.NewReportService()
+func SetupRoutes(r *mux.Router, s Services) {
+	auditSvc := s.Audit
+	reportSvc := s.Reports
+	timeouts := s.Timeouts
+
+	// Requests with a valid X-Audit-Reader-Token see encrypted payloads decrypted
+	if s.Encryption != nil {
+		r.Use(s.Encryption.Middleware)
+	}
 
 	// Health check endpoints
 	r.HandleFunc("/health", healthCheckHandler).Methods("GET")
 	r.HandleFunc("/health/live", livenessHandler).Methods("GET")
-	r.HandleFunc("/health/ready", readinessHandler).Methods("GET")
+	r.HandleFunc("/health/ready", readinessHandler(s.DB)).Methods("GET")
 
 	// Audit log routes
 	r.HandleFunc("/api/audit-logs", auditSvc.CreateAuditLogsHandler).Methods("POST")
-	r.HandleFunc("/api/audit-logs", auditSvc.GetAuditLogsHandler).Methods("GET")
-	r.HandleFunc("/api/audit-logs/actions", auditSvc.GetActionsHandler).Methods("GET")
-	r.HandleFunc("/api/audit-logs/filter-values", auditSvc.GetAuditLogsFilterValuesHandler).Methods("GET")
+	r.HandleFunc("/api/audit-logs", timeouts.Wrap("audit-logs", auditSvc.GetAuditLogsHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/actions", timeouts.Wrap("actions", auditSvc.GetActionsHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/filter-values", timeouts.Wrap("filter-values", auditSvc.GetAuditLogsFilterValuesHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/resources/{resource_type}/{resource_id}", timeouts.Wrap("resource-history", auditSvc.GetResourceHistoryHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/{id:[0-9]+}", timeouts.Wrap("entry", auditSvc.GetAuditLogHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/{id:[0-9]+}/diff", timeouts.Wrap("diff", auditSvc.GetAuditLogDiffHandler)).Methods("GET")
+	r.HandleFunc("/api/audit-logs/verify", timeouts.Wrap("verify", auditSvc.VerifyAuditLogsHandler)).Methods("GET")
 
 	// Report routes
-	r.HandleFunc("/api/v1/audit-service/reports/{report_id}", reportSvc.GetReport).Methods("GET")
-}
+	r.HandleFunc("/api/v1/audit-service/reports/{report_id}", timeouts.Wrap("reports/{report_id}", reportSvc.GetReport)).Methods("GET")
+
+	// Session routes
+	r.HandleFunc("/api/sessions", timeouts.Wrap("sessions", reportSvc.ListSessionsHandler)).Methods("GET")
+	r.HandleFunc("/api/sessions/{session_id}/timeline", timeouts.Wrap("session-timeline", reportSvc.SessionTimelineHandler)).Methods("GET")
+
+	// Search, partitioning, retention, checkpoints, meta-events, erasure, exports and legal holds are PostgreSQL-only
+	if s.Partitions == nil {
+		return
+	}
+	partitionMgr := s.Partitions
+	retentionEngine := s.Retention
+	checkpointMgr := s.Checkpoints
+	metaEvents := s.MetaEvents
+	erasureMgr := s.Erasure
+	exporter := s.Exports
+	legalHolds := s.LegalHolds
+
+	// Full-text search relies on a PostgreSQL tsvector index
+	if reportSvc.FullTextSearch {
+		r.HandleFunc("/api/audit-logs/search", timeouts.Wrap("search", reportSvc.SearchHandler)).Methods("GET")
+	}
 
+	// Checkpoint routes
+	r.HandleFunc("/api/audit-logs/{id:[0-9]+}/proof", checkpointMgr.ProofHandler).Methods("GET")
+	r.HandleFunc("/api/audit-checkpoints", checkpointMgr.ListCheckpointsHandler).Methods("GET")
+	r.HandleFunc("/api/audit-checkpoints/public-key", checkpointMgr.PublicKeyHandler).Methods("GET")
+
+	// Admin routes need a valid X-Audit-Admin-Token
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(s.Admin.Middleware)
+	if len(s.Admin.Tokens) == 0 {
+		log.Printf("[ADMIN] AUDIT_ADMIN_TOKENS is empty - admin routes reject every request")
+	}
+	admin.HandleFunc("/partitions", partitionMgr.ListPartitionsHandler).Methods("GET")
+	admin.HandleFunc("/partitions/ensure", partitionMgr.EnsurePartitionsHandler).Methods("POST")
+	admin.HandleFunc("/checkpoints/run", checkpointMgr.RunHandler).Methods("POST")
+	admin.HandleFunc("/meta-events", metaEvents.ListEventsHandler).Methods("GET")
+	admin.HandleFunc("/exports/users/{user_id}", exporter.ExportHandler).Methods("GET")
+	admin.HandleFunc("/legal-holds", legalHolds.CreateHandler).Methods("POST")
+	admin.HandleFunc("/legal-holds", legalHolds.ListHandler).Methods("GET")
+	admin.HandleFunc("/legal-holds/{id:[0-9]+}", legalHolds.GetHandler).Methods("GET")
+	admin.HandleFunc("/legal-holds/{id:[0-9]+}/release", legalHolds.ReleaseHandler).Methods("POST")
+
+	// Routes that remove or rewrite audit rows need the maintenance role
+	if !s.Maintenance {
+		return
+	}
+	admin.HandleFunc("/partitions/{name}", partitionMgr.RemovePartitionHandler).Methods("DELETE")
+	admin.HandleFunc("/retention/preview", retentionEngine.PreviewHandler).Methods("GET")
+	admin.HandleFunc("/retention/purge", retentionEngine.PurgeHandler).Methods("POST")
+	admin.HandleFunc("/erasures", erasureMgr.SubmitHandler).Methods("POST")
+	admin.HandleFunc("/erasures", erasureMgr.ListJobsHandler).Methods("GET")
+	admin.HandleFunc("/erasures/{id:[0-9]+}", erasureMgr.GetJobHandler).Methods("GET")
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/spf13/viper"
+)
+
+// ErrEmpty is returned when a search has no words to match
+var ErrEmpty = errors.New("search query has no words")
+
+// IndexedColumns are the payload columns search_vector is built from (migration 0010)
+var IndexedColumns = []string{"body_raw", "response_body"}
+
+// Config controls full-text search: GET /api/audit-logs/search and the search report filter
+type Config struct {
+	Enabled bool
+}
+
+// LoadConfig reads AUDIT_SEARCH_ENABLED from viper
+// It defaults to true, unless enc encrypts a column the index is built from
+func LoadConfig(enc encryption.Config) Config {
+	enabled := len(Unindexed(enc)) == 0
+	if viper.IsSet("AUDIT_SEARCH_ENABLED") {
+		enabled = viper.GetBool("AUDIT_SEARCH_ENABLED")
+	}
+	return Config{Enabled: enabled}
+}
+
+// Unindexed returns the indexed columns that enc encrypts. Encrypted values are stored
+// as envelopes, which are kept out of the index, so search cannot find those rows
+func Unindexed(enc encryption.Config) []string {
+	if !enc.Enabled {
+		return nil
+	}
+	var columns []string
+	for _, column := range IndexedColumns {
+		for _, encrypted := range enc.Columns {
+			if column == encrypted {
+				columns = append(columns, column)
+			}
+		}
+	}
+	return columns
+}
+
+// Validate rejects search when the columns it indexes are encrypted
+func (c Config) Validate(enc encryption.Config) error {
+	if columns := Unindexed(enc); c.Enabled && len(columns) > 0 {
+		return fmt.Errorf("AUDIT_SEARCH_ENABLED cannot be combined with encrypting %s: encrypted rows are not indexed, so search would not find them",
+			strings.Join(columns, " and "))
+	}
+	return nil
+}
+
+// Query is a parsed search
+type Query struct {
+	TSQuery string   // Input for to_tsquery('english', ...)
+	Terms   []string // Words that must match, for substring matching where full-text search is unavailable
+}
+
+// term is one word, phrase or prefix of a search
+type term struct {
+	words   []string
+	prefix  bool
+	negated bool
+}
+
+// Parse turns a search into a tsquery
+// Terms are ANDed; "quoted words" match as a phrase, word* as a prefix, -term excludes
+// and OR between terms matches either of them (binding tighter than the implicit AND)
+// Example: `"sprint review" velocity OR burndown -draft*`
+// -> `('sprint' <-> 'review') & ('velocity' | 'burndown') & !'draft':*`
+func Parse(input string) (Query, error) {
+	var groups [][]term
+	var query Query
+	or := false
+
+	s := []rune(input)
+	for i := 0; i < len(s); {
+		if unicode.IsSpace(s[i]) {
+			i++
+			continue
+		}
+
+		var t term
+		if s[i] == '-' {
+			t.negated = true
+			i++
+		}
+		var raw string
+		if i < len(s) && s[i] == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				end++
+			}
+			raw = string(s[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(s) && !unicode.IsSpace(s[end]) && s[end] != '"' {
+				end++
+			}
+			raw = string(s[i:end])
+			i = end
+
+			if raw == "OR" && !t.negated {
+				or = len(groups) > 0
+				continue
+			}
+			if strings.HasSuffix(raw, "*") {
+				t.prefix = true
+				raw = strings.TrimRight(raw, "*")
+			}
+		}
+
+		// Punctuation separates words, as in to_tsvector: "e-mail" is the phrase e <-> mail
+		t.words = strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(t.words) == 0 {
+			or = false
+			continue
+		}
+		if !t.negated {
+			query.Terms = append(query.Terms, t.words...)
+		}
+
+		if or {
+			groups[len(groups)-1] = append(groups[len(groups)-1], t)
+		} else {
+			groups = append(groups, []term{t})
+		}
+		or = false
+	}
+	if len(query.Terms) == 0 {
+		return Query{}, ErrEmpty
+	}
+
+	rendered := make([]string, 0, len(groups))
+	for _, group := range groups {
+		alternatives := make([]string, 0, len(group))
+		for _, t := range group {
+			alternatives = append(alternatives, t.render())
+		}
+		if len(alternatives) == 1 {
+			rendered = append(rendered, alternatives[0])
+		} else {
+			rendered = append(rendered, "("+strings.Join(alternatives, " | ")+")")
+		}
+	}
+	query.TSQuery = strings.Join(rendered, " & ")
+	return query, nil
+}
+
+// render writes a term in to_tsquery syntax; words only hold letters and digits,
+// so quoting them is enough
+func (t term) render() string {
+	lexemes := make([]string, len(t.words))
+	for i, word := range t.words {
+		lexemes[i] = "'" + word + "'"
+	}
+	if t.prefix {
+		lexemes[len(lexemes)-1] += ":*"
+	}
+
+	rendered := lexemes[0]
+	if len(lexemes) > 1 {
+		rendered = "(" + strings.Join(lexemes, " <-> ") + ")"
+	}
+	if t.negated {
+		rendered = "!" + rendered
+	}
+	return rendered
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/motiso/sparksai-audit-service/internal/encryption"
+	"github.com/spf13/viper"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantQuery string
+		wantTerms []string
+	}{
+		{"velocity", `'velocity'`, []string{"velocity"}},
+		{"  sprint   velocity ", `'sprint' & 'velocity'`, []string{"sprint", "velocity"}},
+		{`"sprint review"`, `('sprint' <-> 'review')`, []string{"sprint", "review"}},
+		{"burn*", `'burn':*`, []string{"burn"}},
+		{`"sprint rev"*`, `('sprint' <-> 'rev')`, []string{"sprint", "rev"}},
+		{"velocity -draft", `'velocity' & !'draft'`, []string{"velocity"}},
+		{"velocity -draft*", `'velocity' & !'draft':*`, []string{"velocity"}},
+		{`velocity -"sprint review"`, `'velocity' & !('sprint' <-> 'review')`, []string{"velocity"}},
+		{"velocity OR burndown", `('velocity' | 'burndown')`, []string{"velocity", "burndown"}},
+		{"a OR b OR c d", `('a' | 'b' | 'c') & 'd'`, []string{"a", "b", "c", "d"}},
+		{
+			`"sprint review" velocity OR burndown -draft*`,
+			`('sprint' <-> 'review') & ('velocity' | 'burndown') & !'draft':*`,
+			[]string{"sprint", "review", "velocity", "burndown"},
+		},
+		{"OR velocity", `'velocity'`, []string{"velocity"}},
+		{"velocity OR", `'velocity'`, []string{"velocity"}},
+		{"or velocity", `'or' & 'velocity'`, []string{"or", "velocity"}},
+		{"velocity -OR", `'velocity' & !'OR'`, []string{"velocity"}},
+		{"velocity OR !!! burndown", `'velocity' & 'burndown'`, []string{"velocity", "burndown"}},
+		{"e-mail", `('e' <-> 'mail')`, []string{"e", "mail"}},
+		{"it's O'Reilly", `('it' <-> 's') & ('O' <-> 'Reilly')`, []string{"it", "s", "O", "Reilly"}},
+		{"'); DROP TABLE x; --", `'DROP' & 'TABLE' & 'x'`, []string{"DROP", "TABLE", "x"}},
+		{"crème brûlée 2026", `'crème' & 'brûlée' & '2026'`, []string{"crème", "brûlée", "2026"}},
+		{`"unterminated phrase`, `('unterminated' <-> 'phrase')`, []string{"unterminated", "phrase"}},
+		{`a"b"`, `'a' & 'b'`, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if got.TSQuery != tt.wantQuery {
+				t.Errorf("Parse(%q).TSQuery = %s, want %s", tt.input, got.TSQuery, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(got.Terms, tt.wantTerms) {
+				t.Errorf("Parse(%q).Terms = %q, want %q", tt.input, got.Terms, tt.wantTerms)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "OR", `""`, "***", "-draft", "-a -b", "!!! ???"} {
+		if got, err := Parse(input); err != ErrEmpty {
+			t.Errorf("Parse(%q) = %+v, %v, want ErrEmpty", input, got, err)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		setting       string // AUDIT_SEARCH_ENABLED, empty when unset
+		enc           encryption.Config
+		wantEnabled   bool
+		wantUnindexed []string
+		wantErr       string
+	}{
+		{
+			name:        "no encryption",
+			wantEnabled: true,
+		},
+		{
+			name:        "encryption configured but disabled",
+			enc:         encryption.Config{Columns: []string{"body_raw"}},
+			wantEnabled: true,
+		},
+		{
+			name:        "only query_raw encrypted",
+			enc:         encryption.Config{Enabled: true, Columns: []string{"query_raw"}},
+			wantEnabled: true,
+		},
+		{
+			name:          "indexed columns encrypted",
+			enc:           encryption.Config{Enabled: true, Columns: []string{"body_raw", "response_body"}},
+			wantUnindexed: []string{"body_raw", "response_body"},
+		},
+		{
+			name:          "explicitly disabled",
+			setting:       "false",
+			enc:           encryption.Config{Enabled: true, Columns: []string{"response_body"}},
+			wantUnindexed: []string{"response_body"},
+		},
+		{
+			name:          "explicitly enabled over encrypted columns",
+			setting:       "true",
+			enc:           encryption.Config{Enabled: true, Columns: []string{"body_raw"}},
+			wantEnabled:   true,
+			wantUnindexed: []string{"body_raw"},
+			wantErr:       "cannot be combined with encrypting body_raw",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			if tt.setting != "" {
+				viper.Set("AUDIT_SEARCH_ENABLED", tt.setting)
+			}
+
+			cfg := LoadConfig(tt.enc)
+			if cfg.Enabled != tt.wantEnabled {
+				t.Errorf("LoadConfig().Enabled = %v, want %v", cfg.Enabled, tt.wantEnabled)
+			}
+			if got := Unindexed(tt.enc); !reflect.DeepEqual(got, tt.wantUnindexed) {
+				t.Errorf("Unindexed() = %v, want %v", got, tt.wantUnindexed)
+			}
+			err := cfg.Validate(tt.enc)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}